@@ -0,0 +1,5 @@
+// Package v1alpha1 contains the gpuid.github.com/v1alpha1 API types.
+//
+// +k8s:deepcopy-gen=package
+// +groupName=gpuid.github.com
+package v1alpha1
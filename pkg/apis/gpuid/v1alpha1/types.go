@@ -0,0 +1,70 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GPUInventory records the GPUs gpuid observed on a single node. It is cluster
+// scoped and named after the node it describes.
+//
+// +genclient
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// +kubebuilder:resource:scope=Cluster,shortName=gpuinv
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Node",type=string,JSONPath=`.spec.nodeName`
+// +kubebuilder:printcolumn:name="GPUs",type=integer,JSONPath=`.status.gpuCount`
+// +kubebuilder:printcolumn:name="Model",type=string,JSONPath=`.status.gpus[0].model`
+// +kubebuilder:printcolumn:name="Last Scan",type=date,JSONPath=`.status.lastScan`
+type GPUInventory struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   GPUInventorySpec   `json:"spec,omitempty"`
+	Status GPUInventoryStatus `json:"status,omitempty"`
+}
+
+// GPUInventorySpec identifies the node the inventory describes.
+type GPUInventorySpec struct {
+	// NodeName is the name of the node the GPUs were observed on.
+	NodeName string `json:"nodeName"`
+}
+
+// GPUInventoryStatus is the GPU inventory last observed on the node.
+type GPUInventoryStatus struct {
+	// GPUCount is the number of GPUs (one per UUID) on the node.
+	GPUCount int `json:"gpuCount"`
+
+	// ContentHash is the hash of the chassis and GPU serials, matching the
+	// gpuid.github.com/content-hash node annotation.
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// LastScan is when the inventory was last read from the node.
+	LastScan *metav1.Time `json:"lastScan,omitempty"`
+
+	// GPUs lists the GPUs observed on the node, sorted by UUID.
+	GPUs []GPUDevice `json:"gpus,omitempty"`
+}
+
+// GPUDevice is the identity of a single GPU and when gpuid saw it.
+type GPUDevice struct {
+	Serial  string `json:"serial"`
+	UUID    string `json:"uuid,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Chassis string `json:"chassis,omitempty"`
+
+	// FirstSeen is when the GPU first appeared in this node's inventory.
+	FirstSeen metav1.Time `json:"firstSeen"`
+	// LastSeen is the last scan that observed the GPU.
+	LastSeen metav1.Time `json:"lastSeen"`
+}
+
+// GPUInventoryList is a list of GPUInventory.
+//
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+type GPUInventoryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []GPUInventory `json:"items"`
+}
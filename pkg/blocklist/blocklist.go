@@ -0,0 +1,137 @@
+// Package blocklist loads a list of GPU and chassis serial numbers that must not
+// appear in the cluster (e.g. RMA'd or vendor-flagged parts) and matches scanned
+// inventory against it.
+package blocklist
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/source"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Refresh result label values.
+	refreshSuccess = "success"
+	refreshFailure = "failure"
+)
+
+var (
+	counterRefresh = counter.New("gpuid_blocklist_refresh_total", "Total number of blocklist reloads", "result")
+	gaugeSize      = counter.NewGauge("gpuid_blocklist_serials", "Number of serial numbers in the loaded blocklist")
+)
+
+// Blocklist is a periodically reloaded set of serial numbers. It is safe for
+// concurrent use; a failed reload keeps the previously loaded list.
+type Blocklist struct {
+	source string
+	load   source.Loader
+
+	mu      sync.RWMutex
+	serials map[string]bool
+	changes chan struct{}
+}
+
+// New creates a Blocklist for source: a file path, configmap://<namespace>/<name>
+// or http(s) URL (see source.New). The list is empty until Refresh is called.
+func New(src string, cs kubernetes.Interface) (*Blocklist, error) {
+	load, err := source.New(src, cs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid blocklist source: %w", err)
+	}
+	return &Blocklist{source: src, load: load, serials: make(map[string]bool), changes: make(chan struct{}, 1)}, nil
+}
+
+// Changes signals every time a refresh loads a different list. The channel is
+// buffered by one, so changes made while the reader is busy coalesce.
+func (b *Blocklist) Changes() <-chan struct{} {
+	return b.changes
+}
+
+// Refresh reloads the blocklist from its source.
+func (b *Blocklist) Refresh(ctx context.Context, log *slog.Logger) error {
+	data, err := b.load(ctx)
+	if err != nil {
+		counterRefresh.Increment(refreshFailure)
+		return fmt.Errorf("failed to load blocklist from %s: %w", b.source, err)
+	}
+
+	serials, err := Parse(data)
+	if err != nil {
+		counterRefresh.Increment(refreshFailure)
+		return fmt.Errorf("failed to parse blocklist from %s: %w", b.source, err)
+	}
+
+	b.mu.Lock()
+	changed := !maps.Equal(b.serials, serials)
+	b.serials = serials
+	b.mu.Unlock()
+
+	if changed {
+		select {
+		case b.changes <- struct{}{}:
+		default:
+		}
+	}
+
+	counterRefresh.Increment(refreshSuccess)
+	gaugeSize.Set(float64(len(serials)))
+	log.Debug("blocklist loaded", "source", b.source, "serials", len(serials))
+	return nil
+}
+
+// Match returns the sorted GPU and chassis serials in serials that are blocklisted.
+func (b *Blocklist) Match(serials []*gpu.Serials) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	found := make(map[string]bool)
+	for _, s := range serials {
+		if s == nil {
+			continue
+		}
+		if s.Chassis != "" && b.serials[s.Chassis] {
+			found[s.Chassis] = true
+		}
+		for _, g := range s.GPU {
+			if b.serials[g] {
+				found[g] = true
+			}
+		}
+	}
+
+	out := make([]string, 0, len(found))
+	for s := range found {
+		out = append(out, s)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Parse reads one serial number per line. Blank lines and anything after a #
+// are ignored, so entries can carry a note: `1652823054567  # RMA 2026-03`. A line
+// that can't be read fails the whole list rather than loading part of it.
+func Parse(data []byte) (map[string]bool, error) {
+	serials := make(map[string]bool)
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line, _, _ := strings.Cut(sc.Text(), "#")
+		if line = strings.TrimSpace(line); line != "" {
+			serials[line] = true
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read blocklist: %w", err)
+	}
+	return serials, nil
+}
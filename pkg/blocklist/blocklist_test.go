@@ -0,0 +1,165 @@
+package blocklist
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+const testList = `
+# RMA batch 2026-03
+1652823054567   # fell off the bus
+1821325191344
+`
+
+var testSerials = []*gpu.Serials{
+	{Chassis: "1821325191344", GPU: []string{"1761025346615"}},
+	{Chassis: "1821325190000", GPU: []string{"1652823054567", "1652823055642"}},
+	nil,
+}
+
+func TestParse(t *testing.T) {
+	want := map[string]bool{"1652823054567": true, "1821325191344": true}
+	got, err := Parse([]byte(testList))
+	if err != nil {
+		t.Fatalf("Parse() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse() = %v, want %v", got, want)
+	}
+
+	// A line longer than the scanner buffer fails the list instead of truncating it.
+	long := testList + strings.Repeat("1", 70*1024) + "\n1650924060039\n"
+	if _, err := Parse([]byte(long)); err == nil {
+		t.Error("Parse() expected error for an over-long line")
+	}
+}
+
+func TestRefreshKeepsListOnParseError(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "blocklist.txt")
+	if err := os.WriteFile(path, []byte(testList), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(path, nil)
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
+	if err := b.Refresh(context.Background(), slog.Default()); err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte(strings.Repeat("1", 70*1024)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Refresh(context.Background(), slog.Default()); err == nil {
+		t.Fatal("Refresh() expected error for an unreadable list")
+	}
+	if got := b.Match(testSerials); len(got) != 2 {
+		t.Errorf("Match() after failed refresh = %v, want the previous list", got)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	cs := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "gpuid", Name: "blocklist"},
+		Data:       map[string]string{"rma.txt": testList},
+	})
+
+	b, err := New("configmap://gpuid/blocklist", cs)
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
+	if got := b.Match(testSerials); len(got) != 0 {
+		t.Errorf("Match() before Refresh = %v, want none", got)
+	}
+	if err := b.Refresh(context.Background(), slog.Default()); err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+
+	want := []string{"1652823054567", "1821325191344"}
+	if got := b.Match(testSerials); !reflect.DeepEqual(got, want) {
+		t.Errorf("Match() = %v, want %v", got, want)
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	if _, err := New("ftp://example.com/list", nil); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
+
+func TestRefreshKeepsListOnFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte(testList), 0o600); err != nil {
+		t.Fatalf("failed to write blocklist: %v", err)
+	}
+	b, err := New(path, nil)
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
+	if err := b.Refresh(context.Background(), slog.Default()); err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("failed to remove blocklist: %v", err)
+	}
+	if err := b.Refresh(context.Background(), slog.Default()); err == nil {
+		t.Fatal("expected error for missing file")
+	}
+	if got := b.Match(testSerials); len(got) != 2 {
+		t.Errorf("Match() after failed refresh = %v, want previous list", got)
+	}
+}
+
+func TestChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte(testList), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	b, err := New(path, nil)
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
+
+	changed := func() bool {
+		select {
+		case <-b.Changes():
+			return true
+		default:
+			return false
+		}
+	}
+	refresh := func() {
+		t.Helper()
+		if err := b.Refresh(context.Background(), slog.Default()); err != nil {
+			t.Fatalf("Refresh() unexpected error: %v", err)
+		}
+	}
+
+	refresh()
+	if !changed() {
+		t.Error("expected a change after the first load")
+	}
+	refresh()
+	if changed() {
+		t.Error("expected no change when the list is the same")
+	}
+	if err := os.WriteFile(path, []byte(testList+"1650924060039\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	refresh()
+	if !changed() {
+		t.Error("expected a change after a serial was added")
+	}
+}
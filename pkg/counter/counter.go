@@ -41,6 +41,52 @@ func New(name, help string, labels ...string) IncrementalCounter {
 	}
 }
 
+// SettableGauge defines an interface for gauges that can be set to an arbitrary value with optional label values.
+type SettableGauge interface {
+	Set(v float64, val ...string)
+	Delete(val ...string)
+	Reset()
+}
+
+// Gauge wraps a Prometheus GaugeVec to provide a simple interface for setting gauges with labels.
+type Gauge struct {
+	Name string
+	Help string
+
+	vec *prometheus.GaugeVec
+}
+
+// Set sets the gauge to v for the given label values.
+func (g *Gauge) Set(v float64, val ...string) {
+	g.vec.WithLabelValues(val...).Set(v)
+}
+
+// Delete removes the series for the given label values.
+func (g *Gauge) Delete(val ...string) {
+	g.vec.DeleteLabelValues(val...)
+}
+
+// Reset deletes all label combinations so stale series disappear from the exposition.
+func (g *Gauge) Reset() {
+	g.vec.Reset()
+}
+
+// NewGauge creates and registers a new Prometheus gauge with the given name, help text, and optional labels.
+func NewGauge(name, help string, labels ...string) SettableGauge {
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: name,
+		Help: help,
+	}, labels)
+
+	prometheus.MustRegister(gauge)
+
+	return &Gauge{
+		Name: name,
+		Help: help,
+		vec:  gauge,
+	}
+}
+
 // Handler returns an HTTP handler for serving Prometheus metrics.
 func Handler() http.Handler {
 	return promhttp.Handler()
@@ -71,3 +71,31 @@ func TestHandler(t *testing.T) {
 
 	t.Log("Handler correctly serves Prometheus metrics")
 }
+
+func TestGauge_Set(t *testing.T) {
+	g := NewGauge("test_gauge_metric", "Test gauge", "status")
+	g.Set(3, "success")
+	g.Set(1, "failure")
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	body := rr.Body.String()
+	if !strings.Contains(body, `test_gauge_metric{status="success"} 3`) {
+		t.Errorf("expected gauge value in output, got:\n%s", body)
+	}
+
+	g.Delete("failure")
+	rr = httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(rr.Body.String(), `test_gauge_metric{status="failure"}`) {
+		t.Error("expected gauge series to be removed after Delete")
+	}
+
+	g.Reset()
+	rr = httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(rr.Body.String(), `test_gauge_metric{`) {
+		t.Error("expected gauge series to be removed after Reset")
+	}
+}
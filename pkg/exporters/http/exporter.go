@@ -21,6 +21,15 @@ const (
 	EnvHTTPEndpoint  = "HTTP_ENDPOINT"
 	EnvHTTPTimeout   = "HTTP_TIMEOUT"
 	EnvHTTPAuthToken = "HTTP_AUTH_TOKEN" // #nosec G101 - this is an environment variable name, not a credential
+
+	// EnvHTTPAttributionEndpoint receives workload attribution records; defaults to HTTP_ENDPOINT.
+	EnvHTTPAttributionEndpoint = "HTTP_ATTRIBUTION_ENDPOINT"
+)
+
+// Record type header values, so one endpoint can receive both record kinds.
+const (
+	recordTypeReading     = "reading"
+	recordTypeAttribution = "attribution"
 )
 
 // Default configuration values
@@ -36,6 +45,9 @@ type Exporter struct {
 	Timeout   time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
 	AuthToken string        `json:"auth_token,omitempty" yaml:"auth_token,omitempty"`
 
+	// AttributionEndpoint receives workload attribution records (empty = Endpoint).
+	AttributionEndpoint string `json:"attribution_endpoint,omitempty" yaml:"attribution_endpoint,omitempty"`
+
 	client *http.Client
 }
 
@@ -49,6 +61,10 @@ func (e *Exporter) Validate() error {
 		return fmt.Errorf("HTTP endpoint must be a valid HTTP/HTTPS URL")
 	}
 
+	if e.AttributionEndpoint != "" && !strings.HasPrefix(e.AttributionEndpoint, "http://") && !strings.HasPrefix(e.AttributionEndpoint, "https://") {
+		return fmt.Errorf("HTTP attribution endpoint must be a valid HTTP/HTTPS URL")
+	}
+
 	if e.Timeout <= 0 {
 		return fmt.Errorf("timeout must be positive, got %v", e.Timeout)
 	}
@@ -64,11 +80,14 @@ func (e *Exporter) Validate() error {
 // Optional environment variables:
 //   - HTTP_TIMEOUT: Request timeout in seconds - defaults to 30
 //   - HTTP_AUTH_TOKEN: Bearer token for authentication
+//   - HTTP_ATTRIBUTION_ENDPOINT: Endpoint for workload attribution records - defaults to HTTP_ENDPOINT
 func New(_ context.Context) (*Exporter, error) {
 	exp := &Exporter{
 		Endpoint:  getEnv(EnvHTTPEndpoint, ""),
 		Timeout:   parseDuration(getEnv(EnvHTTPTimeout, "30s"), DefaultTimeout),
 		AuthToken: getEnv(EnvHTTPAuthToken, ""),
+
+		AttributionEndpoint: getEnv(EnvHTTPAttributionEndpoint, ""),
 	}
 
 	if err := exp.Validate(); err != nil {
@@ -95,16 +114,64 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 		return fmt.Errorf("failed to serialize records: %w", err)
 	}
 
+	status, err := e.post(ctx, e.Endpoint, recordTypeReading, len(records), data)
+	if err != nil {
+		return err
+	}
+
+	log.Info("export completed",
+		"endpoint", e.Endpoint,
+		"records", len(records),
+		"size_bytes", len(data),
+		"status", status)
+
+	return nil
+}
+
+// WriteAttributions sends workload attribution records as JSON to the
+// attribution endpoint, or to the main endpoint with X-Record-Type: attribution.
+func (e *Exporter) WriteAttributions(ctx context.Context, log *slog.Logger, records []*gpu.AttributionRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		return fmt.Errorf("failed to serialize attribution records: %w", err)
+	}
+
+	endpoint := e.AttributionEndpoint
+	if endpoint == "" {
+		endpoint = e.Endpoint
+	}
+
+	status, err := e.post(ctx, endpoint, recordTypeAttribution, len(records), data)
+	if err != nil {
+		return err
+	}
+
+	log.Info("attribution export completed",
+		"endpoint", endpoint,
+		"records", len(records),
+		"size_bytes", len(data),
+		"status", status)
+
+	return nil
+}
+
+// post sends a JSON batch of records and returns the response status code.
+func (e *Exporter) post(ctx context.Context, endpoint, recordType string, count int, data []byte) (int, error) {
 	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(data))
 	if err != nil {
-		return fmt.Errorf("failed to create HTTP request: %w", err)
+		return 0, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 
 	// Set headers
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "gpuid-http-exporter/1.0")
-	req.Header.Set("X-Records-Count", strconv.Itoa(len(records)))
+	req.Header.Set("X-Record-Type", recordType)
+	req.Header.Set("X-Records-Count", strconv.Itoa(count))
 	req.Header.Set("X-Timestamp", time.Now().UTC().Format(time.RFC3339))
 
 	// Add authentication if configured
@@ -114,7 +181,7 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 
 	resp, err := e.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send HTTP request: %w", err)
+		return 0, fmt.Errorf("failed to send HTTP request: %w", err)
 	}
 	defer func() {
 		_, _ = io.Copy(io.Discard, resp.Body) // allow connection reuse
@@ -122,16 +189,10 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 	}()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("HTTP request failed with status %d", resp.StatusCode)
+		return resp.StatusCode, fmt.Errorf("HTTP request failed with status %d", resp.StatusCode)
 	}
 
-	log.Info("export completed",
-		"endpoint", e.Endpoint,
-		"records", len(records),
-		"size_bytes", len(data),
-		"status", resp.StatusCode)
-
-	return nil
+	return resp.StatusCode, nil
 }
 
 // Close performs cleanup of HTTP client resources.
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -167,6 +168,46 @@ func TestExporter_HTTPServerIntegration(t *testing.T) {
 	t.Logf("Successfully sent %d records to HTTP server", len(records))
 }
 
+func TestExporter_WriteAttributions(t *testing.T) {
+	var paths, recordTypes []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		recordTypes = append(recordTypes, r.Header.Get("X-Record-Type"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	records := []*gpu.AttributionRecord{
+		{
+			Cluster:   "test-cluster",
+			Namespace: "ml",
+			Pod:       "train-0",
+			UID:       "4a1d",
+			Node:      "test-node",
+			GPUs:      []string{"1652823054567"},
+			Requested: 1,
+			Start:     time.Date(2025, 9, 11, 10, 30, 0, 0, time.UTC),
+		},
+	}
+
+	exporter := &Exporter{Endpoint: server.URL + "/readings", Timeout: 10 * time.Second, client: server.Client()}
+	if err := exporter.WriteAttributions(context.Background(), slog.Default(), records); err != nil {
+		t.Fatalf("WriteAttributions() unexpected error: %v", err)
+	}
+
+	exporter.AttributionEndpoint = server.URL + "/attributions"
+	if err := exporter.WriteAttributions(context.Background(), slog.Default(), records); err != nil {
+		t.Fatalf("WriteAttributions() unexpected error: %v", err)
+	}
+
+	if want := []string{"/readings", "/attributions"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+	if want := []string{recordTypeAttribution, recordTypeAttribution}; !reflect.DeepEqual(recordTypes, want) {
+		t.Errorf("X-Record-Type = %v, want %v", recordTypes, want)
+	}
+}
+
 func TestExporter_HealthCheck(t *testing.T) {
 	healthyServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodHead {
@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"os"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -27,15 +28,23 @@ const (
 	EnvPostgresSSLMode     = "POSTGRES_SSLMODE"
 	EnvPostgresTable       = "POSTGRES_TABLE"
 	EnvPostgresAutoMigrate = "POSTGRES_AUTO_MIGRATE"
+	EnvPostgresAttribTable = "POSTGRES_ATTRIBUTION_TABLE"
 
 	// Default values
 	defaultPostgresPort  = 5432
 	defaultPostgresTable = "gpu"
+	defaultAttribTable   = "gpu_attribution"
 	defaultSSLMode       = "require"
 
 	// SQL query template for inserting GPU serial readings.
 	// Retained for fallback / tests; production writes use pq.CopyIn for batch throughput.
 	insertQueryTemplate = `INSERT INTO %s (cluster, node, machine, source, chassis, gpu, read_time, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	// SQL query template for inserting workload attribution records.
+	insertAttributionTemplate = `INSERT INTO %s (cluster, namespace, pod, uid, owner, node, machine, chassis, gpus, requested, exact, start_time, end_time, created_at) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+
+	// eventSeen is written to the event column for scan readings.
+	eventSeen = "seen"
 )
 
 // Config holds PostgreSQL-specific configuration parameters.
@@ -47,6 +56,15 @@ type Config struct {
 	Password string `json:"password" yaml:"password"`
 	SSLMode  string `json:"sslmode" yaml:"sslmode"`
 	Table    string `json:"table" yaml:"table"`
+
+	// AttributionTable holds workload attribution records.
+	AttributionTable string `json:"attribution_table" yaml:"attribution_table"`
+	// Attribution is set when workload attribution records are exported; only
+	// then does the schema bootstrap create AttributionTable.
+	Attribution bool `json:"attribution" yaml:"attribution"`
+	// Removals is set when node removal records are exported; only then does the
+	// schema bootstrap add the event column to a Table created without it.
+	Removals bool `json:"removals" yaml:"removals"`
 }
 
 // Exporter implements the ExporterBackend interface for PostgreSQL.
@@ -68,8 +86,14 @@ type Exporter struct {
 //   - POSTGRES_PORT: Database port (defaults to 5432)
 //   - POSTGRES_SSLMODE: SSL mode (defaults to require)
 //   - POSTGRES_TABLE: Table name (defaults to gpu_serial_readings)
-func New(ctx context.Context) (*Exporter, error) {
+//   - POSTGRES_ATTRIBUTION_TABLE: Workload attribution table name (defaults to gpu_attribution)
+//
+// attribution reports whether workload attribution records will be written and
+// removals whether node removal records will be.
+func New(ctx context.Context, attribution, removals bool) (*Exporter, error) {
 	config := loadConfigFromEnv()
+	config.Attribution = attribution
+	config.Removals = removals
 
 	if validationErr := config.Validate(); validationErr != nil {
 		return nil, fmt.Errorf("PostgreSQL configuration validation failed: %w", validationErr)
@@ -134,12 +158,19 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 		}
 	}()
 
+	// The event column is only written for batches with removal records, so
+	// tables that predate it keep accepting scan readings.
+	columns := []string{"cluster", "node", "machine", "source", "chassis", "gpu", "read_time", "created_at"}
+	withEvent := slices.ContainsFunc(records, func(r *gpu.SerialNumberReading) bool { return r != nil && r.Event != "" })
+	if withEvent {
+		columns = append(columns, "event")
+	}
+
 	// pq.CopyIn is the documented entry-point for the COPY FROM STDIN protocol
 	// under database/sql; lib/pq's deprecation note refers to library callers
 	// who can use the pgx driver instead. We stay on database/sql, so this is
 	// the correct API.
-	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(e.config.Table, //nolint:staticcheck // SA1019: see comment above
-		"cluster", "node", "machine", "source", "chassis", "gpu", "read_time", "created_at"))
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn(e.config.Table, columns...)) //nolint:staticcheck // SA1019: see comment above
 	if err != nil {
 		return fmt.Errorf("failed to prepare COPY statement: %w", err)
 	}
@@ -150,7 +181,7 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 		if record == nil {
 			continue
 		}
-		if _, err := stmt.ExecContext(ctx,
+		values := []any{
 			record.Cluster,
 			record.Node,
 			record.Machine,
@@ -159,7 +190,11 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 			record.GPU,
 			record.Time,
 			now,
-		); err != nil {
+		}
+		if withEvent {
+			values = append(values, setDefaultIfEmpty(record.Event, eventSeen))
+		}
+		if _, err := stmt.ExecContext(ctx, values...); err != nil {
 			stmt.Close()
 			return fmt.Errorf("failed to buffer record for COPY: %w", err)
 		}
@@ -188,6 +223,76 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 	return nil
 }
 
+// WriteAttributions inserts workload attribution records in a single transaction.
+func (e *Exporter) WriteAttributions(ctx context.Context, log *slog.Logger, records []*gpu.AttributionRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	tx, err := e.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			if rbErr := tx.Rollback(); rbErr != nil && !errors.Is(rbErr, sql.ErrTxDone) {
+				log.Error("transaction rollback failed", "error", rbErr)
+			}
+		}
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(insertAttributionTemplate, e.config.AttributionTable))
+	if err != nil {
+		return fmt.Errorf("failed to prepare attribution insert: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC()
+	rows := 0
+	for _, r := range records {
+		if r == nil {
+			continue
+		}
+		var end sql.NullTime
+		if !r.End.IsZero() {
+			end = sql.NullTime{Time: r.End, Valid: true}
+		}
+		if _, err := stmt.ExecContext(ctx,
+			r.Cluster,
+			r.Namespace,
+			r.Pod,
+			r.UID,
+			r.Owner,
+			r.Node,
+			r.Machine,
+			pq.Array(r.Chassis),
+			pq.Array(r.GPUs),
+			r.Requested,
+			r.Exact,
+			r.Start,
+			end,
+			now,
+		); err != nil {
+			return fmt.Errorf("failed to insert attribution record: %w", err)
+		}
+		rows++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	committed = true
+
+	log.Info("attribution export completed",
+		"table", e.config.AttributionTable,
+		"records", rows,
+		"database", e.config.Database)
+
+	return nil
+}
+
 // Close performs cleanup of PostgreSQL connection resources.
 func (e *Exporter) Close(_ context.Context) error {
 	if e.db != nil {
@@ -226,6 +331,7 @@ func (e *Exporter) initializeSchema(ctx context.Context) error {
 			gpu VARCHAR(255) NOT NULL,
 			read_time TIMESTAMP WITH TIME ZONE NOT NULL,
 			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+			event VARCHAR(16) NOT NULL DEFAULT 'seen',
 			UNIQUE(cluster, node, machine, source, chassis, gpu, read_time)
 		)`, e.config.Table)
 
@@ -234,6 +340,14 @@ func (e *Exporter) initializeSchema(ctx context.Context) error {
 		return fmt.Errorf("failed to create table %s: %w", e.config.Table, err)
 	}
 
+	// Tables created before removal records existed lack the event column.
+	if e.config.Removals {
+		if _, err := e.db.ExecContext(ctx, fmt.Sprintf(
+			"ALTER TABLE %s ADD COLUMN IF NOT EXISTS event VARCHAR(16) NOT NULL DEFAULT 'seen'", e.config.Table)); err != nil {
+			return fmt.Errorf("failed to add event column to %s: %w", e.config.Table, err)
+		}
+	}
+
 	// Create indexes for efficient querying
 	indexQueries := []string{
 		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_cluster ON %s (cluster)", e.config.Table, e.config.Table),
@@ -248,6 +362,43 @@ func (e *Exporter) initializeSchema(ctx context.Context) error {
 		}
 	}
 
+	if !e.config.Attribution {
+		return nil
+	}
+
+	// Workload attribution: one row per start and finish of a pod; the latest
+	// row for a uid is authoritative.
+	t := e.config.AttributionTable
+	attributionQueries := []string{
+		fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			id BIGSERIAL PRIMARY KEY,
+			cluster VARCHAR(255) NOT NULL,
+			namespace VARCHAR(255) NOT NULL,
+			pod VARCHAR(255) NOT NULL,
+			uid VARCHAR(64) NOT NULL,
+			owner VARCHAR(512) NOT NULL,
+			node VARCHAR(255) NOT NULL,
+			machine VARCHAR(255) NOT NULL,
+			chassis TEXT[] NOT NULL,
+			gpus TEXT[] NOT NULL,
+			requested BIGINT NOT NULL,
+			exact BOOLEAN NOT NULL,
+			start_time TIMESTAMP WITH TIME ZONE NOT NULL,
+			end_time TIMESTAMP WITH TIME ZONE,
+			created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+		)`, t),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_uid ON %s (uid)", t, t),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_start_time ON %s (start_time)", t, t),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS idx_%s_gpus ON %s USING GIN (gpus)", t, t),
+	}
+
+	for _, query := range attributionQueries {
+		if _, err := e.db.ExecContext(ctx, query); err != nil {
+			return fmt.Errorf("failed to create attribution table %s: %w", t, err)
+		}
+	}
+
 	return nil
 }
 
@@ -261,6 +412,8 @@ func loadConfigFromEnv() Config {
 		Password: os.Getenv(EnvPostgresPassword), // No default for security
 		SSLMode:  getEnv(EnvPostgresSSLMode, defaultSSLMode),
 		Table:    getEnv(EnvPostgresTable, defaultPostgresTable),
+
+		AttributionTable: getEnv(EnvPostgresAttribTable, defaultAttribTable),
 	}
 
 	return config
@@ -298,6 +451,12 @@ func (c *Config) Validate() error {
 	if !tableNameRegex.MatchString(c.Table) {
 		return fmt.Errorf("postgres table name contains invalid characters: %s", c.Table)
 	}
+	if c.AttributionTable == "" {
+		c.AttributionTable = defaultAttribTable
+	}
+	if !tableNameRegex.MatchString(c.AttributionTable) {
+		return fmt.Errorf("postgres attribution table name contains invalid characters: %q", c.AttributionTable)
+	}
 
 	return nil
 }
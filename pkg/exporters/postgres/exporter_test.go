@@ -111,6 +111,21 @@ func TestExporter_Configuration(t *testing.T) {
 			expectError: true,
 			errorMsg:    "postgres port must be between 1 and 65535",
 		},
+		{
+			name: "invalid_attribution_table",
+			config: Config{
+				Host:             "localhost",
+				Port:             5432,
+				Database:         "testdb",
+				User:             "testuser",
+				Password:         "testpass",
+				SSLMode:          "require",
+				Table:            "gpu_readings",
+				AttributionTable: "gpu;drop",
+			},
+			expectError: true,
+			errorMsg:    "postgres attribution table name contains invalid characters",
+		},
 		{
 			name: "empty_table",
 			config: Config{
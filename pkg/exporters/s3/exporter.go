@@ -24,6 +24,18 @@ const (
 	EnvS3PartitionPattern = "S3_PARTITION_PATTERN"
 )
 
+const (
+	// attributionPrefix is the key directory for workload attribution records.
+	attributionPrefix = "attribution"
+
+	// removedPrefix is the key directory for readings of GPUs on deleted nodes.
+	removedPrefix = "removed"
+
+	// Column orders recorded in object metadata for DMS mapping.
+	readingColumns     = "cluster,node,machine,source,chassis,gpu,time"
+	attributionColumns = "cluster,namespace,pod,uid,owner,node,machine,chassis,gpus,requested,exact,start,end"
+)
+
 // Exporter implements the ExporterBackend interface for Amazon S3.
 // This exporter supports time-based partitioning and efficient batch uploads
 // suitable for high-throughput distributed GPU monitoring systems.
@@ -82,24 +94,40 @@ func New(ctx context.Context) (*Exporter, error) {
 
 // Write uploads GPU serial number readings to S3 with time-based partitioning.
 // Records are batched and uploaded as headerless CSV for DMS compatibility and efficient processing.
+// Removal records (gpu.ReadingRemoved) have the same columns and go under the removed/ prefix.
 func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.SerialNumberReading) error {
+	var readings, removed []*gpu.SerialNumberReading
+	for _, record := range records {
+		switch {
+		case record == nil:
+		case record.Event == gpu.ReadingRemoved:
+			removed = append(removed, record)
+		default:
+			readings = append(readings, record)
+		}
+	}
+
+	if err := e.writeReadings(ctx, log, "", readings); err != nil {
+		return err
+	}
+	return e.writeReadings(ctx, log, removedPrefix, removed)
+}
+
+// writeReadings uploads records as one CSV object under the kind directory.
+func (e *Exporter) writeReadings(ctx context.Context, log *slog.Logger, kind string, records []*gpu.SerialNumberReading) error {
 	if len(records) == 0 {
 		return nil
 	}
 
 	// Create time-partitioned S3 key for efficient data organization
 	timestamp := time.Now().UTC()
-	key := e.generateS3Key(timestamp)
+	key := e.generateKey(kind, timestamp)
 
 	// Serialize records to CSV format for efficient processing
 	var buffer bytes.Buffer
 	writer := csv.NewWriter(&buffer)
 
 	for _, record := range records {
-		if record == nil {
-			continue
-		}
-
 		if err := writer.Write(record.Slice()); err != nil {
 			return fmt.Errorf("failed to write CSV record: %w", err)
 		}
@@ -112,19 +140,7 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 	}
 
 	// Upload to S3
-	if _, err := e.client.PutObject(ctx, &s3.PutObjectInput{
-		Bucket:      aws.String(e.Bucket),
-		Key:         aws.String(key),
-		Body:        bytes.NewReader(buffer.Bytes()),
-		ContentType: aws.String("text/csv"),
-		Metadata: map[string]string{
-			"source":       "gpuid",
-			"record_count": fmt.Sprintf("%d", len(records)),
-			"timestamp":    timestamp.Format(time.RFC3339),
-			"format":       "csv",
-			"columns":      "cluster,node,machine,source,chassis,gpu,time", // Column order for DMS mapping
-		},
-	}); err != nil {
+	if err := e.upload(ctx, key, buffer.Bytes(), len(records), timestamp, readingColumns); err != nil {
 		return fmt.Errorf("failed to upload records to S3: %w", err)
 	}
 
@@ -137,6 +153,73 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 	return nil
 }
 
+// WriteAttributions uploads workload attribution records as headerless CSV under
+// the attribution/ prefix, using the same time-based partitioning as readings.
+func (e *Exporter) WriteAttributions(ctx context.Context, log *slog.Logger, records []*gpu.AttributionRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	timestamp := time.Now().UTC()
+	key := e.generateKey(attributionPrefix, timestamp)
+
+	data, err := attributionCSV(records)
+	if err != nil {
+		return err
+	}
+
+	if err := e.upload(ctx, key, data, len(records), timestamp, attributionColumns); err != nil {
+		return fmt.Errorf("failed to upload attribution records to S3: %w", err)
+	}
+
+	log.Info("attribution export completed",
+		"bucket", e.Bucket,
+		"key", key,
+		"records", len(records),
+		"size_bytes", len(data))
+
+	return nil
+}
+
+// attributionCSV serializes attribution records to headerless CSV.
+func attributionCSV(records []*gpu.AttributionRecord) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+
+	for _, record := range records {
+		if record == nil {
+			continue
+		}
+		if err := writer.Write(record.Slice()); err != nil {
+			return nil, fmt.Errorf("failed to write CSV attribution record: %w", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return nil, fmt.Errorf("failed to flush CSV writer: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// upload puts a CSV object with metadata describing its columns.
+func (e *Exporter) upload(ctx context.Context, key string, data []byte, count int, timestamp time.Time, columns string) error {
+	_, err := e.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(e.Bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("text/csv"),
+		Metadata: map[string]string{
+			"source":       "gpuid",
+			"record_count": fmt.Sprintf("%d", count),
+			"timestamp":    timestamp.Format(time.RFC3339),
+			"format":       "csv",
+			"columns":      columns, // Column order for DMS mapping
+		},
+	})
+	return err
+}
+
 // Close performs cleanup of S3 client resources.
 func (e *Exporter) Close(_ context.Context) error {
 	return nil
@@ -156,6 +239,12 @@ func (e *Exporter) Health(ctx context.Context) error {
 // generateS3Key creates a time-partitioned S3 key for efficient data organization.
 // Default pattern: prefix/year=YYYY/month=MM/day=DD/hour=HH/timestamp.csv
 func (e *Exporter) generateS3Key(timestamp time.Time) string {
+	return e.generateKey("", timestamp)
+}
+
+// generateKey creates a time-partitioned S3 key with an optional record type
+// directory between the prefix and the partition: prefix/kind/year=YYYY/...
+func (e *Exporter) generateKey(kind string, timestamp time.Time) string {
 	pattern := e.PartitionPattern
 	if pattern == "" {
 		pattern = "year=%Y/month=%m/day=%d/hour=%H"
@@ -169,6 +258,10 @@ func (e *Exporter) generateS3Key(timestamp time.Time) string {
 
 	filename := fmt.Sprintf("%s.csv", timestamp.Format("20060102-150405.000"))
 
+	if kind != "" {
+		pattern = kind + "/" + pattern
+	}
+
 	if e.Prefix != "" {
 		return fmt.Sprintf("%s/%s/%s", e.Prefix, pattern, filename)
 	}
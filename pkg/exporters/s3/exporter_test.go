@@ -1,6 +1,9 @@
 package s3
 
 import (
+	"encoding/csv"
+	"log/slog"
+	"strings"
 	"testing"
 	"time"
 
@@ -58,6 +61,61 @@ func TestExporter_CSVOutput(t *testing.T) {
 	t.Logf("Would upload %d records", len(records))
 }
 
+// TestAttributionOutput tests attribution keys and CSV serialization
+func TestAttributionOutput(t *testing.T) {
+	exporter := &Exporter{Bucket: "test-bucket", Region: "us-east-1", Prefix: "gpuid"}
+	key := exporter.generateKey(attributionPrefix, time.Date(2025, 9, 11, 10, 30, 0, 0, time.UTC))
+	if !strings.HasPrefix(key, "gpuid/attribution/year=2025/month=09/day=11/hour=10/") {
+		t.Errorf("unexpected attribution key: %s", key)
+	}
+
+	records := []*gpu.AttributionRecord{
+		{
+			Cluster:   "test-cluster",
+			Namespace: "team-a",
+			Pod:       "train-0",
+			UID:       "uid-1",
+			Owner:     "Job/train",
+			Node:      "test-node-1",
+			Machine:   "test-machine-1",
+			GPUs:      []string{"GPU-1", "GPU-2"},
+			Requested: 2,
+			Exact:     true,
+			Start:     time.Date(2025, 9, 11, 10, 30, 0, 0, time.UTC),
+		},
+		nil,
+	}
+
+	data, err := attributionCSV(records)
+	if err != nil {
+		t.Fatalf("attributionCSV failed: %v", err)
+	}
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+	if got, want := len(rows[0]), len(strings.Split(attributionColumns, ",")); got != want {
+		t.Errorf("expected %d columns, got %d", want, got)
+	}
+}
+
+// TestRemovedKey tests that removal records are keyed under the removed/ prefix
+func TestRemovedKey(t *testing.T) {
+	exporter := &Exporter{Bucket: "test-bucket", Region: "us-east-1", Prefix: "gpuid"}
+	key := exporter.generateKey(removedPrefix, time.Date(2025, 9, 11, 10, 30, 0, 0, time.UTC))
+	if !strings.HasPrefix(key, "gpuid/removed/year=2025/month=09/day=11/hour=10/") {
+		t.Errorf("unexpected removal key: %s", key)
+	}
+
+	// Nothing to upload: no client call is made.
+	if err := exporter.Write(t.Context(), slog.Default(), []*gpu.SerialNumberReading{nil}); err != nil {
+		t.Errorf("Write() of no records error = %v", err)
+	}
+}
+
 // endsWith checks if a string ends with a suffix
 func endsWith(s, suffix string) bool {
 	return len(s) >= len(suffix) && s[len(s)-len(suffix):] == suffix
@@ -30,7 +30,7 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 			continue // Skip nil records gracefully
 		}
 
-		log.InfoContext(ctx, "gpu serial number reading",
+		attrs := []any{
 			"cluster", reading.Cluster,
 			"node", reading.Node,
 			"machine", reading.Machine,
@@ -38,7 +38,11 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 			"chassis", reading.Chassis,
 			"gpu", reading.GPU,
 			"time", reading.Time,
-		)
+		}
+		if reading.Event != "" {
+			attrs = append(attrs, "event", reading.Event)
+		}
+		log.InfoContext(ctx, "gpu serial number reading", attrs...)
 	}
 
 	log.Info("export completed", "records", len(records))
@@ -46,6 +50,40 @@ func (e *Exporter) Write(ctx context.Context, log *slog.Logger, records []*gpu.S
 	return nil
 }
 
+// WriteAttributions outputs workload attribution records to stdout, one log line each.
+func (e *Exporter) WriteAttributions(ctx context.Context, log *slog.Logger, records []*gpu.AttributionRecord) error {
+	if records == nil {
+		return fmt.Errorf("records is nil")
+	}
+
+	for _, r := range records {
+		if r == nil {
+			continue
+		}
+
+		attrs := []any{
+			"cluster", r.Cluster,
+			"namespace", r.Namespace,
+			"pod", r.Pod,
+			"uid", r.UID,
+			"owner", r.Owner,
+			"node", r.Node,
+			"machine", r.Machine,
+			"chassis", r.Chassis,
+			"gpus", r.GPUs,
+			"requested", r.Requested,
+			"exact", r.Exact,
+			"start", r.Start,
+		}
+		if !r.End.IsZero() {
+			attrs = append(attrs, "end", r.End)
+		}
+		log.InfoContext(ctx, "gpu workload attribution", attrs...)
+	}
+
+	return nil
+}
+
 // Close performs cleanup for the stdout exporter.
 // Since stdout output doesn't require cleanup, this is a no-op but satisfies the interface.
 func (e *Exporter) Close(_ context.Context) error {
@@ -0,0 +1,203 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// EphemeralPrefix names the debug containers gpuid attaches for collection.
+	EphemeralPrefix = "gpuid-collect-"
+
+	// ephemeralPoll is how often the pod is polled for the debug container to finish.
+	ephemeralPoll = time.Second
+
+	// maxEphemeral caps the failed gpuid ephemeral containers attached to a pod.
+	// They can't be removed, so a pod whose attempts all failed gets no more.
+	maxEphemeral = 3
+)
+
+var (
+	// counterFallback records collections that had to use the ephemeral container path.
+	counterFallback = counter.New("gpuid_collection_fallback_total", "Total number of collections that fell back to an ephemeral debug container", "node", "result")
+)
+
+// runEphemeral attaches an ephemeral container running argv from image to the pod,
+// waits for it to exit and returns its logs. Ephemeral containers cannot be removed
+// once added, so a previous gpuid container on the same pod that succeeded or is
+// still running is reused — GPU identity does not change for the life of the pod.
+// A failed one is not: each retry attaches a new container until the pod carries
+// maxEphemeral failed ones, after which ErrEphemeralExhausted is returned.
+func runEphemeral(ctx context.Context, log *slog.Logger, cs kubernetes.Interface, pod *corev1.Pod, image, target string, argv []string) (string, error) {
+	pods := cs.CoreV1().Pods(pod.Namespace)
+
+	// The informer cache holds a stripped pod; updating ephemeral containers needs the full object.
+	live, err := pods.Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to get pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+
+	name, failed := reusableEphemeral(live)
+	if name == "" {
+		if failed >= maxEphemeral {
+			return "", fmt.Errorf("%w: pod %s/%s has %d failed gpuid ephemeral containers", ErrEphemeralExhausted, pod.Namespace, pod.Name, failed)
+		}
+		name = EphemeralPrefix + rand.String(5)
+		live.Spec.EphemeralContainers = append(live.Spec.EphemeralContainers, ephemeralContainer(name, image, target, argv))
+
+		if _, err := pods.UpdateEphemeralContainers(ctx, pod.Name, live, metav1.UpdateOptions{}); err != nil {
+			return "", fmt.Errorf("failed to add ephemeral container to pod %s/%s: %w", pod.Namespace, pod.Name, err)
+		}
+		log.Info("attached ephemeral collection container", "ns", pod.Namespace, "pod", pod.Name, "container", name, "image", image)
+	} else {
+		log.Debug("reusing ephemeral collection container", "ns", pod.Namespace, "pod", pod.Name, "container", name)
+	}
+
+	var state *corev1.ContainerStateTerminated
+	err = wait.PollUntilContextCancel(ctx, ephemeralPoll, true, func(ctx context.Context) (bool, error) {
+		p, err := pods.Get(ctx, pod.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		s := ephemeralStatus(p, name)
+		if s == nil {
+			return false, nil
+		}
+		if w := s.State.Waiting; w != nil && imagePullFailed(w.Reason) {
+			// The kubelet keeps retrying the pull; fail now rather than at the timeout.
+			return false, fmt.Errorf("%w: %s: %s", ErrImagePull, w.Reason, w.Message)
+		}
+		state = s.State.Terminated
+		return state != nil, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("ephemeral container %s did not complete: %w", name, err)
+	}
+
+	logs, err := pods.GetLogs(pod.Name, &corev1.PodLogOptions{Container: name}).DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read logs of ephemeral container %s: %w", name, err)
+	}
+
+	if state.ExitCode != 0 {
+		err := fmt.Errorf("ephemeral container %s exited with code %d (%s): %s", name, state.ExitCode, state.Reason, strings.TrimSpace(state.Message))
+		if state.ExitCode == 126 || state.ExitCode == 127 {
+			return "", fmt.Errorf("%w: %w", ErrCommandNotFound, err)
+		}
+		return "", fmt.Errorf("%w: %w", ErrDriver, err)
+	}
+
+	// Container logs interleave stderr with stdout, so drop any warnings nvidia-smi
+	// printed ahead of the XML document.
+	return xmlPayload(string(logs)), nil
+}
+
+// ephemeralContainer builds the debug container spec. The NVIDIA container runtime
+// injects the driver and nvidia-smi for the visible devices set in the environment.
+func ephemeralContainer(name, image, target string, argv []string) corev1.EphemeralContainer {
+	return corev1.EphemeralContainer{
+		TargetContainerName: target,
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:                     name,
+			Image:                    image,
+			ImagePullPolicy:          corev1.PullIfNotPresent,
+			Command:                  argv,
+			TerminationMessagePolicy: corev1.TerminationMessageFallbackToLogsOnError,
+			Env: []corev1.EnvVar{
+				{Name: "NVIDIA_VISIBLE_DEVICES", Value: "all"},
+				{Name: "NVIDIA_DRIVER_CAPABILITIES", Value: "utility"},
+			},
+		},
+	}
+}
+
+// reusableEphemeral returns the name of a gpuid ephemeral container that
+// succeeded or has not failed yet, or "" when there is none, along with the
+// number of gpuid containers that failed.
+func reusableEphemeral(p *corev1.Pod) (string, int) {
+	name, failed := "", 0
+	for _, c := range p.Spec.EphemeralContainers {
+		if !strings.HasPrefix(c.Name, EphemeralPrefix) {
+			continue
+		}
+		if s := ephemeralStatus(p, c.Name); s != nil && ephemeralFailed(s) {
+			failed++
+			continue
+		}
+		if name == "" {
+			name = c.Name
+		}
+	}
+	return name, failed
+}
+
+// ephemeralFailed reports whether the container exited non-zero or can't pull its image.
+func ephemeralFailed(s *corev1.ContainerStatus) bool {
+	if t := s.State.Terminated; t != nil {
+		return t.ExitCode != 0
+	}
+	return s.State.Waiting != nil && imagePullFailed(s.State.Waiting.Reason)
+}
+
+// imagePullFailed reports whether a waiting reason means the image can't be pulled.
+func imagePullFailed(reason string) bool {
+	switch reason {
+	case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+		return true
+	}
+	return false
+}
+
+// ephemeralStatus returns the status of the named ephemeral container, if reported.
+func ephemeralStatus(p *corev1.Pod, name string) *corev1.ContainerStatus {
+	for i := range p.Status.EphemeralContainerStatuses {
+		if s := &p.Status.EphemeralContainerStatuses[i]; s.Name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+// xmlPayload trims anything before the XML declaration or first element.
+func xmlPayload(s string) string {
+	for _, marker := range []string{"<?xml", "<nvidia_smi_log"} {
+		if i := strings.Index(s, marker); i >= 0 {
+			return s[i:]
+		}
+	}
+	return s
+}
+
+// collectEphemeral runs the collection in an ephemeral container when the target
+// pod has no usable nvidia-smi and records that the fallback path was taken.
+func collectEphemeral(ctx context.Context, log *slog.Logger, cs kubernetes.Interface, pod *corev1.Pod, opts ExecOptions) (string, error) {
+	argv := opts.Command
+	if len(argv) == 0 {
+		argv = DefaultCommand
+	}
+
+	out, err := runEphemeral(ctx, log, cs, pod, opts.EphemeralImage, opts.Container, argv)
+	if err != nil {
+		counterFallback.Increment(pod.Spec.NodeName, "failure")
+		return "", err
+	}
+
+	counterFallback.Increment(pod.Spec.NodeName, "success")
+	return out, nil
+}
+
+// isEphemeralEligible reports whether a collection error should trigger the fallback.
+func isEphemeralEligible(err error, opts ExecOptions) bool {
+	return opts.EphemeralImage != "" && errors.Is(err, ErrCommandNotFound)
+}
@@ -0,0 +1,194 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testSMIXML = `<?xml version="1.0" ?><nvidia_smi_log><attached_gpus>1</attached_gpus></nvidia_smi_log>`
+
+// ephemeralClient returns a fake clientset that marks any ephemeral container as
+// terminated with exitCode once it has been added, and serves logs as container output.
+func ephemeralClient(pod *corev1.Pod, exitCode int32, logs string) *fake.Clientset {
+	return ephemeralClientWithState(pod, corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: exitCode}}, logs)
+}
+
+// ephemeralClientWithState is ephemeralClient with every ephemeral container in state.
+func ephemeralClientWithState(pod *corev1.Pod, state corev1.ContainerState, logs string) *fake.Clientset {
+	cs := fake.NewClientset(pod)
+
+	cs.PrependReactor("get", "pods", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		if action.GetSubresource() == "log" {
+			return true, &k8sruntime.Unknown{Raw: []byte(logs)}, nil
+		}
+
+		obj, err := cs.Tracker().Get(action.GetResource(), action.GetNamespace(), pod.Name)
+		if err != nil {
+			return true, nil, err
+		}
+		p := obj.(*corev1.Pod).DeepCopy()
+		p.Status.EphemeralContainerStatuses = nil
+		for _, ec := range p.Spec.EphemeralContainers {
+			p.Status.EphemeralContainerStatuses = append(p.Status.EphemeralContainerStatuses, corev1.ContainerStatus{
+				Name:  ec.Name,
+				State: state,
+			})
+		}
+		return true, p, nil
+	})
+
+	return cs
+}
+
+func TestRunEphemeral(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "plugin-abc", Namespace: "gpu-operator"},
+		Spec: corev1.PodSpec{
+			NodeName:   "node-1",
+			Containers: []corev1.Container{{Name: "plugin"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		exitCode int32
+		logs     string
+		want     string
+		wantErr  error
+		// containers on the pod after the second collection
+		wantContainers int
+	}{
+		{name: "success trims stderr preamble", logs: "WARNING: something\n" + testSMIXML, want: testSMIXML, wantContainers: 1},
+		{name: "binary missing in image", exitCode: 127, wantErr: ErrCommandNotFound, wantContainers: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs := ephemeralClient(pod, tt.exitCode, tt.logs)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			got, err := runEphemeral(ctx, slog.Default(), cs, pod, "nvidia/cuda:base", "plugin", DefaultCommand)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("runEphemeral() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("runEphemeral() = %q, want %q", got, tt.want)
+			}
+
+			obj, err := cs.Tracker().Get(corev1.SchemeGroupVersion.WithResource("pods"), pod.Namespace, pod.Name)
+			if err != nil {
+				t.Fatalf("failed to get pod: %v", err)
+			}
+			ecs := obj.(*corev1.Pod).Spec.EphemeralContainers
+			if len(ecs) != 1 {
+				t.Fatalf("expected 1 ephemeral container, got %d", len(ecs))
+			}
+			if !strings.HasPrefix(ecs[0].Name, EphemeralPrefix) || ecs[0].Image != "nvidia/cuda:base" || ecs[0].TargetContainerName != "plugin" {
+				t.Errorf("unexpected ephemeral container: %+v", ecs[0])
+			}
+
+			// A second collection reuses a successful container and attaches a new
+			// one after a failure.
+			if _, err := runEphemeral(ctx, slog.Default(), cs, pod, "nvidia/cuda:base", "plugin", DefaultCommand); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("second runEphemeral() error = %v, want %v", err, tt.wantErr)
+			}
+			obj, _ = cs.Tracker().Get(corev1.SchemeGroupVersion.WithResource("pods"), pod.Namespace, pod.Name)
+			if n := len(obj.(*corev1.Pod).Spec.EphemeralContainers); n != tt.wantContainers {
+				t.Errorf("expected %d ephemeral containers, got %d", tt.wantContainers, n)
+			}
+		})
+	}
+}
+
+func TestRunEphemeralLimits(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "plugin-abc", Namespace: "gpu-operator"},
+		Spec:       corev1.PodSpec{NodeName: "node-1", Containers: []corev1.Container{{Name: "plugin"}}},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	containers := func(cs *fake.Clientset) int {
+		obj, err := cs.Tracker().Get(corev1.SchemeGroupVersion.WithResource("pods"), pod.Namespace, pod.Name)
+		if err != nil {
+			t.Fatalf("failed to get pod: %v", err)
+		}
+		return len(obj.(*corev1.Pod).Spec.EphemeralContainers)
+	}
+
+	// A failing container is replaced until the cap, then the pod is given up on.
+	cs := ephemeralClient(pod, 1, "")
+	for i := range maxEphemeral {
+		if _, err := runEphemeral(ctx, slog.Default(), cs, pod.DeepCopy(), "img", "plugin", DefaultCommand); !errors.Is(err, ErrDriver) {
+			t.Fatalf("attempt %d: runEphemeral() error = %v, want %v", i+1, err, ErrDriver)
+		}
+	}
+	if _, err := runEphemeral(ctx, slog.Default(), cs, pod.DeepCopy(), "img", "plugin", DefaultCommand); !errors.Is(err, ErrEphemeralExhausted) {
+		t.Fatalf("runEphemeral() after %d failures error = %v, want %v", maxEphemeral, err, ErrEphemeralExhausted)
+	}
+	if n := containers(cs); n != maxEphemeral {
+		t.Errorf("expected %d ephemeral containers, got %d", maxEphemeral, n)
+	}
+
+	// An image that can't be pulled fails without waiting for the timeout.
+	cs = ephemeralClientWithState(pod, corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}}, "")
+	if _, err := runEphemeral(ctx, slog.Default(), cs, pod.DeepCopy(), "img", "plugin", DefaultCommand); !errors.Is(err, ErrImagePull) {
+		t.Fatalf("runEphemeral() error = %v, want %v", err, ErrImagePull)
+	}
+	if ctx.Err() != nil {
+		t.Error("image pull failure waited for the timeout")
+	}
+}
+
+func TestIsEphemeralEligible(t *testing.T) {
+	notFound := errors.Join(ErrCommandNotFound, errors.New("exit 127"))
+
+	if isEphemeralEligible(notFound, ExecOptions{}) {
+		t.Error("fallback should be disabled without an image")
+	}
+	if !isEphemeralEligible(notFound, ExecOptions{EphemeralImage: "img"}) {
+		t.Error("fallback should trigger on command not found")
+	}
+	if isEphemeralEligible(errors.New("exit 9"), ExecOptions{EphemeralImage: "img"}) {
+		t.Error("fallback should not trigger on other failures")
+	}
+}
+
+func TestReusableEphemeral(t *testing.T) {
+	p := &corev1.Pod{}
+	add := func(name string, state corev1.ContainerState) {
+		p.Spec.EphemeralContainers = append(p.Spec.EphemeralContainers, corev1.EphemeralContainer{
+			EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: name},
+		})
+		p.Status.EphemeralContainerStatuses = append(p.Status.EphemeralContainerStatuses, corev1.ContainerStatus{Name: name, State: state})
+	}
+	exited := func(code int32) corev1.ContainerState {
+		return corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: code}}
+	}
+
+	if name, failed := reusableEphemeral(p); name != "" || failed != 0 {
+		t.Errorf("reusableEphemeral() = %q, %d for a pod without containers", name, failed)
+	}
+
+	add("debugger", exited(0))
+	add(EphemeralPrefix+"aaaaa", exited(9))
+	add(EphemeralPrefix+"bbbbb", corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ErrImagePull"}})
+	if name, failed := reusableEphemeral(p); name != "" || failed != 2 {
+		t.Errorf("reusableEphemeral() = %q, %d; want no container and 2 failures", name, failed)
+	}
+
+	add(EphemeralPrefix+"ccccc", exited(0))
+	if name, _ := reusableEphemeral(p); name != EphemeralPrefix+"ccccc" {
+		t.Errorf("reusableEphemeral() = %q, want the successful gpuid container", name)
+	}
+}
@@ -0,0 +1,252 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+const (
+	// TransportAuto tries the WebSocket exec protocol first and falls back to SPDY
+	// when the API server or an intermediate proxy rejects the upgrade.
+	TransportAuto = "auto"
+	// TransportWebSocket uses only the WebSocket exec protocol.
+	TransportWebSocket = "websocket"
+	// TransportSPDY uses only the legacy SPDY exec protocol.
+	TransportSPDY = "spdy"
+)
+
+var (
+	// DefaultCommand is the collection argv; it is executed directly, without a shell.
+	DefaultCommand = []string{"nvidia-smi", "-q", "-x"}
+
+	// ErrCommandNotFound indicates the collection binary is not present in the container.
+	ErrCommandNotFound = errors.New("collection command not found")
+
+	// ErrDriver indicates the collection binary ran but exited non-zero, typically
+	// because the NVIDIA driver is not loaded or the GPU is in a failed state.
+	ErrDriver = errors.New("collection command failed")
+
+	// ErrParse indicates the collection output could not be parsed.
+	ErrParse = errors.New("failed to parse collection output")
+
+	// ErrImagePull indicates the ephemeral collection container's image could not be pulled.
+	ErrImagePull = errors.New("failed to pull ephemeral container image")
+
+	// ErrEphemeralExhausted indicates the pod already carries the maximum number of
+	// failed ephemeral collection containers, so no more are attached.
+	ErrEphemeralExhausted = errors.New("ephemeral collection attempts exhausted")
+
+	// counterTransport records which exec protocol actually carried each stream.
+	counterTransport = counter.New("gpuid_exec_transport_total", "Total number of pod exec streams by transport used", "transport")
+)
+
+// ExecOptions configures how commands are executed inside the target container.
+type ExecOptions struct {
+	Container   string   // Container name within the pod
+	AutoDetect  bool     // Also try the pod's other containers when Container lacks the command
+	Command     []string // Collection argv (empty = DefaultCommand)
+	SearchPaths []string // Alternate absolute paths for Command[0], tried in order
+	Transport   string   // Exec transport: auto, websocket or spdy (empty = auto)
+
+	// EphemeralImage, when set, enables the fallback that runs Command in an ephemeral
+	// debug container from this image if no container in the pod has the binary.
+	EphemeralImage string
+}
+
+// execFunc runs argv in a container of the target pod and returns its stdout.
+type execFunc func(ctx context.Context, container string, argv []string) (string, error)
+
+// collect runs the collection command in the target pod, trying each candidate
+// container and binary path until one is found.
+func collect(ctx context.Context, log *slog.Logger, cfg *rest.Config, cs *kubernetes.Clientset, pod *corev1.Pod, opts ExecOptions) (string, error) {
+	return collectWith(ctx, log, pod, opts, func(ctx context.Context, container string, argv []string) (string, error) {
+		return execCommand(ctx, log, cfg, cs, pod, container, argv, opts.Transport)
+	})
+}
+
+// collectWith walks containers x binaries and returns the first output that is not
+// a missing-binary failure. Any other error (non-zero exit, transport) is returned
+// as is, since the binary exists and trying elsewhere would mask the real problem.
+func collectWith(ctx context.Context, log *slog.Logger, pod *corev1.Pod, opts ExecOptions, run execFunc) (string, error) {
+	argv := opts.Command
+	if len(argv) == 0 {
+		argv = DefaultCommand
+	}
+
+	var lastErr error
+	for _, container := range candidateContainers(pod, opts.Container, opts.AutoDetect) {
+		for _, bin := range candidateBinaries(argv[0], opts.SearchPaths) {
+			cmd := append([]string{bin}, argv[1:]...)
+			out, err := run(ctx, container, cmd)
+			if err == nil {
+				if container != opts.Container || bin != argv[0] {
+					log.Debug("collection command resolved", "ns", pod.Namespace, "pod", pod.Name, "container", container, "command", bin)
+				}
+				return out, nil
+			}
+			if !errors.Is(err, ErrCommandNotFound) {
+				return out, err
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+		}
+	}
+
+	if lastErr == nil {
+		return "", fmt.Errorf("%w: no container to run %s in", ErrCommandNotFound, argv[0])
+	}
+	return "", lastErr
+}
+
+// candidateContainers returns the configured container first followed, when
+// autoDetect is set, by the pod's remaining containers in spec order.
+func candidateContainers(pod *corev1.Pod, container string, autoDetect bool) []string {
+	out := []string{container}
+	if !autoDetect {
+		return out
+	}
+	for _, c := range pod.Spec.Containers {
+		if c.Name != container {
+			out = append(out, c.Name)
+		}
+	}
+	return out
+}
+
+// candidateBinaries returns bin followed by each search path. Search paths are
+// only used for a bare command name; an explicit path is taken as is.
+func candidateBinaries(bin string, searchPaths []string) []string {
+	out := []string{bin}
+	if strings.Contains(bin, "/") {
+		return out
+	}
+	seen := map[string]bool{bin: true}
+	for _, p := range searchPaths {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		// A directory entry means <dir>/<bin>.
+		if path.Base(p) != bin {
+			p = path.Join(p, bin)
+		}
+		if seen[p] {
+			continue
+		}
+		seen[p] = true
+		out = append(out, p)
+	}
+	return out
+}
+
+// isNotFound reports whether an exec failure means the binary is missing or not
+// executable: the runtime exits 126/127 or rejects the exec before starting it.
+func isNotFound(err error, stderr string) bool {
+	var exitError utilexec.ExitError
+	if errors.As(err, &exitError) {
+		// Any other exit code came from the binary itself, e.g. a driver error
+		// that happens to mention a missing /dev node.
+		code := exitError.ExitStatus()
+		return code == 126 || code == 127
+	}
+	// A missing file alone is not enough: transport errors such as a failed
+	// socket dial also report it. Only trust it inside a runtime exec rejection.
+	msg := strings.ToLower(err.Error() + " " + stderr)
+	if strings.Contains(msg, "executable file not found") {
+		return true
+	}
+	return strings.Contains(msg, "exec failed") && strings.Contains(msg, "no such file or directory")
+}
+
+// ValidTransport reports whether t is a supported exec transport.
+func ValidTransport(t string) bool {
+	switch t {
+	case "", TransportAuto, TransportWebSocket, TransportSPDY:
+		return true
+	default:
+		return false
+	}
+}
+
+// trackedExecutor records the transport name of the last executor that streamed,
+// so the transport picked by a fallback executor can be reported after the fact.
+type trackedExecutor struct {
+	remotecommand.Executor
+	transport string
+	used      *string
+}
+
+func (t *trackedExecutor) StreamWithContext(ctx context.Context, opts remotecommand.StreamOptions) error {
+	*t.used = t.transport
+	return t.Executor.StreamWithContext(ctx, opts)
+}
+
+// newExecutor creates the executor for the requested transport. The returned
+// string pointer holds the transport that served the stream once it has run.
+func newExecutor(cfg *rest.Config, u *url.URL, transport string) (remotecommand.Executor, *string, error) {
+	used := new(string)
+
+	spdy := func() (remotecommand.Executor, error) {
+		e, err := remotecommand.NewSPDYExecutor(cfg, http.MethodPost, u)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create SPDY executor: %w", err)
+		}
+		return &trackedExecutor{Executor: e, transport: TransportSPDY, used: used}, nil
+	}
+
+	websocket := func() (remotecommand.Executor, error) {
+		// WebSocket exec is a GET upgrade, unlike the SPDY POST.
+		e, err := remotecommand.NewWebSocketExecutor(cfg, http.MethodGet, u.String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to create WebSocket executor: %w", err)
+		}
+		return &trackedExecutor{Executor: e, transport: TransportWebSocket, used: used}, nil
+	}
+
+	switch transport {
+	case TransportSPDY:
+		e, err := spdy()
+		return e, used, err
+	case TransportWebSocket:
+		e, err := websocket()
+		return e, used, err
+	case "", TransportAuto:
+		primary, err := websocket()
+		if err != nil {
+			return nil, nil, err
+		}
+		secondary, err := spdy()
+		if err != nil {
+			return nil, nil, err
+		}
+		e, err := remotecommand.NewFallbackExecutor(primary, secondary, shouldFallback)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create fallback executor: %w", err)
+		}
+		return e, used, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported exec transport: %q", transport)
+	}
+}
+
+// shouldFallback mirrors kubectl: fall back to SPDY only when the WebSocket
+// upgrade itself was refused, never after the command has started running.
+func shouldFallback(err error) bool {
+	return httpstream.IsUpgradeFailure(err) || httpstream.IsHTTPSProxyError(err)
+}
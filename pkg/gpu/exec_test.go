@@ -0,0 +1,246 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/httpstream"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// fakeExecutor returns err from every stream.
+type fakeExecutor struct {
+	err   error
+	calls int
+}
+
+func (f *fakeExecutor) Stream(_ remotecommand.StreamOptions) error {
+	f.calls++
+	return f.err
+}
+
+func (f *fakeExecutor) StreamWithContext(_ context.Context, _ remotecommand.StreamOptions) error {
+	f.calls++
+	return f.err
+}
+
+func TestNewExecutor(t *testing.T) {
+	cfg := &rest.Config{Host: "https://127.0.0.1:6443"}
+	u, err := url.Parse("https://127.0.0.1:6443/api/v1/namespaces/default/pods/p/exec")
+	if err != nil {
+		t.Fatalf("failed to parse url: %v", err)
+	}
+
+	tests := []struct {
+		transport string
+		wantErr   bool
+	}{
+		{transport: "", wantErr: false},
+		{transport: TransportAuto, wantErr: false},
+		{transport: TransportWebSocket, wantErr: false},
+		{transport: TransportSPDY, wantErr: false},
+		{transport: "http2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.transport, func(t *testing.T) {
+			e, used, err := newExecutor(cfg, u, tt.transport)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newExecutor() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if e == nil || used == nil {
+				t.Fatal("expected executor and transport holder")
+			}
+			if !ValidTransport(tt.transport) {
+				t.Errorf("ValidTransport(%q) = false", tt.transport)
+			}
+		})
+	}
+}
+
+func TestFallbackRecordsTransport(t *testing.T) {
+	upgradeErr := &httpstream.UpgradeFailureError{Cause: errors.New("websocket not supported")}
+
+	tests := []struct {
+		name       string
+		primaryErr error
+		want       string
+		wantSPDY   int
+	}{
+		{name: "websocket succeeds", primaryErr: nil, want: TransportWebSocket, wantSPDY: 0},
+		{name: "upgrade refused", primaryErr: upgradeErr, want: TransportSPDY, wantSPDY: 1},
+		{name: "command failed", primaryErr: errors.New("exit 1"), want: TransportWebSocket, wantSPDY: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used := new(string)
+			ws := &fakeExecutor{err: tt.primaryErr}
+			sp := &fakeExecutor{}
+
+			e, err := remotecommand.NewFallbackExecutor(
+				&trackedExecutor{Executor: ws, transport: TransportWebSocket, used: used},
+				&trackedExecutor{Executor: sp, transport: TransportSPDY, used: used},
+				shouldFallback,
+			)
+			if err != nil {
+				t.Fatalf("failed to create fallback executor: %v", err)
+			}
+
+			_ = e.StreamWithContext(context.Background(), remotecommand.StreamOptions{})
+
+			if *used != tt.want {
+				t.Errorf("transport = %q, want %q", *used, tt.want)
+			}
+			if sp.calls != tt.wantSPDY {
+				t.Errorf("spdy calls = %d, want %d", sp.calls, tt.wantSPDY)
+			}
+		})
+	}
+}
+
+func TestCandidateBinaries(t *testing.T) {
+	got := candidateBinaries("nvidia-smi", []string{"/usr/local/nvidia/bin/nvidia-smi", "/opt/bin", "", "/opt/bin/nvidia-smi"})
+	want := []string{"nvidia-smi", "/usr/local/nvidia/bin/nvidia-smi", "/opt/bin/nvidia-smi"}
+	if len(got) != len(want) {
+		t.Fatalf("candidateBinaries() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("candidateBinaries()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	if got := candidateBinaries("/usr/bin/nvidia-smi", []string{"/usr/local/nvidia/bin"}); len(got) != 1 {
+		t.Errorf("explicit path should not be searched, got %v", got)
+	}
+}
+
+func TestCollectWith(t *testing.T) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "ns"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "plugin"}, {Name: "toolkit"}},
+		},
+	}
+	notFound := fmt.Errorf("%w: nvidia-smi", ErrCommandNotFound)
+
+	tests := []struct {
+		name      string
+		opts      ExecOptions
+		found     string // container/binary that has the command
+		other     error  // non-not-found error returned by every exec
+		want      string
+		wantErr   error
+		wantCalls int
+	}{
+		{
+			name:      "default command in configured container",
+			opts:      ExecOptions{Container: "plugin"},
+			found:     "plugin/nvidia-smi",
+			want:      "plugin/nvidia-smi",
+			wantCalls: 1,
+		},
+		{
+			name:      "search path",
+			opts:      ExecOptions{Container: "plugin", SearchPaths: []string{"/usr/local/nvidia/bin/nvidia-smi"}},
+			found:     "plugin//usr/local/nvidia/bin/nvidia-smi",
+			want:      "plugin//usr/local/nvidia/bin/nvidia-smi",
+			wantCalls: 2,
+		},
+		{
+			name:      "auto detect container",
+			opts:      ExecOptions{Container: "plugin", AutoDetect: true},
+			found:     "toolkit/nvidia-smi",
+			want:      "toolkit/nvidia-smi",
+			wantCalls: 2,
+		},
+		{
+			name:      "not found without auto detect",
+			opts:      ExecOptions{Container: "plugin"},
+			found:     "toolkit/nvidia-smi",
+			wantErr:   ErrCommandNotFound,
+			wantCalls: 1,
+		},
+		{
+			name:      "other error stops search",
+			opts:      ExecOptions{Container: "plugin", AutoDetect: true},
+			other:     errors.New("exit code 9"),
+			wantCalls: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			run := func(_ context.Context, container string, argv []string) (string, error) {
+				calls++
+				if tt.other != nil {
+					return "", tt.other
+				}
+				if container+"/"+argv[0] == tt.found {
+					if len(argv) != 3 || argv[1] != "-q" || argv[2] != "-x" {
+						t.Errorf("unexpected argv: %v", argv)
+					}
+					return tt.found, nil
+				}
+				return "", notFound
+			}
+
+			got, err := collectWith(context.Background(), slog.Default(), pod, tt.opts, run)
+			switch {
+			case tt.other != nil:
+				if !errors.Is(err, tt.other) {
+					t.Errorf("collectWith() error = %v, want %v", err, tt.other)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("collectWith() error = %v, want %v", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Fatalf("collectWith() unexpected error: %v", err)
+			case got != tt.want:
+				t.Errorf("collectWith() = %q, want %q", got, tt.want)
+			}
+			if calls != tt.wantCalls {
+				t.Errorf("exec calls = %d, want %d", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		stderr string
+		want   bool
+	}{
+		{name: "exit 127", err: utilexec.CodeExitError{Err: errors.New("x"), Code: 127}, want: true},
+		{name: "exit 126", err: utilexec.CodeExitError{Err: errors.New("x"), Code: 126}, want: true},
+		{name: "driver error", err: utilexec.CodeExitError{Err: errors.New("x"), Code: 9}, stderr: "/dev/nvidiactl: no such file or directory", want: false},
+		{name: "runtime rejection", err: errors.New(`exec: "nvidia-smi": executable file not found in $PATH`), want: true},
+		{name: "missing path", err: errors.New(`command terminated with non-zero exit code: OCI runtime exec failed: exec failed: unable to start container process: exec: "/usr/bin/nvidia-smi": stat /usr/bin/nvidia-smi: no such file or directory: unknown`), want: true},
+		{name: "transport", err: errors.New("connection reset"), want: false},
+		{name: "transport missing socket", err: errors.New("error dialing backend: dial unix /var/run/crio/crio.sock: connect: no such file or directory"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isNotFound(tt.err, tt.stderr); got != tt.want {
+				t.Errorf("isNotFound() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
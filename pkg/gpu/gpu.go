@@ -6,7 +6,6 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
 	"sort"
 
 	corev1 "k8s.io/api/core/v1"
@@ -21,14 +20,44 @@ import (
 type Serials struct {
 	Chassis string   `json:"chassis" yaml:"chassis"`
 	GPU     []string `json:"gpu" yaml:"gpu"`
+
+	// Devices lists every GPU on the chassis, one per UUID. Unlike GPU it is not
+	// deduplicated by serial: GB200 dies on the same module share a serial.
+	Devices []Device `json:"devices,omitempty" yaml:"devices,omitempty"`
+
+	// Driver is the NVIDIA driver version reported alongside the devices.
+	Driver string `json:"driver,omitempty" yaml:"driver,omitempty"`
+
+	// Attached is the number of GPUs the driver reports on the whole node. When it
+	// exceeds the devices read across all chassis, GPUs are missing.
+	Attached int `json:"-" yaml:"-"`
+}
+
+// Device is the identity of a single GPU as reported by nvidia-smi.
+type Device struct {
+	Serial       string `json:"serial" yaml:"serial"`
+	UUID         string `json:"uuid" yaml:"uuid"`
+	Model        string `json:"model" yaml:"model"`
+	Architecture string `json:"architecture,omitempty" yaml:"architecture,omitempty"`
+	BusID        string `json:"busId,omitempty" yaml:"busId,omitempty"`
+	ModuleID     string `json:"moduleId,omitempty" yaml:"moduleId,omitempty"`
+	MIGMode      string `json:"migMode,omitempty" yaml:"migMode,omitempty"`
+
+	// Issues lists health problems reported for the GPU (see the Issue constants).
+	Issues []string `json:"issues,omitempty" yaml:"issues,omitempty"`
 }
 
 // GetSerialNumbers retrieves unique GPU serial numbers from a specified pod and container.
-// It executes the `nvidia-smi -q -x` command inside the container, parses the XML output,
-// and extracts the serial numbers of all GPUs present. The function ensures that only
-// unique serial numbers are returned, handling any duplicates that may arise.
-func GetSerialNumbers(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, cfg *rest.Config, pod *corev1.Pod, container string) ([]*Serials, error) {
-	stdout, err := execShell(ctx, log, cfg, cs, pod, container)
+// It executes the configured collection command (`nvidia-smi -q -x` by default) inside the
+// container without a shell, parses the XML output, and extracts the serial numbers of all
+// GPUs present. The function ensures that only unique serial numbers are returned, handling
+// any duplicates that may arise.
+func GetSerialNumbers(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, cfg *rest.Config, pod *corev1.Pod, opts ExecOptions) ([]*Serials, error) {
+	stdout, err := collect(ctx, log, cfg, cs, pod, opts)
+	if isEphemeralEligible(err, opts) {
+		log.Info("collection command not found in pod, falling back to ephemeral container", "ns", pod.Namespace, "pod", pod.Name, "err", err)
+		stdout, err = collectEphemeral(ctx, log, cs, pod, opts)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to execute command in pod %s/%s: %w", pod.Namespace, pod.Name, err)
 	}
@@ -36,52 +65,77 @@ func GetSerialNumbers(ctx context.Context, log *slog.Logger, cs *kubernetes.Clie
 	// parse output
 	d, err := parseSMIDevice([]byte(stdout))
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse nvidia-smi output: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrParse, err)
 	}
 
 	log.Debug("gpu info", "ns", pod.Namespace, "pod", pod.Name, "gpu_count", len(d.GPUs))
 
-	// spoole through GPUs and group them by chassis serial number
-	unitMap := make(map[string]map[string]bool)
-	for _, gpu := range d.GPUs {
-		if unitMap[gpu.PlatformInfo.ChassisSerialNumber] == nil {
-			unitMap[gpu.PlatformInfo.ChassisSerialNumber] = make(map[string]bool)
-		}
+	units := groupSerials(d)
 
-		if unitMap[gpu.PlatformInfo.ChassisSerialNumber][gpu.Serial] {
-			continue
-		}
+	log.Debug("gpu serial numbers", "ns", pod.Namespace, "pod", pod.Name, "serial_numbers", len(units))
 
-		unitMap[gpu.PlatformInfo.ChassisSerialNumber][gpu.Serial] = true
-	}
+	return units, nil
+}
 
-	units := make([]*Serials, 0)
+// ParseSerials parses nvidia-smi XML output and groups the GPUs by chassis, as
+// GetSerialNumbers does with the output collected from a pod.
+func ParseSerials(data []byte) ([]*Serials, error) {
+	d, err := parseSMIDevice(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrParse, err)
+	}
+	return groupSerials(d), nil
+}
 
-	// convert map to slice
-	for chassis, gpus := range unitMap {
-		s := &Serials{
-			Chassis: chassis,
-			GPU:     make([]string, 0, len(gpus)),
+// groupSerials groups the GPUs in d by chassis serial number, deduplicating GPU
+// serials and collecting the per-UUID device identities.
+func groupSerials(d *NVSMIDevice) []*Serials {
+	unitMap := make(map[string]*Serials)
+	seen := make(map[string]map[string]bool)
+	for _, g := range d.GPUs {
+		chassis := g.PlatformInfo.ChassisSerialNumber
+		s, ok := unitMap[chassis]
+		if !ok {
+			s = &Serials{Chassis: chassis, GPU: make([]string, 0), Driver: d.DriverVersion, Attached: attachedGPUs(d)}
+			unitMap[chassis] = s
+			seen[chassis] = make(map[string]bool)
 		}
 
-		for gpu := range gpus {
-			s.GPU = append(s.GPU, gpu)
+		s.Devices = append(s.Devices, Device{
+			Serial:       g.Serial,
+			UUID:         g.UUID,
+			Model:        g.ProductName,
+			Architecture: g.ProductArchitecture,
+			BusID:        g.Pci.PciBusID,
+			ModuleID:     g.PlatformInfo.ModuleID,
+			MIGMode:      g.MigMode.CurrentMig,
+			Issues:       deviceIssues(g),
+		})
+
+		if seen[chassis][g.Serial] {
+			continue
 		}
+		seen[chassis][g.Serial] = true
+		s.GPU = append(s.GPU, g.Serial)
+	}
 
+	units := make([]*Serials, 0, len(unitMap))
+	for _, s := range unitMap {
 		sort.Strings(s.GPU)
+		sort.Slice(s.Devices, func(i, j int) bool { return s.Devices[i].UUID < s.Devices[j].UUID })
 		units = append(units, s)
 	}
 
-	log.Debug("gpu serial numbers", "ns", pod.Namespace, "pod", pod.Name, "serial_numbers", len(units))
-
-	return units, nil
+	return units
 }
 
-// execShell executes a shell command in a pod container using the Kubernetes exec API.
+// execCommand runs argv (no shell) in a pod container using the Kubernetes exec API
+// over the transport selected in opts.
 // nvidia-smi can emit benign warnings to stderr (driver mismatches, MIG hints) while
 // stdout still contains valid XML, so stderr alone does not signal failure — only a
-// non-zero exit code or a transport error is fatal.
-func execShell(ctx context.Context, log *slog.Logger, cfg *rest.Config, cs *kubernetes.Clientset, pod *corev1.Pod, container string) (string, error) {
+// non-zero exit code or a transport error is fatal. A missing binary is reported as
+// ErrCommandNotFound so callers can move on to the next candidate.
+func execCommand(ctx context.Context, log *slog.Logger, cfg *rest.Config, cs *kubernetes.Clientset, pod *corev1.Pod, container string, argv []string, transportName string) (string, error) {
 	req := cs.CoreV1().RESTClient().
 		Post().
 		Namespace(pod.Namespace).
@@ -90,16 +144,16 @@ func execShell(ctx context.Context, log *slog.Logger, cfg *rest.Config, cs *kube
 		SubResource("exec").
 		VersionedParams(&corev1.PodExecOptions{
 			Container: container,
-			Command:   []string{"/bin/sh", "-c", "nvidia-smi -q -x"},
+			Command:   argv,
 			Stdin:     false,
 			Stdout:    true,
 			Stderr:    true,
 			TTY:       false,
 		}, scheme.ParameterCodec)
 
-	executor, err := remotecommand.NewSPDYExecutor(cfg, http.MethodPost, req.URL())
+	executor, transport, err := newExecutor(cfg, req.URL(), transportName)
 	if err != nil {
-		return "", fmt.Errorf("failed to create SPDY executor: %w", err)
+		return "", err
 	}
 
 	var stdout, stderr bytes.Buffer
@@ -109,20 +163,29 @@ func execShell(ctx context.Context, log *slog.Logger, cfg *rest.Config, cs *kube
 		Tty:    false,
 	})
 
+	if *transport != "" {
+		counterTransport.Increment(*transport)
+		log.Debug("exec transport", "ns", pod.Namespace, "pod", pod.Name, "transport", *transport)
+	}
+
 	stdoutStr := stdout.String()
 	stderrStr := stderr.String()
 
 	if stderrStr != "" {
-		log.Debug("nvidia-smi stderr", "ns", pod.Namespace, "pod", pod.Name, "stderr", stderrStr)
+		log.Debug("nvidia-smi stderr", "ns", pod.Namespace, "pod", pod.Name, "container", container, "stderr", stderrStr)
 	}
 
 	if err == nil {
 		return stdoutStr, nil
 	}
 
+	if isNotFound(err, stderrStr) {
+		return stdoutStr, fmt.Errorf("%w: %s in container %s (stderr=%q): %w", ErrCommandNotFound, argv[0], container, stderrStr, err)
+	}
+
 	var exitError utilexec.ExitError
 	if errors.As(err, &exitError) {
-		return stdoutStr, fmt.Errorf("command failed with exit code %d (stderr=%q): %w", exitError.ExitStatus(), stderrStr, err)
+		return stdoutStr, fmt.Errorf("%w: exit code %d (stderr=%q): %w", ErrDriver, exitError.ExitStatus(), stderrStr, err)
 	}
 
 	return stdoutStr, fmt.Errorf("execution stream error: %w", err)
@@ -0,0 +1,59 @@
+package gpu
+
+import (
+	"strconv"
+	"strings"
+)
+
+// GPU health issues reported in Device.Issues.
+const (
+	IssueUncorrectableECC  = "uncorrectable_ecc"       // volatile uncorrectable SRAM or DRAM ECC errors
+	IssuePendingRemap      = "pending_row_remap"       // row remap pending, needs a GPU reset
+	IssueRemapFailure      = "row_remap_failure"       // row remapping failed
+	IssuePendingRetirement = "pending_page_retirement" // page retirement or blacklist pending, needs a reset
+	IssueRecoveryAction    = "recovery_action"         // driver requests a recovery action (reset, reboot, ...)
+)
+
+// deviceIssues returns the health issues nvidia-smi reports for g, or nil when the
+// GPU is healthy. Fields reported as N/A (unsupported) are ignored.
+func deviceIssues(g GPU) []string {
+	var issues []string
+
+	v := g.EccErrors.Volatile
+	if positive(v.SramUncorrectableParity) || positive(v.SramUncorrectableSecded) || positive(v.DramUncorrectable) {
+		issues = append(issues, IssueUncorrectableECC)
+	}
+	if yes(g.RemappedRows.RemappedRowPending) {
+		issues = append(issues, IssuePendingRemap)
+	}
+	if yes(g.RemappedRows.RemappedRowFailure) {
+		issues = append(issues, IssueRemapFailure)
+	}
+	if yes(g.RetiredPages.PendingRetirement) || yes(g.RetiredPages.PendingBlacklist) {
+		issues = append(issues, IssuePendingRetirement)
+	}
+	if a := strings.TrimSpace(g.GpuRecoveryAction); a != "" && a != "None" && a != "N/A" {
+		issues = append(issues, IssueRecoveryAction)
+	}
+
+	return issues
+}
+
+// attachedGPUs returns the number of GPUs the driver reports as attached, or 0 if
+// it is not reported.
+func attachedGPUs(d *NVSMIDevice) int {
+	n, err := strconv.Atoi(strings.TrimSpace(d.AttachedGpus))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func positive(s string) bool {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	return err == nil && n > 0
+}
+
+func yes(s string) bool {
+	return strings.EqualFold(strings.TrimSpace(s), "yes")
+}
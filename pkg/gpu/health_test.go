@@ -0,0 +1,71 @@
+package gpu
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestDeviceIssues(t *testing.T) {
+	tests := []struct {
+		name string
+		gpu  GPU
+		want []string
+	}{
+		{
+			name: "healthy",
+			gpu: GPU{
+				GpuRecoveryAction: "None",
+				RetiredPages:      RetiredPages{PendingRetirement: "N/A", PendingBlacklist: "N/A"},
+				RemappedRows:      RemappedRows{RemappedRowPending: "No", RemappedRowFailure: "No"},
+			},
+		},
+		{
+			name: "uncorrectable ECC",
+			gpu:  GPU{EccErrors: EccErrors{Volatile: Volatile{DramUncorrectable: "2", SramUncorrectableParity: "N/A"}}},
+			want: []string{IssueUncorrectableECC},
+		},
+		{
+			name: "pending remap and retirement",
+			gpu: GPU{
+				RetiredPages: RetiredPages{PendingRetirement: "Yes"},
+				RemappedRows: RemappedRows{RemappedRowPending: "Yes"},
+			},
+			want: []string{IssuePendingRemap, IssuePendingRetirement},
+		},
+		{
+			name: "recovery action",
+			gpu:  GPU{GpuRecoveryAction: "Node Reboot Required"},
+			want: []string{IssueRecoveryAction},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := deviceIssues(tt.gpu); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("deviceIssues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthFromFixture(t *testing.T) {
+	data, err := os.ReadFile("../../etc/gpus/h100.xml")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	d, err := parseSMIDevice(data)
+	if err != nil {
+		t.Fatalf("failed to parse: %v", err)
+	}
+
+	units := groupSerials(d)
+	if units[0].Attached != 8 {
+		t.Errorf("expected 8 attached GPUs, got %d", units[0].Attached)
+	}
+	for _, dev := range units[0].Devices {
+		if len(dev.Issues) > 0 {
+			t.Errorf("unexpected issues on healthy fixture GPU %s: %v", dev.UUID, dev.Issues)
+		}
+	}
+}
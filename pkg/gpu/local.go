@@ -0,0 +1,46 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os/exec"
+	"strings"
+)
+
+// GetLocalSerialNumbers runs the collection command on this host rather than in a
+// pod, for agent mode where gpuid runs on the GPU node itself, and parses its
+// output. opts.Command and opts.SearchPaths are honored; the container and
+// transport options don't apply.
+func GetLocalSerialNumbers(ctx context.Context, log *slog.Logger, opts ExecOptions) ([]*Serials, error) {
+	argv := opts.Command
+	if len(argv) == 0 {
+		argv = DefaultCommand
+	}
+
+	var lastErr error
+	for _, bin := range candidateBinaries(argv[0], opts.SearchPaths) {
+		var stdout, stderr strings.Builder
+		c := exec.CommandContext(ctx, bin, argv[1:]...)
+		c.Stdout, c.Stderr = &stdout, &stderr
+
+		err := c.Run()
+		if err == nil {
+			if bin != argv[0] {
+				log.Debug("collection command resolved", "command", bin)
+			}
+			return ParseSerials([]byte(stdout.String()))
+		}
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+			lastErr = fmt.Errorf("%w: %s: %w", ErrCommandNotFound, bin, err)
+			continue
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("%w: %s: %w: %s", ErrDriver, bin, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil, lastErr
+}
@@ -0,0 +1,58 @@
+package gpu
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGetLocalSerialNumbers(t *testing.T) {
+	xmlPath, err := filepath.Abs("../../etc/gpus/h100.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "fake-smi")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat "+xmlPath+"\n"), 0o755); err != nil { //nolint:gosec // G306: test script must be executable
+		t.Fatal(err)
+	}
+	failing := filepath.Join(dir, "failing-smi")
+	if err := os.WriteFile(failing, []byte("#!/bin/sh\necho 'driver not loaded' >&2\nexit 9\n"), 0o755); err != nil { //nolint:gosec // G306: test script must be executable
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	log := slog.Default()
+
+	t.Run("search path", func(t *testing.T) {
+		serials, err := GetLocalSerialNumbers(ctx, log, ExecOptions{Command: []string{"fake-smi", "-q", "-x"}, SearchPaths: []string{dir}})
+		if err != nil {
+			t.Fatalf("GetLocalSerialNumbers() error = %v", err)
+		}
+		var devices int
+		for _, s := range serials {
+			devices += len(s.Devices)
+		}
+		if devices != 8 {
+			t.Errorf("expected 8 devices, got %d", devices)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := GetLocalSerialNumbers(ctx, log, ExecOptions{Command: []string{"gpuid-missing-smi"}})
+		if !errors.Is(err, ErrCommandNotFound) {
+			t.Errorf("expected ErrCommandNotFound, got %v", err)
+		}
+	})
+
+	t.Run("driver error", func(t *testing.T) {
+		_, err := GetLocalSerialNumbers(ctx, log, ExecOptions{Command: []string{failing}})
+		if !errors.Is(err, ErrDriver) {
+			t.Errorf("expected ErrDriver, got %v", err)
+		}
+	})
+}
@@ -67,3 +67,48 @@ func TestParseNvidiaSMILogs(t *testing.T) {
 		}
 	}
 }
+
+func TestGroupSerials(t *testing.T) {
+	tests := []struct {
+		file    string
+		chassis int
+		serials int
+		devices int
+	}{
+		{file: "../../etc/gpus/h100.xml", chassis: 1, serials: 8, devices: 8},
+		// GB200: dual-die modules share a serial, so devices outnumber serials.
+		{file: "../../etc/gpus/gb200.xml", chassis: 1, serials: 2, devices: 4},
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			data, err := os.ReadFile(test.file)
+			if err != nil {
+				t.Fatalf("failed to read %s file: %v", test.file, err)
+			}
+			d, err := parseSMIDevice(data)
+			if err != nil {
+				t.Fatalf("failed to parse: %v", err)
+			}
+
+			units := groupSerials(d)
+			if len(units) != test.chassis {
+				t.Fatalf("expected %d chassis, got %d", test.chassis, len(units))
+			}
+			if len(units[0].GPU) != test.serials {
+				t.Errorf("expected %d serials, got %d", test.serials, len(units[0].GPU))
+			}
+			if units[0].Driver == "" {
+				t.Error("expected driver version")
+			}
+			if len(units[0].Devices) != test.devices {
+				t.Errorf("expected %d devices, got %d", test.devices, len(units[0].Devices))
+			}
+			for _, dev := range units[0].Devices {
+				if dev.UUID == "" || dev.Serial == "" || dev.Model == "" || dev.Architecture == "" || dev.MIGMode == "" {
+					t.Errorf("incomplete device: %+v", dev)
+				}
+			}
+		})
+	}
+}
@@ -2,9 +2,15 @@ package gpu
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// ReadingRemoved marks a SerialNumberReading written when the node the GPU was
+// labeled on was deleted, ending its residency on that node.
+const ReadingRemoved = "removed"
+
 // SerialNumberReading represents a single reading of a GPU serial number associated with a pod.
 type SerialNumberReading struct {
 	Cluster string    `json:"cluster" yaml:"cluster"`
@@ -14,6 +20,7 @@ type SerialNumberReading struct {
 	Chassis string    `json:"chassis" yaml:"chassis"`
 	GPU     string    `json:"gpu" yaml:"gpu"`
 	Time    time.Time `json:"time" yaml:"time"`
+	Event   string    `json:"event,omitempty" yaml:"event,omitempty"` // ReadingRemoved, or empty for a scan
 }
 
 // Slice returns the SerialNumberReading fields as a slice of strings for CSV serialization.
@@ -49,5 +56,74 @@ func (r *SerialNumberReading) Validate() error {
 	if r.Time.IsZero() {
 		return fmt.Errorf("time is required")
 	}
+	if r.Event != "" && r.Event != ReadingRemoved {
+		return fmt.Errorf("unknown reading event %q", r.Event)
+	}
+	return nil
+}
+
+// AttributionRecord ties a workload pod to the GPUs of the node it ran on. A
+// record is written when the pod starts running (End is zero) and again when it
+// finishes, so the latest record for a UID is authoritative.
+type AttributionRecord struct {
+	Cluster   string    `json:"cluster" yaml:"cluster"`
+	Namespace string    `json:"namespace" yaml:"namespace"`
+	Pod       string    `json:"pod" yaml:"pod"`
+	UID       string    `json:"uid" yaml:"uid"`
+	Owner     string    `json:"owner,omitempty" yaml:"owner,omitempty"` // Kind/name of the controlling owner
+	Node      string    `json:"node" yaml:"node"`
+	Machine   string    `json:"machine" yaml:"machine"`
+	Chassis   []string  `json:"chassis,omitempty" yaml:"chassis,omitempty"`
+	GPUs      []string  `json:"gpus" yaml:"gpus"`
+	Requested int64     `json:"requested" yaml:"requested"` // GPUs requested by the pod
+	Exact     bool      `json:"exact" yaml:"exact"`         // GPUs are the ones allocated to the pod, not every GPU on the node
+	Start     time.Time `json:"start" yaml:"start"`
+	End       time.Time `json:"end,omitzero" yaml:"end,omitempty"`
+}
+
+// Slice returns the AttributionRecord fields as a slice of strings for CSV
+// serialization. Lists are joined with semicolons and an unfinished End is empty.
+func (r *AttributionRecord) Slice() []string {
+	end := ""
+	if !r.End.IsZero() {
+		end = r.End.Format(time.RFC3339)
+	}
+	return []string{
+		r.Cluster,
+		r.Namespace,
+		r.Pod,
+		r.UID,
+		r.Owner,
+		r.Node,
+		r.Machine,
+		strings.Join(r.Chassis, ";"),
+		strings.Join(r.GPUs, ";"),
+		strconv.FormatInt(r.Requested, 10),
+		strconv.FormatBool(r.Exact),
+		r.Start.Format(time.RFC3339),
+		end,
+	}
+}
+
+// Validate checks if the AttributionRecord has all required fields populated.
+func (r *AttributionRecord) Validate() error {
+	if r.Cluster == "" {
+		return fmt.Errorf("cluster name is required")
+	}
+	if r.Namespace == "" || r.Pod == "" || r.UID == "" {
+		return fmt.Errorf("pod namespace, name and UID are required")
+	}
+	if r.Node == "" {
+		return fmt.Errorf("node name is required")
+	}
+	if len(r.GPUs) == 0 {
+		return fmt.Errorf("at least one GPU is required")
+	}
+	if r.Start.IsZero() {
+		return fmt.Errorf("start time is required")
+	}
+	if !r.End.IsZero() && r.End.Before(r.Start) {
+		return fmt.Errorf("end time is before start time")
+	}
 	return nil
 }
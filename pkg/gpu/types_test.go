@@ -129,6 +129,33 @@ func TestSerialNumberReading_Validate(t *testing.T) {
 			wantErr: true,
 			errMsg:  "time is required",
 		},
+		{
+			name: "removed reading",
+			reading: &SerialNumberReading{
+				Cluster: "test-cluster",
+				Node:    "test-node",
+				Machine: "test-machine",
+				Source:  "node/test-node",
+				GPU:     "GPU-12345",
+				Time:    validTime,
+				Event:   ReadingRemoved,
+			},
+			wantErr: false,
+		},
+		{
+			name: "unknown event",
+			reading: &SerialNumberReading{
+				Cluster: "test-cluster",
+				Node:    "test-node",
+				Machine: "test-machine",
+				Source:  "test-namespace/test-pod",
+				GPU:     "GPU-12345",
+				Time:    validTime,
+				Event:   "moved",
+			},
+			wantErr: true,
+			errMsg:  `unknown reading event "moved"`,
+		},
 	}
 
 	for _, tt := range tests {
@@ -206,3 +233,53 @@ func TestSerialNumberReading_TimeFormatting(t *testing.T) {
 		})
 	}
 }
+
+func TestAttributionRecord(t *testing.T) {
+	start := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	valid := func() *AttributionRecord {
+		return &AttributionRecord{
+			Cluster:   "test-cluster",
+			Namespace: "ml",
+			Pod:       "train-0",
+			UID:       "4a1d",
+			Owner:     "Job/train",
+			Node:      "node1",
+			Machine:   "i-123",
+			Chassis:   []string{"1821325191344"},
+			GPUs:      []string{"1652823054567", "1652823055642"},
+			Requested: 2,
+			Exact:     true,
+			Start:     start,
+		}
+	}
+
+	want := []string{"test-cluster", "ml", "train-0", "4a1d", "Job/train", "node1", "i-123",
+		"1821325191344", "1652823054567;1652823055642", "2", "true", start.Format(time.RFC3339), ""}
+	if got := valid().Slice(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Slice() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*AttributionRecord)
+		wantErr bool
+	}{
+		{name: "valid", modify: func(*AttributionRecord) {}},
+		{name: "finished", modify: func(r *AttributionRecord) { r.End = start.Add(time.Hour) }},
+		{name: "missing cluster", modify: func(r *AttributionRecord) { r.Cluster = "" }, wantErr: true},
+		{name: "missing uid", modify: func(r *AttributionRecord) { r.UID = "" }, wantErr: true},
+		{name: "missing node", modify: func(r *AttributionRecord) { r.Node = "" }, wantErr: true},
+		{name: "no GPUs", modify: func(r *AttributionRecord) { r.GPUs = nil }, wantErr: true},
+		{name: "missing start", modify: func(r *AttributionRecord) { r.Start = time.Time{} }, wantErr: true},
+		{name: "end before start", modify: func(r *AttributionRecord) { r.End = start.Add(-time.Hour) }, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.modify(r)
+			if err := r.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
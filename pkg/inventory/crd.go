@@ -0,0 +1,229 @@
+// Package inventory maintains the GPUInventory custom resources that mirror the
+// GPU serials gpuid writes to node labels, and reconciles the observed GPUs
+// against an expected inventory manifest.
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/apis/gpuid/v1alpha1"
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/node"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// GPUInventory write result label values.
+const (
+	writeCreated = "created"
+	writeUpdated = "updated"
+	writeFailed  = "failed"
+)
+
+var (
+	counterWrite = counter.New("gpuid_inventory_write_total", "Total number of GPUInventory resource writes", "result")
+)
+
+// Writer creates and updates the cluster-scoped GPUInventory resource of a node.
+type Writer struct {
+	client dynamic.Interface
+	nodes  node.Getter
+}
+
+// NewWriter creates a Writer using the dynamic client so gpuid does not need a
+// generated clientset for its own resource. Each GPUInventory is owned by its
+// Node, read through nodes, so it is garbage collected with the node; a nil nodes
+// writes resources without an owner.
+func NewWriter(client dynamic.Interface, nodes node.Getter) *Writer {
+	return &Writer{client: client, nodes: nodes}
+}
+
+// Write records serials as the GPUInventory of nodeName. The resource is created on
+// first write; afterwards only its status is updated. FirstSeen is carried over for
+// GPUs already in the inventory so it reflects when the GPU was first observed,
+// unless the inventory is owned by an earlier node of the same name.
+func (w *Writer) Write(ctx context.Context, log *slog.Logger, nodeName string, serials []*gpu.Serials, now time.Time) error {
+	if nodeName == "" {
+		return fmt.Errorf("node name is required")
+	}
+	if w == nil || w.client == nil {
+		return fmt.Errorf("inventory client is nil")
+	}
+
+	owner, err := w.owner(ctx, nodeName)
+	if err != nil {
+		return err
+	}
+
+	res := w.client.Resource(v1alpha1.GPUInventoryResource)
+
+	created := false
+	inv := &v1alpha1.GPUInventory{}
+	u, err := res.Get(ctx, nodeName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		inv = newInventory(nodeName, owner)
+		if u, err = toUnstructured(inv); err != nil {
+			return err
+		}
+		if u, err = res.Create(ctx, u, metav1.CreateOptions{}); err != nil {
+			counterWrite.Increment(writeFailed)
+			return fmt.Errorf("failed to create GPUInventory %s: %w", nodeName, err)
+		}
+		created = true
+	case err != nil:
+		counterWrite.Increment(writeFailed)
+		return fmt.Errorf("failed to get GPUInventory %s: %w", nodeName, err)
+	}
+
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, inv); err != nil {
+		return fmt.Errorf("failed to decode GPUInventory %s: %w", nodeName, err)
+	}
+
+	if owner != nil && !ownedBy(inv, owner.UID) {
+		// Another owner means the node was re-created under the same name before
+		// the old inventory was collected; its GPU history doesn't apply. An
+		// inventory without an owner predates owner references and is adopted.
+		stale := len(inv.OwnerReferences) > 0
+		inv.OwnerReferences = []metav1.OwnerReference{*owner}
+		if u, err = toUnstructured(inv); err != nil {
+			return err
+		}
+		if u, err = res.Update(ctx, u, metav1.UpdateOptions{}); err != nil {
+			counterWrite.Increment(writeFailed)
+			return fmt.Errorf("failed to update GPUInventory %s owner: %w", nodeName, err)
+		}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, inv); err != nil {
+			return fmt.Errorf("failed to decode GPUInventory %s: %w", nodeName, err)
+		}
+		if stale {
+			inv.Status = v1alpha1.GPUInventoryStatus{}
+		}
+	}
+
+	inv.Status = buildStatus(inv.Status, serials, metav1.NewTime(now.UTC()))
+
+	if u, err = toUnstructured(inv); err != nil {
+		return err
+	}
+	if _, err := res.UpdateStatus(ctx, u, metav1.UpdateOptions{}); err != nil {
+		counterWrite.Increment(writeFailed)
+		return fmt.Errorf("failed to update GPUInventory %s status: %w", nodeName, err)
+	}
+
+	result := writeUpdated
+	if created {
+		result = writeCreated
+	}
+	counterWrite.Increment(result)
+
+	log.Debug("wrote GPU inventory", "node", nodeName, "gpus", inv.Status.GPUCount, "result", result)
+	return nil
+}
+
+// owner returns the owner reference to the Node nodeName, or nil without nodes.
+func (w *Writer) owner(ctx context.Context, nodeName string) (*metav1.OwnerReference, error) {
+	if w.nodes == nil {
+		return nil, nil
+	}
+	n, err := w.nodes.GetNode(ctx, nodeName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+	return &metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "Node",
+		Name:       n.Name,
+		UID:        n.UID,
+	}, nil
+}
+
+// ownedBy reports whether inv is owned by the object with uid.
+func ownedBy(inv *v1alpha1.GPUInventory, uid types.UID) bool {
+	for _, ref := range inv.OwnerReferences {
+		if ref.UID == uid {
+			return true
+		}
+	}
+	return false
+}
+
+func newInventory(nodeName string, owner *metav1.OwnerReference) *v1alpha1.GPUInventory {
+	inv := &v1alpha1.GPUInventory{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: v1alpha1.SchemeGroupVersion.String(),
+			Kind:       "GPUInventory",
+		},
+		ObjectMeta: metav1.ObjectMeta{Name: nodeName},
+		Spec:       v1alpha1.GPUInventorySpec{NodeName: nodeName},
+	}
+	if owner != nil {
+		inv.OwnerReferences = []metav1.OwnerReference{*owner}
+	}
+	return inv
+}
+
+// buildStatus converts serials into the inventory status, keeping the FirstSeen of
+// GPUs present in prev. Devices are keyed by UUID, falling back to serial when the
+// UUID is unknown.
+func buildStatus(prev v1alpha1.GPUInventoryStatus, serials []*gpu.Serials, now metav1.Time) v1alpha1.GPUInventoryStatus {
+	firstSeen := make(map[string]metav1.Time, len(prev.GPUs))
+	for _, d := range prev.GPUs {
+		firstSeen[deviceKey(d)] = d.FirstSeen
+	}
+
+	gpus := make([]v1alpha1.GPUDevice, 0)
+	for _, s := range serials {
+		if s == nil {
+			continue
+		}
+		if len(s.Devices) == 0 {
+			for _, serial := range s.GPU {
+				gpus = append(gpus, v1alpha1.GPUDevice{Serial: serial, Chassis: s.Chassis})
+			}
+		}
+		for _, d := range s.Devices {
+			gpus = append(gpus, v1alpha1.GPUDevice{Serial: d.Serial, UUID: d.UUID, Model: d.Model, Chassis: s.Chassis})
+		}
+	}
+
+	for i := range gpus {
+		gpus[i].LastSeen = now
+		gpus[i].FirstSeen = now
+		if t, ok := firstSeen[deviceKey(gpus[i])]; ok && !t.IsZero() {
+			gpus[i].FirstSeen = t
+		}
+	}
+	sort.Slice(gpus, func(i, j int) bool { return deviceKey(gpus[i]) < deviceKey(gpus[j]) })
+
+	return v1alpha1.GPUInventoryStatus{
+		GPUCount:    len(gpus),
+		ContentHash: node.ContentHash(serials),
+		LastScan:    &now,
+		GPUs:        gpus,
+	}
+}
+
+func deviceKey(d v1alpha1.GPUDevice) string {
+	if d.UUID != "" {
+		return d.UUID
+	}
+	return d.Serial
+}
+
+func toUnstructured(inv *v1alpha1.GPUInventory) (*unstructured.Unstructured, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(inv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode GPUInventory %s: %w", inv.Name, err)
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
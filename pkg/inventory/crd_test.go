@@ -0,0 +1,142 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/apis/gpuid/v1alpha1"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func getInventory(t *testing.T, client *dynamicfake.FakeDynamicClient, name string) *v1alpha1.GPUInventory {
+	t.Helper()
+	u, err := client.Resource(v1alpha1.GPUInventoryResource).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get GPUInventory: %v", err)
+	}
+	inv := &v1alpha1.GPUInventory{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, inv); err != nil {
+		t.Fatalf("failed to decode GPUInventory: %v", err)
+	}
+	return inv
+}
+
+func TestWriterWrite(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	client := dynamicfake.NewSimpleDynamicClient(scheme)
+	w := NewWriter(client, nil)
+	ctx := context.Background()
+
+	first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	serials := []*gpu.Serials{{
+		Chassis: "1654922000042",
+		GPU:     []string{"1650924060039"},
+		Devices: []gpu.Device{{Serial: "1650924060039", UUID: "GPU-aaa", Model: "NVIDIA H100 80GB HBM3"}},
+	}}
+
+	if err := w.Write(ctx, slog.Default(), "node-1", serials, first); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+
+	inv := getInventory(t, client, "node-1")
+	if inv.Spec.NodeName != "node-1" || inv.Status.GPUCount != 1 {
+		t.Fatalf("unexpected inventory: %+v", inv)
+	}
+	d := inv.Status.GPUs[0]
+	if d.Serial != "1650924060039" || d.UUID != "GPU-aaa" || d.Model != "NVIDIA H100 80GB HBM3" || d.Chassis != "1654922000042" {
+		t.Errorf("unexpected device: %+v", d)
+	}
+
+	// A second scan adds a GPU; the existing one keeps its FirstSeen.
+	second := first.Add(time.Hour)
+	serials[0].GPU = append(serials[0].GPU, "1650924060040")
+	serials[0].Devices = append(serials[0].Devices, gpu.Device{Serial: "1650924060040", UUID: "GPU-bbb"})
+
+	if err := w.Write(ctx, slog.Default(), "node-1", serials, second); err != nil {
+		t.Fatalf("second Write() unexpected error: %v", err)
+	}
+
+	inv = getInventory(t, client, "node-1")
+	if inv.Status.GPUCount != 2 {
+		t.Fatalf("expected 2 GPUs, got %d", inv.Status.GPUCount)
+	}
+	if got := inv.Status.GPUs[0]; !got.FirstSeen.Time.Equal(first) || !got.LastSeen.Time.Equal(second) {
+		t.Errorf("existing GPU times = %v/%v, want %v/%v", got.FirstSeen, got.LastSeen, first, second)
+	}
+	if got := inv.Status.GPUs[1]; !got.FirstSeen.Time.Equal(second) {
+		t.Errorf("new GPU FirstSeen = %v, want %v", got.FirstSeen, second)
+	}
+}
+
+func TestWriterWriteValidation(t *testing.T) {
+	if err := NewWriter(nil, nil).Write(context.Background(), slog.Default(), "node-1", nil, time.Now()); err == nil {
+		t.Error("expected error for nil client")
+	}
+	client := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme())
+	if err := NewWriter(client, nil).Write(context.Background(), slog.Default(), "", nil, time.Now()); err == nil {
+		t.Error("expected error for empty node name")
+	}
+}
+
+// nodeGetter serves nodes by name.
+type nodeGetter map[string]*corev1.Node
+
+func (g nodeGetter) GetNode(_ context.Context, name string) (*corev1.Node, error) {
+	n, ok := g[name]
+	if !ok {
+		return nil, fmt.Errorf("node %s not found", name)
+	}
+	return n, nil
+}
+
+func TestWriterWriteOwner(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("failed to build scheme: %v", err)
+	}
+	client := dynamicfake.NewSimpleDynamicClient(scheme)
+	nodes := nodeGetter{"node-1": {ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "uid-1"}}}
+	w := NewWriter(client, nodes)
+	ctx := context.Background()
+
+	first := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	serials := []*gpu.Serials{{Chassis: "1654922000042", GPU: []string{"1650924060039"}}}
+	if err := w.Write(ctx, slog.Default(), "node-1", serials, first); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+
+	inv := getInventory(t, client, "node-1")
+	if len(inv.OwnerReferences) != 1 || inv.OwnerReferences[0].Kind != "Node" || inv.OwnerReferences[0].UID != "uid-1" {
+		t.Fatalf("unexpected owner references: %+v", inv.OwnerReferences)
+	}
+
+	// The node is re-created under the same name: the inventory moves to the new
+	// node and starts a fresh GPU history.
+	second := first.Add(time.Hour)
+	nodes["node-1"] = &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "uid-2"}}
+	if err := w.Write(ctx, slog.Default(), "node-1", serials, second); err != nil {
+		t.Fatalf("second Write() unexpected error: %v", err)
+	}
+
+	inv = getInventory(t, client, "node-1")
+	if len(inv.OwnerReferences) != 1 || inv.OwnerReferences[0].UID != "uid-2" {
+		t.Fatalf("unexpected owner references after re-creation: %+v", inv.OwnerReferences)
+	}
+	if got := inv.Status.GPUs[0].FirstSeen; !got.Time.Equal(second) {
+		t.Errorf("FirstSeen = %v, want %v after node re-creation", got, second)
+	}
+
+	if err := w.Write(ctx, slog.Default(), "node-2", serials, second); err == nil {
+		t.Error("expected error for an unknown node")
+	}
+}
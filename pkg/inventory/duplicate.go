@@ -0,0 +1,183 @@
+package inventory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+var gaugeDuplicate = counter.NewGauge("gpuid_duplicate_serial", "Number of live nodes reporting the same GPU serial within the duplicate window", "serial")
+
+// Sighting is the last time a node reported a GPU serial.
+type Sighting struct {
+	Node     string    `json:"node"`
+	Machine  string    `json:"machine,omitempty"`
+	LastSeen time.Time `json:"lastSeen"`
+}
+
+// Duplicate is a GPU serial reported by more than one live node.
+type Duplicate struct {
+	Serial    string     `json:"serial"`
+	Sightings []Sighting `json:"sightings"` // sorted by node
+}
+
+// Nodes returns the names of the nodes reporting the serial.
+func (d Duplicate) Nodes() []string {
+	nodes := make([]string, len(d.Sightings))
+	for i, s := range d.Sightings {
+		nodes[i] = s.Node
+	}
+	return nodes
+}
+
+// SerialIndex maps GPU serials to the nodes that reported them. A serial
+// reported by two nodes whose sightings are both within the window is a
+// duplicate: one GPU can't be in two machines, so either the data is wrong or
+// the serial is being cloned. It is safe for concurrent use.
+type SerialIndex struct {
+	window time.Duration
+	live   func(nodeName string) bool
+
+	mu       sync.Mutex
+	serials  map[string]map[string]Sighting // serial -> node -> sighting
+	byNode   map[string][]string            // node -> serials in its last scan
+	reported map[string]string              // serial -> nodes last reported as duplicate
+}
+
+// NewSerialIndex creates a SerialIndex. live reports whether a node still exists,
+// so sightings from deleted nodes are dropped before the window expires them; nil
+// treats every node as live.
+func NewSerialIndex(window time.Duration, live func(nodeName string) bool) *SerialIndex {
+	return &SerialIndex{
+		window:   window,
+		live:     live,
+		serials:  make(map[string]map[string]Sighting),
+		byNode:   make(map[string][]string),
+		reported: make(map[string]string),
+	}
+}
+
+// Observe records the GPU serials scanned on nodeName at now, replacing the
+// node's previous scan. It returns the duplicates involving nodeName whose set of
+// nodes changed since they were last returned, so each new duplicate is reported
+// once. The gpuid_duplicate_serial gauge tracks every current duplicate.
+func (x *SerialIndex) Observe(nodeName, machine string, serials []*gpu.Serials, now time.Time) []Duplicate {
+	current := make(map[string]bool)
+	for _, s := range serials {
+		if s == nil {
+			continue
+		}
+		for _, g := range s.GPU {
+			if g != "" {
+				current[g] = true
+			}
+		}
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	// GPUs that left the node no longer place it.
+	for _, g := range x.byNode[nodeName] {
+		if !current[g] {
+			x.drop(g, nodeName)
+		}
+	}
+
+	list := make([]string, 0, len(current))
+	for g := range current {
+		if x.serials[g] == nil {
+			x.serials[g] = make(map[string]Sighting)
+		}
+		x.serials[g][nodeName] = Sighting{Node: nodeName, Machine: machine, LastSeen: now}
+		list = append(list, g)
+	}
+	sort.Strings(list)
+	x.byNode[nodeName] = list
+
+	x.expire(now)
+
+	var out []Duplicate
+	for _, g := range list {
+		d, ok := x.duplicate(g)
+		if !ok {
+			continue
+		}
+		key := strings.Join(d.Nodes(), ",")
+		if x.reported[g] == key {
+			continue
+		}
+		x.reported[g] = key
+		out = append(out, d)
+	}
+	return out
+}
+
+// expire drops sightings older than the window or from nodes that no longer
+// exist, along with the scans of those nodes, and refreshes the gauge; callers
+// hold x.mu.
+func (x *SerialIndex) expire(now time.Time) {
+	for g, nodes := range x.serials {
+		for n, s := range nodes {
+			if now.Sub(s.LastSeen) > x.window || (x.live != nil && !x.live(n)) {
+				x.drop(g, n)
+			}
+		}
+	}
+	// A node's sightings are all recorded at once, so they expire together;
+	// forget the scans of nodes that no longer place any serial.
+	for n, list := range x.byNode {
+		if (x.live != nil && !x.live(n)) || !x.places(n, list) {
+			delete(x.byNode, n)
+		}
+	}
+	for g := range x.reported {
+		if _, ok := x.duplicate(g); !ok {
+			delete(x.reported, g)
+			gaugeDuplicate.Delete(g)
+		}
+	}
+	for g, nodes := range x.serials {
+		if len(nodes) > 1 {
+			gaugeDuplicate.Set(float64(len(nodes)), g)
+		}
+	}
+}
+
+// places reports whether node n still has a sighting of any serial in list;
+// callers hold x.mu.
+func (x *SerialIndex) places(n string, list []string) bool {
+	for _, g := range list {
+		if _, ok := x.serials[g][n]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// drop removes the sighting of serial g on node n; callers hold x.mu.
+func (x *SerialIndex) drop(g, n string) {
+	delete(x.serials[g], n)
+	if len(x.serials[g]) == 0 {
+		delete(x.serials, g)
+	}
+}
+
+// duplicate returns serial g as a Duplicate when more than one node reports it;
+// callers hold x.mu.
+func (x *SerialIndex) duplicate(g string) (Duplicate, bool) {
+	nodes := x.serials[g]
+	if len(nodes) < 2 {
+		return Duplicate{}, false
+	}
+	d := Duplicate{Serial: g, Sightings: make([]Sighting, 0, len(nodes))}
+	for _, s := range nodes {
+		d.Sightings = append(d.Sightings, s)
+	}
+	sort.Slice(d.Sightings, func(i, j int) bool { return d.Sightings[i].Node < d.Sightings[j].Node })
+	return d, true
+}
@@ -0,0 +1,71 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+func TestSerialIndex(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	live := map[string]bool{"node1": true, "node2": true, "node3": true}
+	x := NewSerialIndex(time.Hour, func(n string) bool { return live[n] })
+
+	scan := func(gpus ...string) []*gpu.Serials {
+		return []*gpu.Serials{{Chassis: "C1", GPU: gpus}}
+	}
+
+	if got := x.Observe("node1", "i-1", scan("G1", "G2"), now); len(got) != 0 {
+		t.Fatalf("first scan reported duplicates: %+v", got)
+	}
+
+	got := x.Observe("node2", "i-2", scan("G2", "G3"), now.Add(time.Minute))
+	want := []Duplicate{{Serial: "G2", Sightings: []Sighting{
+		{Node: "node1", Machine: "i-1", LastSeen: now},
+		{Node: "node2", Machine: "i-2", LastSeen: now.Add(time.Minute)},
+	}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Observe() = %+v, want %+v", got, want)
+	}
+
+	// The same duplicate is reported once, until its set of nodes changes.
+	if got := x.Observe("node2", "i-2", scan("G2", "G3"), now.Add(2*time.Minute)); len(got) != 0 {
+		t.Errorf("repeat scan reported duplicates again: %+v", got)
+	}
+	got = x.Observe("node3", "i-3", scan("G2"), now.Add(3*time.Minute))
+	if len(got) != 1 || !reflect.DeepEqual(got[0].Nodes(), []string{"node1", "node2", "node3"}) {
+		t.Errorf("third node: Observe() = %+v, want G2 on node1, node2, node3", got)
+	}
+
+	// A GPU that left a node no longer places it there.
+	x.Observe("node3", "i-3", scan("G4"), now.Add(4*time.Minute))
+	if nodes := x.serials["G2"]; len(nodes) != 2 {
+		t.Errorf("G2 sightings after node3 rescan = %v, want node1 and node2", nodes)
+	}
+
+	// Deleted nodes drop out before the window expires their sightings.
+	live["node1"] = false
+	x.Observe("node2", "i-2", scan("G2", "G3"), now.Add(5*time.Minute))
+	if _, ok := x.serials["G2"]["node1"]; ok {
+		t.Error("sighting from deleted node1 was kept")
+	}
+	if _, ok := x.byNode["node1"]; ok {
+		t.Error("scan of deleted node1 was kept")
+	}
+
+	// Sightings older than the window expire.
+	live["node1"] = true
+	x.Observe("node1", "i-1", scan("G1", "G2"), now.Add(6*time.Minute))
+	x.Observe("node3", "i-3", scan("G4"), now.Add(2*time.Hour))
+	if len(x.serials) != 1 {
+		t.Errorf("serials after window = %v, want only G4", x.serials)
+	}
+	if len(x.reported) != 0 {
+		t.Errorf("reported duplicates after window = %v, want none", x.reported)
+	}
+	if _, ok := x.byNode["node1"]; ok || len(x.byNode) != 1 {
+		t.Errorf("scans after window = %v, want only node3", x.byNode)
+	}
+}
@@ -0,0 +1,433 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/source"
+	"k8s.io/client-go/kubernetes"
+)
+
+// Discrepancy types reported by the Reconciler.
+const (
+	// FindingUnknown is an observed GPU or chassis serial the manifest doesn't list.
+	FindingUnknown = "unknown"
+	// FindingMissing is a GPU in the manifest that no scanned node reports. It is
+	// only reported once the GPU's chassis has been scanned, or after a full pass
+	// over the fleet (see Reconciler.Complete).
+	FindingMissing = "missing"
+	// FindingMismatch is a GPU observed in a different chassis than the manifest says.
+	FindingMismatch = "mismatch"
+
+	// Manifest load result label values.
+	loadSuccess = "success"
+	loadFailure = "failure"
+)
+
+var (
+	gaugeDiscrepancies = counter.NewGauge("gpuid_inventory_discrepancies", "Differences between the expected inventory manifest and the observed GPUs", "type")
+	counterManifest    = counter.New("gpuid_inventory_manifest_load_total", "Total number of expected inventory manifest loads", "result")
+)
+
+// Expected is one manifest entry: a GPU serial and the chassis it belongs to.
+// Chassis is empty for GPUs that don't report one (e.g. H100 boards, where
+// nvidia-smi prints N/A).
+type Expected struct {
+	Chassis string `json:"chassis"`
+	GPU     string `json:"gpu"`
+}
+
+// Finding is a single difference between the manifest and the observed GPUs.
+type Finding struct {
+	Type            string `json:"type"`
+	Node            string `json:"node,omitempty"`
+	Chassis         string `json:"chassis,omitempty"`
+	GPU             string `json:"gpu,omitempty"`
+	ExpectedChassis string `json:"expectedChassis,omitempty"`
+}
+
+// String describes the finding for logs and Events.
+func (f Finding) String() string {
+	switch {
+	case f.Type == FindingMismatch:
+		return fmt.Sprintf("GPU %s in chassis %q, expected %q", f.GPU, f.Chassis, f.ExpectedChassis)
+	case f.Type == FindingMissing:
+		return fmt.Sprintf("GPU %s missing from chassis %q", f.GPU, f.ExpectedChassis)
+	case f.GPU == "":
+		return fmt.Sprintf("unknown chassis %s", f.Chassis)
+	default:
+		return fmt.Sprintf("unknown GPU %s", f.GPU)
+	}
+}
+
+// Report is the reconciliation of the manifest against every node observed so far.
+type Report struct {
+	Generated time.Time      `json:"generated"`
+	Source    string         `json:"source"`
+	Expected  int            `json:"expected"`
+	Observed  int            `json:"observed"`
+	Nodes     int            `json:"nodes"`
+	Summary   map[string]int `json:"summary"`
+	Findings  []Finding      `json:"findings"`
+}
+
+// ParseManifest reads an expected inventory manifest. JSON manifests are an
+// array of {"chassis": "...", "gpu": "..."} objects; anything else is read as
+// CSV with a header row naming a "gpu" column and, optionally, a "chassis"
+// column. Other columns are ignored so CMDB exports can be used as they are.
+func ParseManifest(data []byte) ([]Expected, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, fmt.Errorf("manifest is empty")
+	}
+
+	var entries []Expected
+	if trimmed[0] == '[' {
+		if err := json.Unmarshal(trimmed, &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON manifest: %w", err)
+		}
+	} else {
+		var err error
+		if entries, err = parseCSVManifest(trimmed); err != nil {
+			return nil, err
+		}
+	}
+
+	seen := make(map[string]string, len(entries))
+	out := entries[:0]
+	for i, e := range entries {
+		e.Chassis, e.GPU = normalizeChassis(e.Chassis), strings.TrimSpace(e.GPU)
+		if e.GPU == "" {
+			return nil, fmt.Errorf("manifest entry %d: gpu serial is required", i+1)
+		}
+		if c, ok := seen[e.GPU]; ok {
+			if c != e.Chassis {
+				return nil, fmt.Errorf("manifest lists GPU %s in both chassis %q and %q", e.GPU, c, e.Chassis)
+			}
+			continue
+		}
+		seen[e.GPU] = e.Chassis
+		out = append(out, e)
+	}
+	return out, nil
+}
+
+func parseCSVManifest(data []byte) ([]Expected, error) {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV manifest header: %w", err)
+	}
+	gpuCol, chassisCol := -1, -1
+	for i, h := range header {
+		switch strings.ToLower(strings.TrimSpace(h)) {
+		case "gpu":
+			gpuCol = i
+		case "chassis":
+			chassisCol = i
+		}
+	}
+	if gpuCol < 0 {
+		return nil, fmt.Errorf("CSV manifest header must name a gpu column, got %q", strings.Join(header, ","))
+	}
+
+	var entries []Expected
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV manifest: %w", err)
+		}
+		var e Expected
+		if gpuCol < len(rec) {
+			e.GPU = rec[gpuCol]
+		}
+		if chassisCol >= 0 && chassisCol < len(rec) {
+			e.Chassis = rec[chassisCol]
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// Reconciler compares the GPUs observed on each node with an expected inventory
+// manifest. Observations are kept in memory, so the comparison covers the nodes
+// scanned by this replica in its current term. It is safe for concurrent use.
+type Reconciler struct {
+	source string
+	load   source.Loader
+
+	mu       sync.Mutex
+	loaded   bool
+	active   bool              // observing nodes; only then is the gauge published
+	complete bool              // a full pass over the fleet is done
+	expected map[string]string // GPU serial -> expected chassis
+	chassis  map[string]bool   // chassis serials in the manifest
+	observed map[string][]*gpu.Serials
+	reported map[string]string // node -> findings last returned by Observe
+}
+
+// NewReconciler creates a Reconciler for a manifest at src: a file path,
+// configmap://<namespace>/<name> or http(s) URL (see source.New). Nothing is
+// reported until the manifest is loaded with Refresh.
+func NewReconciler(src string, cs kubernetes.Interface) (*Reconciler, error) {
+	load, err := source.New(src, cs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expected inventory source: %w", err)
+	}
+	return &Reconciler{
+		source:   src,
+		load:     load,
+		observed: make(map[string][]*gpu.Serials),
+		reported: make(map[string]string),
+	}, nil
+}
+
+// Refresh reloads the manifest. A manifest that fails to load or parse keeps the
+// previous one.
+func (r *Reconciler) Refresh(ctx context.Context, log *slog.Logger) error {
+	data, err := r.load(ctx)
+	if err != nil {
+		counterManifest.Increment(loadFailure)
+		return fmt.Errorf("failed to load expected inventory from %s: %w", r.source, err)
+	}
+	entries, err := ParseManifest(data)
+	if err != nil {
+		counterManifest.Increment(loadFailure)
+		return fmt.Errorf("invalid expected inventory from %s: %w", r.source, err)
+	}
+
+	expected := make(map[string]string, len(entries))
+	chassis := make(map[string]bool)
+	for _, e := range entries {
+		expected[e.GPU] = e.Chassis
+		if e.Chassis != "" {
+			chassis[e.Chassis] = true
+		}
+	}
+
+	r.mu.Lock()
+	r.expected, r.chassis, r.loaded = expected, chassis, true
+	rep, active := r.report(time.Now()), r.active
+	r.mu.Unlock()
+
+	counterManifest.Increment(loadSuccess)
+	if active {
+		setDiscrepancies(rep.Summary)
+	}
+	log.Debug("expected inventory loaded", "source", r.source, "gpus", len(expected), "chassis", len(chassis))
+	return nil
+}
+
+// Observe records the GPUs scanned on nodeName and returns the findings for that
+// node, and whether they differ from the findings last returned for it.
+func (r *Reconciler) Observe(nodeName string, serials []*gpu.Serials) ([]Finding, bool) {
+	r.mu.Lock()
+	r.active = true
+	r.observed[nodeName] = serials
+	rep := r.report(time.Now())
+
+	var findings []Finding
+	for _, f := range rep.Findings {
+		if f.Node == nodeName {
+			findings = append(findings, f)
+		}
+	}
+	key := findingsKey(findings)
+	changed := r.reported[nodeName] != key
+	r.reported[nodeName] = key
+	r.mu.Unlock()
+
+	setDiscrepancies(rep.Summary)
+	return findings, changed
+}
+
+// Forget drops the observation of a deleted node.
+func (r *Reconciler) Forget(nodeName string) {
+	r.mu.Lock()
+	if _, ok := r.observed[nodeName]; !ok {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.observed, nodeName)
+	delete(r.reported, nodeName)
+	rep, active := r.report(time.Now()), r.active
+	r.mu.Unlock()
+
+	if active {
+		setDiscrepancies(rep.Summary)
+	}
+}
+
+// Complete marks the first full pass over the fleet done. Until then a GPU is
+// only reported missing when its chassis has been scanned, since any node not
+// scanned yet may hold it; GPUs without a chassis are not reported missing at all.
+func (r *Reconciler) Complete() {
+	r.mu.Lock()
+	r.complete = true
+	rep, active := r.report(time.Now()), r.active
+	r.mu.Unlock()
+
+	if active {
+		setDiscrepancies(rep.Summary)
+	}
+}
+
+// Reset forgets every observation and clears the gpuid_inventory_discrepancies
+// gauge, so a replica that stops reconciling (e.g. on losing the leader lease)
+// does not keep publishing a stale view.
+func (r *Reconciler) Reset() {
+	r.mu.Lock()
+	r.active, r.complete = false, false
+	r.observed = make(map[string][]*gpu.Serials)
+	r.reported = make(map[string]string)
+	r.mu.Unlock()
+
+	gaugeDiscrepancies.Reset()
+}
+
+// Report reconciles the manifest against every observed node.
+func (r *Reconciler) Report() Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.report(time.Now())
+}
+
+// report builds the reconciliation; callers hold r.mu.
+func (r *Reconciler) report(now time.Time) Report {
+	rep := Report{
+		Generated: now.UTC(),
+		Source:    r.source,
+		Expected:  len(r.expected),
+		Nodes:     len(r.observed),
+		Summary:   map[string]int{FindingUnknown: 0, FindingMissing: 0, FindingMismatch: 0},
+		Findings:  []Finding{},
+	}
+	if !r.loaded {
+		return rep
+	}
+
+	seen := make(map[string]bool)
+	chassisNode := make(map[string]string)
+	for nodeName, serials := range r.observed {
+		for _, s := range serials {
+			if s == nil {
+				continue
+			}
+			chassis := normalizeChassis(s.Chassis)
+			if chassis != "" {
+				chassisNode[chassis] = nodeName
+				if !r.chassis[chassis] {
+					rep.Findings = append(rep.Findings, Finding{Type: FindingUnknown, Node: nodeName, Chassis: chassis})
+				}
+			}
+			for _, g := range s.GPU {
+				if seen[g] {
+					continue
+				}
+				seen[g] = true
+				rep.Observed++
+
+				want, ok := r.expected[g]
+				switch {
+				case !ok:
+					rep.Findings = append(rep.Findings, Finding{Type: FindingUnknown, Node: nodeName, Chassis: chassis, GPU: g})
+				case want != chassis:
+					rep.Findings = append(rep.Findings, Finding{Type: FindingMismatch, Node: nodeName, Chassis: chassis, GPU: g, ExpectedChassis: want})
+				}
+			}
+		}
+	}
+
+	for g, c := range r.expected {
+		if seen[g] {
+			continue
+		}
+		// Attribute the missing GPU to the node hosting its chassis. Without one
+		// it's only missing once every node has been scanned.
+		if n, scanned := chassisNode[c]; scanned || r.complete {
+			rep.Findings = append(rep.Findings, Finding{Type: FindingMissing, Node: n, GPU: g, ExpectedChassis: c})
+		}
+	}
+
+	sort.Slice(rep.Findings, func(i, j int) bool {
+		a, b := rep.Findings[i], rep.Findings[j]
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		if a.Node != b.Node {
+			return a.Node < b.Node
+		}
+		if a.Chassis != b.Chassis {
+			return a.Chassis < b.Chassis
+		}
+		return a.GPU < b.GPU
+	})
+	for _, f := range rep.Findings {
+		rep.Summary[f.Type]++
+	}
+	return rep
+}
+
+// ServeHTTP writes the reconciliation report as JSON, or as a CSV download with
+// ?format=csv.
+func (r *Reconciler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	rep := r.Report()
+
+	if req.URL.Query().Get("format") == "csv" {
+		w.Header().Set("Content-Type", "text/csv")
+		w.Header().Set("Content-Disposition", `attachment; filename="gpuid-reconciliation.csv"`)
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"type", "node", "chassis", "gpu", "expected_chassis"})
+		for _, f := range rep.Findings {
+			_ = cw.Write([]string{f.Type, f.Node, f.Chassis, f.GPU, f.ExpectedChassis})
+		}
+		cw.Flush()
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(rep)
+}
+
+// normalizeChassis maps the N/A nvidia-smi reports for GPUs without a chassis
+// (e.g. H100 boards) to "", so they compare equal to manifest entries that leave
+// the chassis out.
+func normalizeChassis(chassis string) string {
+	chassis = strings.TrimSpace(chassis)
+	if strings.EqualFold(chassis, "N/A") {
+		return ""
+	}
+	return chassis
+}
+
+func setDiscrepancies(summary map[string]int) {
+	for t, n := range summary {
+		gaugeDiscrepancies.Set(float64(n), t)
+	}
+}
+
+func findingsKey(findings []Finding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		parts[i] = f.String()
+	}
+	return strings.Join(parts, "\n")
+}
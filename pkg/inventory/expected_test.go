@@ -0,0 +1,217 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+func TestParseManifest(t *testing.T) {
+	want := []Expected{
+		{Chassis: "1821325191344", GPU: "1652823054567"},
+		{Chassis: "1821325191344", GPU: "1652823055642"},
+		{GPU: "1650924060039"},
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		want    []Expected
+		wantErr bool
+	}{
+		{
+			name: "csv with extra columns",
+			data: "rack,chassis,gpu\nr1,1821325191344,1652823054567\nr1, 1821325191344 ,1652823055642\nr2,,1650924060039\n",
+			want: want,
+		},
+		{
+			name: "json",
+			data: `[{"chassis":"1821325191344","gpu":"1652823054567"},{"chassis":"1821325191344","gpu":"1652823055642"},{"gpu":"1650924060039"}]`,
+			want: want,
+		},
+		{name: "duplicate entry", data: "gpu\n1650924060039\n1650924060039\n", want: []Expected{{GPU: "1650924060039"}}},
+		{name: "conflicting chassis", data: "chassis,gpu\nA,1\nB,1\n", wantErr: true},
+		{name: "no gpu column", data: "chassis,serial\nA,1\n", wantErr: true},
+		{name: "empty gpu", data: `[{"chassis":"A"}]`, wantErr: true},
+		{name: "empty", data: " \n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseManifest([]byte(tt.data))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseManifest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseManifest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReconciler(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expected.csv")
+	manifest := "chassis,gpu\nC1,G1\nC1,G2\nC2,G3\nC2,G4\n,G5\n"
+	if err := os.WriteFile(path, []byte(manifest), 0o600); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	r, err := NewReconciler(path, nil)
+	if err != nil {
+		t.Fatalf("NewReconciler() unexpected error: %v", err)
+	}
+
+	// Nothing is reported before the manifest loads.
+	if findings, _ := r.Observe("node1", []*gpu.Serials{{Chassis: "C1", GPU: []string{"G1", "X1"}}}); len(findings) != 0 {
+		t.Errorf("Observe() before Refresh = %v, want none", findings)
+	}
+	if err := r.Refresh(context.Background(), slog.Default()); err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+
+	findings, changed := r.Observe("node1", []*gpu.Serials{{Chassis: "C1", GPU: []string{"G1", "G3", "X1"}}})
+	want := []Finding{
+		{Type: FindingMismatch, Node: "node1", Chassis: "C1", GPU: "G3", ExpectedChassis: "C2"},
+		{Type: FindingMissing, Node: "node1", GPU: "G2", ExpectedChassis: "C1"},
+		{Type: FindingUnknown, Node: "node1", Chassis: "C1", GPU: "X1"},
+	}
+	if !changed || !reflect.DeepEqual(findings, want) {
+		t.Errorf("Observe() = %+v, %v; want %+v, true", findings, changed, want)
+	}
+	if _, changed = r.Observe("node1", []*gpu.Serials{{Chassis: "C1", GPU: []string{"G1", "G3", "X1"}}}); changed {
+		t.Error("Observe() reported a change for the same findings")
+	}
+
+	if findings, _ = r.Observe("node2", []*gpu.Serials{{Chassis: "CX", GPU: []string{"G5"}}}); len(findings) != 2 {
+		t.Errorf("Observe(node2) = %+v, want unknown chassis and mismatch", findings)
+	}
+
+	// G4 is in chassis C2, which no node reported yet, so it isn't missing until
+	// the first full pass is done.
+	rep := r.Report()
+	wantSummary := map[string]int{FindingUnknown: 2, FindingMissing: 1, FindingMismatch: 2}
+	if !reflect.DeepEqual(rep.Summary, wantSummary) {
+		t.Errorf("Report().Summary = %v, want %v", rep.Summary, wantSummary)
+	}
+	if rep.Expected != 5 || rep.Observed != 4 || rep.Nodes != 2 {
+		t.Errorf("Report() expected=%d observed=%d nodes=%d, want 5/4/2", rep.Expected, rep.Observed, rep.Nodes)
+	}
+	r.Complete()
+	wantSummary = map[string]int{FindingUnknown: 2, FindingMissing: 2, FindingMismatch: 2}
+	if got := r.Report().Summary; !reflect.DeepEqual(got, wantSummary) {
+		t.Errorf("Report().Summary after Complete = %v, want %v", got, wantSummary)
+	}
+
+	// A deleted node no longer counts; G5 is now missing too.
+	r.Forget("node2")
+	wantSummary = map[string]int{FindingUnknown: 1, FindingMissing: 3, FindingMismatch: 1}
+	if got := r.Report(); !reflect.DeepEqual(got.Summary, wantSummary) || got.Nodes != 1 {
+		t.Errorf("Report() after Forget = %v over %d nodes, want %v over 1", got.Summary, got.Nodes, wantSummary)
+	}
+
+	// A failed reload keeps the previous manifest.
+	if err := os.WriteFile(path, []byte("serial\nG1\n"), 0o600); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	if err := r.Refresh(context.Background(), slog.Default()); err == nil {
+		t.Error("expected error for invalid manifest")
+	}
+	if got := r.Report().Expected; got != 5 {
+		t.Errorf("Report().Expected after failed reload = %d, want 5", got)
+	}
+
+	// Reset starts over, as when a replica stops leading.
+	r.Reset()
+	if rep := r.Report(); rep.Nodes != 0 || len(rep.Findings) != 0 {
+		t.Errorf("Report() after Reset = %+v, want no nodes or findings", rep)
+	}
+}
+
+func TestReconcilerServeHTTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "expected.json")
+	if err := os.WriteFile(path, []byte(`[{"chassis":"C1","gpu":"G1"}]`), 0o600); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+	r, err := NewReconciler("file://"+path, nil)
+	if err != nil {
+		t.Fatalf("NewReconciler() unexpected error: %v", err)
+	}
+	if err := r.Refresh(context.Background(), slog.Default()); err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+	r.Observe("node1", []*gpu.Serials{{Chassis: "C1", GPU: []string{"G2"}}})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/inventory/report", nil))
+	var rep Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &rep); err != nil {
+		t.Fatalf("failed to decode report: %v", err)
+	}
+	if len(rep.Findings) != 2 {
+		t.Errorf("report findings = %+v, want missing and unknown", rep.Findings)
+	}
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/inventory/report?format=csv", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Errorf("Content-Type = %q, want text/csv", ct)
+	}
+	want := "type,node,chassis,gpu,expected_chassis\nmissing,node1,,G1,C1\nunknown,node1,C1,G2,\n"
+	if got := rec.Body.String(); got != want {
+		t.Errorf("CSV report = %q, want %q", got, want)
+	}
+	if !strings.Contains(rec.Header().Get("Content-Disposition"), "attachment") {
+		t.Error("CSV report is not served as an attachment")
+	}
+}
+
+func TestReconcilerWithoutChassis(t *testing.T) {
+	data, err := os.ReadFile("../../etc/gpus/h100.xml")
+	if err != nil {
+		t.Fatalf("failed to read fixture: %v", err)
+	}
+	serials, err := gpu.ParseSerials(data)
+	if err != nil {
+		t.Fatalf("ParseSerials() unexpected error: %v", err)
+	}
+
+	// H100 boards report the chassis as N/A; the manifest leaves it out, or
+	// copies the N/A from nvidia-smi.
+	var sb strings.Builder
+	sb.WriteString("chassis,gpu\n")
+	for _, s := range serials {
+		for i, g := range s.GPU {
+			chassis := ""
+			if i%2 == 0 {
+				chassis = "N/A"
+			}
+			sb.WriteString(chassis + "," + g + "\n")
+		}
+	}
+	path := filepath.Join(t.TempDir(), "expected.csv")
+	if err := os.WriteFile(path, []byte(sb.String()), 0o600); err != nil {
+		t.Fatalf("failed to write manifest: %v", err)
+	}
+
+	r, err := NewReconciler(path, nil)
+	if err != nil {
+		t.Fatalf("NewReconciler() unexpected error: %v", err)
+	}
+	if err := r.Refresh(context.Background(), slog.Default()); err != nil {
+		t.Fatalf("Refresh() unexpected error: %v", err)
+	}
+	if findings, _ := r.Observe("node1", serials); len(findings) != 0 {
+		t.Errorf("Observe() = %+v, want none", findings)
+	}
+	if rep := r.Report(); rep.Observed == 0 || rep.Observed != rep.Expected {
+		t.Errorf("Report() expected=%d observed=%d, want all fixture GPUs", rep.Expected, rep.Observed)
+	}
+}
@@ -0,0 +1,141 @@
+// Package nfd publishes the gpuid node labels as Node Feature Discovery
+// NodeFeature resources, so nfd-master applies them to the node instead of gpuid.
+package nfd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+const (
+	// NodeNameLabel is the label nfd-master reads to find the node a NodeFeature
+	// describes.
+	NodeNameLabel = "nfd.node.kubernetes.io/node-name"
+
+	// namePrefix keeps the gpuid NodeFeature apart from the one nfd-worker
+	// writes for the same node.
+	namePrefix = "gpuid-"
+
+	// NodeFeature write result label values.
+	writeCreated   = "created"
+	writeUpdated   = "updated"
+	writeUnchanged = "unchanged"
+	writeFailed    = "failed"
+)
+
+var (
+	// NodeFeatureResource is the group version resource of NodeFeature.
+	NodeFeatureResource = schema.GroupVersionResource{Group: "nfd.k8s-sigs.io", Version: "v1alpha1", Resource: "nodefeatures"}
+
+	counterWrite = counter.New("gpuid_nodefeature_write_total", "Total number of NodeFeature resource writes", "result")
+)
+
+// Writer creates and updates the NodeFeature of a node in one namespace.
+type Writer struct {
+	client    dynamic.Interface
+	namespace string
+}
+
+// NewWriter creates a Writer using the dynamic client so gpuid does not depend on
+// the NFD API module.
+func NewWriter(client dynamic.Interface, namespace string) *Writer {
+	return &Writer{client: client, namespace: namespace}
+}
+
+// Name returns the name of the NodeFeature gpuid writes for nodeName.
+func Name(nodeName string) string {
+	return namePrefix + nodeName
+}
+
+// Write sets labels as the labels of the NodeFeature of nodeName. The resource is
+// created on first write and only updated when the labels change; nfd-master
+// then adds, updates and removes the node labels to match.
+func (w *Writer) Write(ctx context.Context, log *slog.Logger, nodeName string, labels map[string]string) error {
+	if nodeName == "" {
+		return fmt.Errorf("node name is required")
+	}
+	if w == nil || w.client == nil {
+		return fmt.Errorf("node feature client is nil")
+	}
+
+	res := w.client.Resource(NodeFeatureResource).Namespace(w.namespace)
+	name := Name(nodeName)
+
+	u, err := res.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		if _, err := res.Create(ctx, newNodeFeature(w.namespace, nodeName, labels), metav1.CreateOptions{}); err != nil {
+			counterWrite.Increment(writeFailed)
+			return fmt.Errorf("failed to create NodeFeature %s/%s: %w", w.namespace, name, err)
+		}
+		counterWrite.Increment(writeCreated)
+		log.Debug("created node feature", "node", nodeName, "labels", len(labels))
+		return nil
+	case err != nil:
+		counterWrite.Increment(writeFailed)
+		return fmt.Errorf("failed to get NodeFeature %s/%s: %w", w.namespace, name, err)
+	}
+
+	current, _, err := unstructured.NestedStringMap(u.Object, "spec", "labels")
+	if err != nil {
+		return fmt.Errorf("failed to decode NodeFeature %s/%s labels: %w", w.namespace, name, err)
+	}
+	if maps.Equal(current, labels) && u.GetLabels()[NodeNameLabel] == nodeName {
+		counterWrite.Increment(writeUnchanged)
+		return nil
+	}
+
+	u.SetLabels(nodeNameLabels(u.GetLabels(), nodeName))
+	if err := unstructured.SetNestedStringMap(u.Object, labels, "spec", "labels"); err != nil {
+		return fmt.Errorf("failed to encode NodeFeature %s/%s labels: %w", w.namespace, name, err)
+	}
+	if _, err := res.Update(ctx, u, metav1.UpdateOptions{}); err != nil {
+		counterWrite.Increment(writeFailed)
+		return fmt.Errorf("failed to update NodeFeature %s/%s: %w", w.namespace, name, err)
+	}
+	counterWrite.Increment(writeUpdated)
+	log.Debug("updated node feature", "node", nodeName, "labels", len(labels))
+	return nil
+}
+
+func newNodeFeature(namespace, nodeName string, labels map[string]string) *unstructured.Unstructured {
+	u := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"features": map[string]any{},
+			"labels":   toAnyMap(labels),
+		},
+	}}
+	u.SetAPIVersion(NodeFeatureResource.GroupVersion().String())
+	u.SetKind("NodeFeature")
+	u.SetNamespace(namespace)
+	u.SetName(Name(nodeName))
+	u.SetLabels(nodeNameLabels(nil, nodeName))
+	return u
+}
+
+// nodeNameLabels returns labels with the NFD node name label set to nodeName.
+func nodeNameLabels(labels map[string]string, nodeName string) map[string]string {
+	out := maps.Clone(labels)
+	if out == nil {
+		out = make(map[string]string, 1)
+	}
+	out[NodeNameLabel] = nodeName
+	return out
+}
+
+func toAnyMap(m map[string]string) map[string]any {
+	out := make(map[string]any, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,82 @@
+package nfd
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+func getLabels(t *testing.T, client *dynamicfake.FakeDynamicClient, name string) (map[string]string, *unstructured.Unstructured) {
+	t.Helper()
+	u, err := client.Resource(NodeFeatureResource).Namespace("gpuid").Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("failed to get NodeFeature: %v", err)
+	}
+	labels, _, err := unstructured.NestedStringMap(u.Object, "spec", "labels")
+	if err != nil {
+		t.Fatalf("failed to decode NodeFeature labels: %v", err)
+	}
+	return labels, u
+}
+
+func TestWriterWrite(t *testing.T) {
+	client := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{NodeFeatureResource: "NodeFeatureList"})
+	w := NewWriter(client, "gpuid")
+	ctx := context.Background()
+
+	labels := map[string]string{
+		"gpuid.github.com/chassis": "1654922000042",
+		"gpuid.github.com/gpu-0":   "1650924060039",
+	}
+	if err := w.Write(ctx, slog.Default(), "node-1", labels); err != nil {
+		t.Fatalf("Write() unexpected error: %v", err)
+	}
+
+	got, u := getLabels(t, client, Name("node-1"))
+	if len(got) != 2 || got["gpuid.github.com/gpu-0"] != "1650924060039" {
+		t.Errorf("unexpected labels: %v", got)
+	}
+	if u.GetKind() != "NodeFeature" || u.GetLabels()[NodeNameLabel] != "node-1" {
+		t.Errorf("unexpected NodeFeature: %+v", u.Object)
+	}
+	if _, ok, _ := unstructured.NestedMap(u.Object, "spec", "features"); !ok {
+		t.Error("expected empty spec.features")
+	}
+
+	// A second scan drops a GPU; the labels are replaced, not merged.
+	delete(labels, "gpuid.github.com/gpu-0")
+	if err := w.Write(ctx, slog.Default(), "node-1", labels); err != nil {
+		t.Fatalf("second Write() unexpected error: %v", err)
+	}
+	if got, _ := getLabels(t, client, Name("node-1")); len(got) != 1 {
+		t.Errorf("expected 1 label after update, got %v", got)
+	}
+
+	// Unchanged labels don't update the resource.
+	before := len(client.Actions())
+	if err := w.Write(ctx, slog.Default(), "node-1", labels); err != nil {
+		t.Fatalf("third Write() unexpected error: %v", err)
+	}
+	for _, a := range client.Actions()[before:] {
+		if a.GetVerb() != "get" {
+			t.Errorf("unexpected %s for unchanged labels", a.GetVerb())
+		}
+	}
+}
+
+func TestWriterWriteValidation(t *testing.T) {
+	if err := NewWriter(nil, "gpuid").Write(context.Background(), slog.Default(), "node-1", nil); err == nil {
+		t.Error("expected error for nil client")
+	}
+	w := NewWriter(dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()), "gpuid")
+	if err := w.Write(context.Background(), slog.Default(), "", nil); err == nil {
+		t.Error("expected error for empty node name")
+	}
+}
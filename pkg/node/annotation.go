@@ -0,0 +1,138 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+const (
+	// Scan status annotation names; the keys are under the label domain, see
+	// LabelFormat.Key.
+	AnnotationLastScan    = "last-scan"
+	AnnotationLastResult  = "last-result"
+	AnnotationLastError   = "last-error"
+	AnnotationContentHash = "content-hash"
+
+	// Scan results recorded in AnnotationLastResult.
+	ScanSuccess = "success"
+	ScanNoGPU   = "no-gpu"
+	ScanFailure = "failure"
+
+	// maxErrorAnnotation bounds the last-error annotation so a verbose stderr
+	// can't bloat the node object.
+	maxErrorAnnotation = 1024
+
+	// scanRefresh is how stale last-scan may get before a scan with an unchanged
+	// outcome patches the node again; retries and resyncs within it don't.
+	scanRefresh = 10 * time.Minute
+)
+
+// ScanStatus is the outcome of a single scan of a node.
+type ScanStatus struct {
+	Time   time.Time
+	Result string // ScanSuccess, ScanNoGPU or ScanFailure
+	Reason string // Failure class (e.g. driver_error), empty unless Result is ScanFailure
+	Err    error  // Failure cause, nil otherwise
+	Hash   string // ContentHash of the serials read; empty keeps the previous hash
+}
+
+// RecordScan writes the scan status annotations to the node. last-error is removed on
+// success, and content-hash is only updated when the scan produced inventory, so a
+// failed scan does not erase the hash of the last good inventory. The keys are
+// under the domain of f. A scan with the same outcome as the one recorded less
+// than scanRefresh ago is not written, so retries don't patch the node each time.
+func RecordScan(ctx context.Context, log *slog.Logger, updater Updater, f *LabelFormat, nodeName string, st ScanStatus) error {
+	if nodeName == "" {
+		return fmt.Errorf("node name is required")
+	}
+	if updater == nil {
+		return fmt.Errorf("node updater is nil")
+	}
+
+	annotations := map[string]any{
+		f.Key(AnnotationLastScan):   st.Time.UTC().Format(time.RFC3339),
+		f.Key(AnnotationLastResult): st.Result,
+		f.Key(AnnotationLastError):  nil,
+	}
+	if st.Err != nil {
+		msg := st.Err.Error()
+		if st.Reason != "" {
+			msg = st.Reason + ": " + msg
+		}
+		annotations[f.Key(AnnotationLastError)] = truncate(msg, maxErrorAnnotation)
+	}
+	if st.Hash != "" {
+		annotations[f.Key(AnnotationContentHash)] = st.Hash
+	}
+
+	if n, err := updater.GetNode(ctx, nodeName); err == nil && scanRecorded(n.Annotations, annotations, f, st.Time) {
+		log.Debug("node scan status unchanged", "node", nodeName, "result", st.Result)
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal scan status patch: %w", err)
+	}
+
+	if err := updater.PatchNode(ctx, nodeName, patch); err != nil {
+		return fmt.Errorf("failed to patch scan status on node %s: %w", nodeName, err)
+	}
+
+	log.Debug("recorded node scan status", "node", nodeName, "result", st.Result)
+	return nil
+}
+
+// scanRecorded reports whether current already holds the desired scan status
+// annotations, with a last-scan less than scanRefresh before now.
+func scanRecorded(current map[string]string, desired map[string]any, f *LabelFormat, now time.Time) bool {
+	for k, v := range desired {
+		if k == f.Key(AnnotationLastScan) {
+			continue
+		}
+		cur, ok := current[k]
+		if s, set := v.(string); set != ok || (set && cur != s) {
+			return false
+		}
+	}
+	last, err := time.Parse(time.RFC3339, current[f.Key(AnnotationLastScan)])
+	return err == nil && now.Sub(last) < scanRefresh
+}
+
+// ContentHash returns a short, order-independent hash of the GPU inventory so a
+// change in the hardware behind a node is visible without diffing labels.
+func ContentHash(serials []*gpu.Serials) string {
+	entries := make([]string, 0)
+	for _, s := range serials {
+		if s == nil {
+			continue
+		}
+		for _, g := range s.GPU {
+			entries = append(entries, s.Chassis+"/"+g)
+		}
+	}
+	sort.Strings(entries)
+
+	sum := sha256.Sum256([]byte(strings.Join(entries, "\n")))
+	return hex.EncodeToString(sum[:8])
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,100 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+func TestRecordScan(t *testing.T) {
+	n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "n1"}}
+	m := NewMockUpdater(n)
+	ctx := context.Background()
+	log := slog.Default()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	f := mustLabelFormat(LabelFormatConfig{Domain: "hw.example.com"})
+
+	if err := RecordScan(ctx, log, m, f, "n1", ScanStatus{Time: now, Result: ScanSuccess, Hash: "abc"}); err != nil {
+		t.Fatalf("RecordScan() unexpected error: %v", err)
+	}
+	if got := n.Annotations["hw.example.com/last-scan"]; got != "2026-01-02T03:04:05Z" {
+		t.Errorf("last-scan = %q", got)
+	}
+	if got := n.Annotations[f.Key(AnnotationContentHash)]; got != "abc" {
+		t.Errorf("content-hash = %q", got)
+	}
+
+	longErr := errors.New(strings.Repeat("x", 2*maxErrorAnnotation))
+	if err := RecordScan(ctx, log, m, f, "n1", ScanStatus{Time: now, Result: ScanFailure, Err: longErr}); err != nil {
+		t.Fatalf("RecordScan() unexpected error: %v", err)
+	}
+	if got := n.Annotations[f.Key(AnnotationLastResult)]; got != ScanFailure {
+		t.Errorf("last-result = %q, want %q", got, ScanFailure)
+	}
+	if got := len(n.Annotations[f.Key(AnnotationLastError)]); got != maxErrorAnnotation {
+		t.Errorf("last-error length = %d, want %d", got, maxErrorAnnotation)
+	}
+	if got := n.Annotations[f.Key(AnnotationContentHash)]; got != "abc" {
+		t.Errorf("failed scan should keep content-hash, got %q", got)
+	}
+
+	if err := RecordScan(ctx, log, m, f, "n1", ScanStatus{Time: now, Result: ScanSuccess, Hash: "def"}); err != nil {
+		t.Fatalf("RecordScan() unexpected error: %v", err)
+	}
+	if _, ok := n.Annotations[f.Key(AnnotationLastError)]; ok {
+		t.Error("successful scan should remove last-error")
+	}
+
+	// An unchanged outcome is only written again once last-scan is stale.
+	patches := m.updateCount
+	if err := RecordScan(ctx, log, m, f, "n1", ScanStatus{Time: now.Add(time.Minute), Result: ScanSuccess, Hash: "def"}); err != nil {
+		t.Fatalf("RecordScan() unexpected error: %v", err)
+	}
+	if m.updateCount != patches {
+		t.Errorf("unchanged scan within %v should not patch the node", scanRefresh)
+	}
+	if err := RecordScan(ctx, log, m, f, "n1", ScanStatus{Time: now.Add(scanRefresh), Result: ScanSuccess, Hash: "def"}); err != nil {
+		t.Fatalf("RecordScan() unexpected error: %v", err)
+	}
+	if m.updateCount != patches+1 {
+		t.Error("unchanged scan after the refresh interval should patch the node")
+	}
+
+	if err := RecordScan(ctx, log, nil, f, "n1", ScanStatus{}); err == nil {
+		t.Error("expected error for nil updater")
+	}
+}
+
+func TestContentHash(t *testing.T) {
+	a := []*gpu.Serials{
+		{Chassis: "c1", GPU: []string{"g1", "g2"}},
+		{Chassis: "c2", GPU: []string{"g3"}},
+	}
+	b := []*gpu.Serials{
+		{Chassis: "c2", GPU: []string{"g3"}},
+		nil,
+		{Chassis: "c1", GPU: []string{"g2", "g1"}},
+	}
+	c := []*gpu.Serials{
+		{Chassis: "c1", GPU: []string{"g1", "g4"}},
+		{Chassis: "c2", GPU: []string{"g3"}},
+	}
+
+	if ContentHash(a) != ContentHash(b) {
+		t.Error("hash should not depend on order")
+	}
+	if ContentHash(a) == ContentHash(c) {
+		t.Error("hash should change when a GPU changes")
+	}
+	if len(ContentHash(a)) != 16 {
+		t.Errorf("unexpected hash length: %q", ContentHash(a))
+	}
+}
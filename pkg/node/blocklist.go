@@ -0,0 +1,91 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+)
+
+// AnnotationBlocklisted names the annotation, under the label domain, that lists
+// the blocklisted serial numbers found on the node.
+const AnnotationBlocklisted = "blocklisted-serials"
+
+var gaugeBlocklisted = counter.NewGauge("gpuid_blocklisted_gpu", "Blocklisted GPU or chassis serial numbers found on a node (1 while present)", "node", "serial")
+
+// MarkBlocklisted records the blocklisted serials found on nodeName in
+// AnnotationBlocklisted and the gpuid_blocklisted_gpu gauge, removing both once
+// the serials are gone. When cordon is true a node with matches is marked
+// unschedulable; it is never uncordoned automatically since the part may still
+// need to be pulled. It reports whether the set of serials on the node changed.
+// The annotation key is under the domain of f.
+func MarkBlocklisted(ctx context.Context, log *slog.Logger, updater Updater, f *LabelFormat, nodeName string, matches []string, cordon bool) (bool, error) {
+	if nodeName == "" {
+		return false, fmt.Errorf("node name is required")
+	}
+	if updater == nil {
+		return false, fmt.Errorf("node updater is nil")
+	}
+
+	n, err := updater.GetNode(ctx, nodeName)
+	if err != nil {
+		return false, fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+
+	current := n.Annotations[f.Key(AnnotationBlocklisted)]
+	desired := strings.Join(matches, ",")
+
+	for _, s := range splitAnnotationList(current) {
+		gaugeBlocklisted.Delete(nodeName, s)
+	}
+	for _, s := range matches {
+		gaugeBlocklisted.Set(1, nodeName, s)
+	}
+
+	doCordon := cordon && len(matches) > 0 && !n.Spec.Unschedulable
+	changed := current != desired
+	if !changed && !doCordon {
+		return false, nil
+	}
+
+	var value any
+	if desired != "" {
+		value = desired
+	}
+	body := map[string]any{
+		"metadata": map[string]any{
+			"annotations": map[string]any{f.Key(AnnotationBlocklisted): value},
+		},
+	}
+	if doCordon {
+		body["spec"] = map[string]any{"unschedulable": true}
+	}
+
+	patch, err := json.Marshal(body)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal blocklist patch: %w", err)
+	}
+	if err := updater.PatchNode(ctx, nodeName, patch); err != nil {
+		return false, fmt.Errorf("failed to patch blocklist status on node %s: %w", nodeName, err)
+	}
+
+	switch {
+	case doCordon:
+		log.Warn("cordoned node with blocklisted serials", "node", nodeName, "serials", desired)
+	case desired != "":
+		log.Warn("blocklisted serials found on node", "node", nodeName, "serials", desired)
+	default:
+		log.Info("blocklisted serials no longer found on node", "node", nodeName, "previous", current)
+	}
+	return changed, nil
+}
+
+func splitAnnotationList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
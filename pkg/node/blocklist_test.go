@@ -0,0 +1,63 @@
+package node
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestMarkBlocklisted(t *testing.T) {
+	ctx := context.Background()
+	log := slog.Default()
+
+	tests := []struct {
+		name   string
+		cordon bool
+	}{
+		{name: "annotate only", cordon: false},
+		{name: "annotate and cordon", cordon: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMockUpdater(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1"}})
+
+			changed, err := MarkBlocklisted(ctx, log, m, nil, "node1", nil, tt.cordon)
+			if err != nil || changed || m.updateCount != 0 {
+				t.Fatalf("clean node: changed=%v err=%v patches=%d, want no change", changed, err, m.updateCount)
+			}
+
+			matches := []string{"1652823054567", "1821325191344"}
+			changed, err = MarkBlocklisted(ctx, log, m, nil, "node1", matches, tt.cordon)
+			if err != nil || !changed {
+				t.Fatalf("match: changed=%v err=%v, want change", changed, err)
+			}
+			if got := m.node.Annotations[defaultLabelFormat.Key(AnnotationBlocklisted)]; got != "1652823054567,1821325191344" {
+				t.Errorf("annotation = %q", got)
+			}
+			if m.node.Spec.Unschedulable != tt.cordon {
+				t.Errorf("unschedulable = %v, want %v", m.node.Spec.Unschedulable, tt.cordon)
+			}
+
+			// Unchanged matches are a no-op.
+			patches := m.updateCount
+			if changed, err = MarkBlocklisted(ctx, log, m, nil, "node1", matches, tt.cordon); err != nil || changed || m.updateCount != patches {
+				t.Errorf("repeat: changed=%v err=%v patches=%d, want no-op", changed, err, m.updateCount-patches)
+			}
+
+			// Cleared matches remove the annotation but leave the node cordoned.
+			if changed, err = MarkBlocklisted(ctx, log, m, nil, "node1", nil, tt.cordon); err != nil || !changed {
+				t.Fatalf("clear: changed=%v err=%v, want change", changed, err)
+			}
+			if _, ok := m.node.Annotations[defaultLabelFormat.Key(AnnotationBlocklisted)]; ok {
+				t.Error("annotation not removed")
+			}
+			if m.node.Spec.Unschedulable != tt.cordon {
+				t.Errorf("unschedulable after clear = %v, want %v", m.node.Spec.Unschedulable, tt.cordon)
+			}
+		})
+	}
+}
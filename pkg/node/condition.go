@@ -0,0 +1,158 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ConditionGPUIdentityVerified is True when the last scan read the node's GPU serials.
+	ConditionGPUIdentityVerified corev1.NodeConditionType = "GPUIdentityVerified"
+
+	// ConditionGPUInventoryChanged is True when the last scan found a different set of
+	// GPUs than the scan before it.
+	ConditionGPUInventoryChanged corev1.NodeConditionType = "GPUInventoryChanged"
+
+	// Condition reasons not derived from a failure class.
+	reasonSerialsRead       = "SerialsRead"
+	reasonNoGPUFound        = "NoGPUFound"
+	reasonInventoryChanged  = "InventoryChanged"
+	reasonInventoryStable   = "InventoryUnchanged"
+	reasonScanFailedDefault = "ScanFailed"
+)
+
+// ScanConditions builds the node conditions for a scan. identity adds
+// GPUIdentityVerified; inventory adds GPUInventoryChanged when the scan produced a
+// hash to compare with prevHash (the first successful scan is not a change).
+func ScanConditions(st ScanStatus, prevHash string, identity, inventory bool) []corev1.NodeCondition {
+	var conds []corev1.NodeCondition
+
+	if identity {
+		c := corev1.NodeCondition{Type: ConditionGPUIdentityVerified}
+		switch st.Result {
+		case ScanSuccess:
+			c.Status = corev1.ConditionTrue
+			c.Reason = reasonSerialsRead
+			c.Message = "GPU serial numbers read and labeled by gpuid"
+		case ScanNoGPU:
+			c.Status = corev1.ConditionFalse
+			c.Reason = reasonNoGPUFound
+			c.Message = "nvidia-smi reported no GPUs"
+		default:
+			c.Status = corev1.ConditionFalse
+			c.Reason = conditionReason(st.Reason)
+			if st.Err != nil {
+				c.Message = truncate(st.Err.Error(), maxErrorAnnotation)
+			}
+		}
+		conds = append(conds, c)
+	}
+
+	if inventory && st.Hash != "" && prevHash != "" {
+		c := corev1.NodeCondition{
+			Type:    ConditionGPUInventoryChanged,
+			Status:  corev1.ConditionFalse,
+			Reason:  reasonInventoryStable,
+			Message: fmt.Sprintf("GPU inventory hash %s", st.Hash),
+		}
+		if st.Hash != prevHash {
+			c.Status = corev1.ConditionTrue
+			c.Reason = reasonInventoryChanged
+			c.Message = fmt.Sprintf("GPU inventory hash changed from %s to %s", prevHash, st.Hash)
+		}
+		conds = append(conds, c)
+	}
+
+	return conds
+}
+
+// SetConditions merges conds into the node's status conditions. A condition is only
+// written when its status, reason or message changed; lastTransitionTime moves only
+// when the status flips, matching how the kubelet maintains its own conditions.
+func SetConditions(ctx context.Context, log *slog.Logger, updater Updater, nodeName string, conds []corev1.NodeCondition) error {
+	if len(conds) == 0 {
+		return nil
+	}
+	if nodeName == "" {
+		return fmt.Errorf("node name is required")
+	}
+	if updater == nil {
+		return fmt.Errorf("node updater is nil")
+	}
+
+	n, err := updater.GetNode(ctx, nodeName)
+	if err != nil {
+		return fmt.Errorf("failed to get node %s: %w", nodeName, err)
+	}
+
+	now := metav1.NewTime(time.Now())
+	changed := make([]corev1.NodeCondition, 0, len(conds))
+	for _, c := range conds {
+		c.LastHeartbeatTime = now
+		c.LastTransitionTime = now
+
+		if cur := findCondition(n.Status.Conditions, c.Type); cur != nil {
+			if cur.Status == c.Status && cur.Reason == c.Reason && cur.Message == c.Message {
+				continue
+			}
+			if cur.Status == c.Status {
+				c.LastTransitionTime = cur.LastTransitionTime
+			}
+		}
+		changed = append(changed, c)
+	}
+
+	if len(changed) == 0 {
+		log.Debug("node conditions up to date", "node", nodeName)
+		return nil
+	}
+
+	// Conditions merge on "type" in a strategic merge patch, so other writers'
+	// conditions (kubelet, node-problem-detector) are left untouched.
+	patch, err := json.Marshal(map[string]any{
+		"status": map[string]any{
+			"conditions": changed,
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal condition patch: %w", err)
+	}
+
+	if err := updater.PatchNodeStatus(ctx, nodeName, patch); err != nil {
+		return fmt.Errorf("failed to patch conditions on node %s: %w", nodeName, err)
+	}
+
+	log.Debug("updated node conditions", "node", nodeName, "conditions", len(changed))
+	return nil
+}
+
+func findCondition(conds []corev1.NodeCondition, t corev1.NodeConditionType) *corev1.NodeCondition {
+	for i := range conds {
+		if conds[i].Type == t {
+			return &conds[i]
+		}
+	}
+	return nil
+}
+
+// conditionReason converts a snake_case failure class into a CamelCase condition reason.
+func conditionReason(class string) string {
+	if class == "" {
+		return reasonScanFailedDefault
+	}
+	var b strings.Builder
+	for _, part := range strings.Split(class, "_") {
+		if part == "" {
+			continue
+		}
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
@@ -0,0 +1,132 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestScanConditions(t *testing.T) {
+	tests := []struct {
+		name       string
+		st         ScanStatus
+		prevHash   string
+		identity   bool
+		inventory  bool
+		wantTypes  []corev1.NodeConditionType
+		wantStatus []corev1.ConditionStatus
+		wantReason []string
+	}{
+		{
+			name:       "success",
+			st:         ScanStatus{Result: ScanSuccess, Hash: "h1"},
+			identity:   true,
+			wantTypes:  []corev1.NodeConditionType{ConditionGPUIdentityVerified},
+			wantStatus: []corev1.ConditionStatus{corev1.ConditionTrue},
+			wantReason: []string{reasonSerialsRead},
+		},
+		{
+			name:       "failure reason is camel cased",
+			st:         ScanStatus{Result: ScanFailure, Reason: "driver_error", Err: errors.New("exit 9")},
+			identity:   true,
+			inventory:  true,
+			prevHash:   "h1",
+			wantTypes:  []corev1.NodeConditionType{ConditionGPUIdentityVerified},
+			wantStatus: []corev1.ConditionStatus{corev1.ConditionFalse},
+			wantReason: []string{"DriverError"},
+		},
+		{
+			name:      "first scan is not an inventory change",
+			st:        ScanStatus{Result: ScanSuccess, Hash: "h1"},
+			inventory: true,
+		},
+		{
+			name:       "inventory changed",
+			st:         ScanStatus{Result: ScanSuccess, Hash: "h2"},
+			prevHash:   "h1",
+			inventory:  true,
+			wantTypes:  []corev1.NodeConditionType{ConditionGPUInventoryChanged},
+			wantStatus: []corev1.ConditionStatus{corev1.ConditionTrue},
+			wantReason: []string{reasonInventoryChanged},
+		},
+		{
+			name:       "inventory unchanged",
+			st:         ScanStatus{Result: ScanSuccess, Hash: "h1"},
+			prevHash:   "h1",
+			inventory:  true,
+			wantTypes:  []corev1.NodeConditionType{ConditionGPUInventoryChanged},
+			wantStatus: []corev1.ConditionStatus{corev1.ConditionFalse},
+			wantReason: []string{reasonInventoryStable},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ScanConditions(tt.st, tt.prevHash, tt.identity, tt.inventory)
+			if len(got) != len(tt.wantTypes) {
+				t.Fatalf("ScanConditions() = %+v, want %d conditions", got, len(tt.wantTypes))
+			}
+			for i := range got {
+				if got[i].Type != tt.wantTypes[i] || got[i].Status != tt.wantStatus[i] || got[i].Reason != tt.wantReason[i] {
+					t.Errorf("condition %d = %s/%s/%s, want %s/%s/%s", i,
+						got[i].Type, got[i].Status, got[i].Reason,
+						tt.wantTypes[i], tt.wantStatus[i], tt.wantReason[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSetConditions(t *testing.T) {
+	past := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	n := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "n1"},
+		Status: corev1.NodeStatus{
+			Conditions: []corev1.NodeCondition{
+				{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+				{Type: ConditionGPUIdentityVerified, Status: corev1.ConditionTrue, Reason: "Old", LastTransitionTime: past},
+			},
+		},
+	}
+	m := NewMockUpdater(n)
+	ctx := context.Background()
+	log := slog.Default()
+
+	verified := ScanConditions(ScanStatus{Result: ScanSuccess}, "", true, false)
+
+	// Same status, new reason: patched but the transition time is kept.
+	if err := SetConditions(ctx, log, m, "n1", verified); err != nil {
+		t.Fatalf("SetConditions() unexpected error: %v", err)
+	}
+	c := findCondition(n.Status.Conditions, ConditionGPUIdentityVerified)
+	if c == nil || c.Reason != reasonSerialsRead || !c.LastTransitionTime.Equal(&past) {
+		t.Errorf("unexpected condition after reason change: %+v", c)
+	}
+	if findCondition(n.Status.Conditions, corev1.NodeReady) == nil {
+		t.Error("other conditions must be preserved")
+	}
+
+	// Unchanged: no patch.
+	before := m.updateCount
+	if err := SetConditions(ctx, log, m, "n1", verified); err != nil {
+		t.Fatalf("SetConditions() unexpected error: %v", err)
+	}
+	if m.updateCount != before {
+		t.Error("unchanged conditions should not be patched")
+	}
+
+	// Status flip moves the transition time.
+	failed := ScanConditions(ScanStatus{Result: ScanFailure, Reason: "parse_error", Err: errors.New("bad xml")}, "", true, false)
+	if err := SetConditions(ctx, log, m, "n1", failed); err != nil {
+		t.Fatalf("SetConditions() unexpected error: %v", err)
+	}
+	c = findCondition(n.Status.Conditions, ConditionGPUIdentityVerified)
+	if c == nil || c.Status != corev1.ConditionFalse || c.LastTransitionTime.Equal(&past) {
+		t.Errorf("unexpected condition after status flip: %+v", c)
+	}
+}
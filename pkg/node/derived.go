@@ -0,0 +1,109 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+const (
+	// Derived label names, under the active label domain.
+	labelProduct       = "product"
+	labelArchitecture  = "architecture"
+	labelGPUCount      = "gpu-count"
+	labelDriverVersion = "driver-version"
+	labelMIGMode       = "mig-mode"
+
+	// mixedValue is used when GPUs on the node disagree on a derived attribute.
+	mixedValue = "mixed"
+)
+
+// calculateDerivedLabels returns node-level labels describing the GPUs rather than
+// identifying them: product name, architecture, driver version, MIG mode and the
+// GPU count per chassis. Attributes that differ between GPUs are labeled "mixed";
+// attributes nvidia-smi didn't report are omitted.
+func calculateDerivedLabels(f *LabelFormat, serials []*gpu.Serials) map[string]string {
+	labels := make(map[string]string)
+
+	chassis := make([]*gpu.Serials, 0, len(serials))
+	for _, s := range serials {
+		if s != nil {
+			chassis = append(chassis, s)
+		}
+	}
+	if len(chassis) == 0 {
+		return labels
+	}
+	sort.Slice(chassis, func(i, j int) bool { return chassis[i].Chassis < chassis[j].Chassis })
+
+	var products, archs, migModes, drivers []string
+	for i, s := range chassis {
+		devices := chassisDevices(s)
+
+		// GPU count per chassis; named after the chassis label when there are several.
+		if key, ok := chassisCountKey(f, s, i, len(chassis) > 1); ok {
+			if _, taken := labels[key]; !taken {
+				labels[key] = fmt.Sprintf("%d", len(devices))
+			}
+		}
+
+		drivers = append(drivers, s.Driver)
+		for _, d := range devices {
+			products = append(products, d.Model)
+			archs = append(archs, d.Architecture)
+			migModes = append(migModes, d.MIGMode)
+		}
+	}
+
+	for name, values := range map[string][]string{
+		labelProduct:       products,
+		labelArchitecture:  archs,
+		labelMIGMode:       migModes,
+		labelDriverVersion: drivers,
+	} {
+		if v := commonValue(values); v != "" {
+			labels[f.Key(name)] = sanitizeLabelValue(truncate(v, maxLabelValue))
+		}
+	}
+
+	return labels
+}
+
+// chassisCountKey returns the key of the GPU count label of chassis s at index i:
+// gpu-count on a single chassis, otherwise the chassis label key rendered for s
+// with a -gpu-count suffix. It reports false when that is not a valid label key.
+func chassisCountKey(f *LabelFormat, s *gpu.Serials, i int, multi bool) (string, bool) {
+	if !multi {
+		return f.Key(labelGPUCount), true
+	}
+	data := LabelData{ChassisIndex: i, MultiChassis: true}
+	if c := sanitizeLabelValue(s.Chassis); c != notSetDefault {
+		data.Chassis = c
+	}
+	ck, err := f.key(f.chassisKey, data)
+	if err != nil {
+		return "", false
+	}
+	key := ck + "-" + labelGPUCount
+	return key, len(validation.IsQualifiedName(key)) == 0
+}
+
+// commonValue returns the single non-empty value in values, mixedValue when they
+// disagree, or "" when none is set.
+func commonValue(values []string) string {
+	var out string
+	for _, v := range values {
+		switch {
+		case v == "":
+			continue
+		case out == "":
+			out = v
+		case out != v:
+			return mixedValue
+		}
+	}
+	return out
+}
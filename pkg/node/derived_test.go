@@ -0,0 +1,113 @@
+package node
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+func TestCalculateDerivedLabels(t *testing.T) {
+	h100 := func(serial, uuid, mig string) gpu.Device {
+		return gpu.Device{Serial: serial, UUID: uuid, Model: "NVIDIA H100 80GB HBM3", Architecture: "Hopper", MIGMode: mig}
+	}
+
+	tests := []struct {
+		name    string
+		format  LabelFormatConfig
+		serials []*gpu.Serials
+		want    map[string]string
+	}{
+		{
+			name:    "no GPUs",
+			serials: nil,
+			want:    map[string]string{},
+		},
+		{
+			name: "single chassis",
+			serials: []*gpu.Serials{{
+				GPU:     []string{"1", "2"},
+				Driver:  "570.86.15",
+				Devices: []gpu.Device{h100("1", "GPU-a", "Disabled"), h100("2", "GPU-b", "Disabled")},
+			}},
+			want: map[string]string{
+				"gpuid.github.com/product":        "NVIDIA-H100-80GB-HBM3",
+				"gpuid.github.com/architecture":   "Hopper",
+				"gpuid.github.com/gpu-count":      "2",
+				"gpuid.github.com/driver-version": "570.86.15",
+				"gpuid.github.com/mig-mode":       "Disabled",
+			},
+		},
+		{
+			name: "multiple chassis with mixed MIG mode",
+			serials: []*gpu.Serials{
+				{Chassis: "c2", GPU: []string{"3"}, Driver: "570.86.15", Devices: []gpu.Device{h100("3", "GPU-c", "Enabled")}},
+				{Chassis: "c1", GPU: []string{"1", "2"}, Driver: "570.86.15", Devices: []gpu.Device{h100("1", "GPU-a", "Disabled"), h100("2", "GPU-b", "Disabled")}},
+			},
+			want: map[string]string{
+				"gpuid.github.com/product":             "NVIDIA-H100-80GB-HBM3",
+				"gpuid.github.com/architecture":        "Hopper",
+				"gpuid.github.com/chassis-0-gpu-count": "2",
+				"gpuid.github.com/chassis-1-gpu-count": "1",
+				"gpuid.github.com/driver-version":      "570.86.15",
+				"gpuid.github.com/mig-mode":            "mixed",
+			},
+		},
+		{
+			name:   "multiple chassis with a custom chassis key",
+			format: LabelFormatConfig{Domain: "hw.example.com", ChassisKey: "host-{{.Chassis}}"},
+			serials: []*gpu.Serials{
+				{Chassis: "c2", GPU: []string{"3"}},
+				{Chassis: "c1", GPU: []string{"1", "2"}},
+			},
+			want: map[string]string{
+				"hw.example.com/host-c1-gpu-count": "2",
+				"hw.example.com/host-c2-gpu-count": "1",
+			},
+		},
+		{
+			name:    "serials without device details",
+			serials: []*gpu.Serials{{GPU: []string{"1", "2", "3"}}},
+			want:    map[string]string{"gpuid.github.com/gpu-count": "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := calculateDerivedLabels(mustLabelFormat(tt.format), tt.serials)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calculateDerivedLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureLabelsDerived(t *testing.T) {
+	ctx := context.Background()
+	m := NewMockUpdater(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "test-node"}})
+	serials := []*gpu.Serials{{
+		GPU:     []string{"1652823054567"},
+		Devices: []gpu.Device{{Serial: "1652823054567", UUID: "GPU-a", Model: "NVIDIA H100 80GB HBM3"}},
+	}}
+
+	if err := EnsureLabels(ctx, slog.Default(), m, "test-node", serials, LabelOptions{Derived: true}); err != nil {
+		t.Fatalf("EnsureLabels() unexpected error: %v", err)
+	}
+	if m.node.Labels["gpuid.github.com/product"] != "NVIDIA-H100-80GB-HBM3" || m.node.Labels["gpuid.github.com/gpu-0"] != "1652823054567" {
+		t.Errorf("unexpected labels: %v", m.node.Labels)
+	}
+
+	// Turning derived labels off removes them and keeps the identity labels.
+	if err := EnsureLabels(ctx, slog.Default(), m, "test-node", serials, LabelOptions{}); err != nil {
+		t.Fatalf("EnsureLabels() unexpected error: %v", err)
+	}
+	want := map[string]string{"gpuid.github.com/gpu-0": "1652823054567"}
+	if !reflect.DeepEqual(m.node.Labels, want) {
+		t.Errorf("labels = %v, want %v", m.node.Labels, want)
+	}
+}
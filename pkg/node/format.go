@@ -0,0 +1,205 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	// DefaultLabelDomain is the label key prefix used when none is configured.
+	DefaultLabelDomain = labelNS
+
+	// Default templates reproduce the original fixed label keys: gpu-N on a single
+	// chassis, chassis-I and chassis-I-gpu-N when a node hosts several chassis.
+	DefaultChassisKeyTemplate = `chassis{{if .MultiChassis}}-{{.ChassisIndex}}{{end}}`
+	DefaultGPUKeyTemplate     = `{{if and .MultiChassis .Chassis}}chassis-{{.ChassisIndex}}-{{end}}gpu-{{.Index}}`
+	DefaultGPUValueTemplate   = `{{.Serial}}`
+
+	// maxLabelValue is the Kubernetes limit on label values.
+	maxLabelValue = 63
+)
+
+// defaultLabelFormat is used when LabelOptions carries no format.
+var defaultLabelFormat = mustLabelFormat(LabelFormatConfig{})
+
+// LabelData is the input of the label key and value templates. Chassis templates
+// only see the chassis fields.
+type LabelData struct {
+	Chassis      string // Sanitized chassis serial, empty when not reported
+	ChassisIndex int    // Position of the chassis, sorted by serial
+	MultiChassis bool   // The node hosts more than one chassis
+
+	Index       int    // Position of the GPU serial within its chassis, sorted; dies sharing a serial share an index
+	DeviceIndex int    // Position of the GPU within its chassis, sorted by UUID
+	Serial      string // GPU serial number
+	UUID        string // GPU UUID, empty when not reported
+	Model       string // Product name, e.g. NVIDIA H100 80GB HBM3
+	ModuleID    string // Module ID from nvidia-smi platform info
+}
+
+// LabelFormatConfig holds the unparsed label format settings. Empty fields use
+// the defaults.
+type LabelFormatConfig struct {
+	Domain      string   // Label key prefix, e.g. hw.example.com
+	ChassisKey  string   // Template for the chassis label name (after the domain)
+	GPUKey      string   // Template for the GPU label names (after the domain)
+	GPUValue    string   // Template for the GPU label values
+	MigrateFrom []string // Previous domains whose labels are removed
+}
+
+// LabelFormat renders label keys and values and decides which node labels gpuid
+// owns. Stale labels are only removed under the active domain and the domains
+// being migrated from, so the domain should not be shared with other writers.
+type LabelFormat struct {
+	domain      string
+	migrateFrom []string
+	chassisKey  *template.Template
+	gpuKey      *template.Template
+	gpuValue    *template.Template
+
+	// readable is set when all templates are the defaults, so the GPU labels of a
+	// node can be read back into serials.
+	readable bool
+}
+
+// NewLabelFormat parses and validates the label format. Templates are executed
+// against sample data so a key that renders to an invalid label name fails here
+// rather than on the first node.
+func NewLabelFormat(cfg LabelFormatConfig) (*LabelFormat, error) {
+	f := &LabelFormat{domain: orDefault(cfg.Domain, DefaultLabelDomain)}
+	if errs := validation.IsDNS1123Subdomain(f.domain); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid label domain %q: %s", f.domain, strings.Join(errs, "; "))
+	}
+
+	for _, d := range cfg.MigrateFrom {
+		if d == f.domain {
+			return nil, fmt.Errorf("label domain %q cannot also be migrated from", d)
+		}
+		if errs := validation.IsDNS1123Subdomain(d); len(errs) > 0 {
+			return nil, fmt.Errorf("invalid migration label domain %q: %s", d, strings.Join(errs, "; "))
+		}
+		f.migrateFrom = append(f.migrateFrom, d)
+	}
+
+	var err error
+	if f.chassisKey, err = parseLabelTemplate("chassis-key", orDefault(cfg.ChassisKey, DefaultChassisKeyTemplate)); err != nil {
+		return nil, err
+	}
+	if f.gpuKey, err = parseLabelTemplate("gpu-key", orDefault(cfg.GPUKey, DefaultGPUKeyTemplate)); err != nil {
+		return nil, err
+	}
+	if f.gpuValue, err = parseLabelTemplate("gpu-value", orDefault(cfg.GPUValue, DefaultGPUValueTemplate)); err != nil {
+		return nil, err
+	}
+
+	f.readable = orDefault(cfg.ChassisKey, DefaultChassisKeyTemplate) == DefaultChassisKeyTemplate &&
+		orDefault(cfg.GPUKey, DefaultGPUKeyTemplate) == DefaultGPUKeyTemplate &&
+		orDefault(cfg.GPUValue, DefaultGPUValueTemplate) == DefaultGPUValueTemplate
+
+	sample := LabelData{
+		Chassis:      "1821325191344",
+		ChassisIndex: 1,
+		MultiChassis: true,
+		Index:        1,
+		DeviceIndex:  1,
+		Serial:       "1652824032109",
+		UUID:         "GPU-25e04bdf-6a27-0a2d-f989-a9228a03f373",
+		Model:        "NVIDIA GB200",
+		ModuleID:     "2",
+	}
+	for _, t := range []*template.Template{f.chassisKey, f.gpuKey} {
+		if _, err := f.key(t, sample); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := f.value(f.gpuValue, sample); err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func mustLabelFormat(cfg LabelFormatConfig) *LabelFormat {
+	f, err := NewLabelFormat(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Domain returns the active label domain.
+func (f *LabelFormat) Domain() string {
+	return f.domain
+}
+
+// Key returns the full label or annotation key for name under the active
+// domain. A nil format uses the default domain.
+func (f *LabelFormat) Key(name string) string {
+	if f == nil {
+		return DefaultLabelDomain + "/" + name
+	}
+	return f.domain + "/" + name
+}
+
+// owns reports whether key is a gpuid label: under the active domain or a domain
+// being migrated from. Owned labels that are no longer desired are removed.
+func (f *LabelFormat) owns(key string) bool {
+	if strings.HasPrefix(key, f.domain+"/") {
+		return true
+	}
+	for _, d := range f.migrateFrom {
+		if strings.HasPrefix(key, d+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// key renders a label name template and returns the full, validated label key.
+func (f *LabelFormat) key(t *template.Template, data LabelData) (string, error) {
+	name, err := render(t, data)
+	if err != nil {
+		return "", err
+	}
+	key := f.Key(name)
+	if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+		return "", fmt.Errorf("template %s rendered invalid label key %q: %s", t.Name(), key, strings.Join(errs, "; "))
+	}
+	return key, nil
+}
+
+// value renders a label value template into a valid label value.
+func (f *LabelFormat) value(t *template.Template, data LabelData) (string, error) {
+	v, err := render(t, data)
+	if err != nil {
+		return "", err
+	}
+	return sanitizeLabelValue(truncate(v, maxLabelValue)), nil
+}
+
+func parseLabelTemplate(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	return t, nil
+}
+
+func render(t *template.Template, data LabelData) (string, error) {
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template: %w", t.Name(), err)
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
+
+func orDefault(s, def string) string {
+	if strings.TrimSpace(s) == "" {
+		return def
+	}
+	return s
+}
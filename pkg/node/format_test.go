@@ -0,0 +1,148 @@
+package node
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+// gb200Serials is one chassis with two dual-die modules: four GPUs, two serials.
+var gb200Serials = []*gpu.Serials{{
+	Chassis: "1821325191344",
+	GPU:     []string{"1761025346615", "1761125340953"},
+	Devices: []gpu.Device{
+		{Serial: "1761025346615", UUID: "GPU-25e04bdf", Model: "NVIDIA GB200", ModuleID: "2"},
+		{Serial: "1761025346615", UUID: "GPU-a0540bed", Model: "NVIDIA GB200", ModuleID: "1"},
+		{Serial: "1761125340953", UUID: "GPU-aa321f5a", Model: "NVIDIA GB200", ModuleID: "3"},
+		{Serial: "1761125340953", UUID: "GPU-fd2305c8", Model: "NVIDIA GB200", ModuleID: "4"},
+	},
+}}
+
+func TestCalculateGPULabelsFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  LabelFormatConfig
+		want map[string]string
+	}{
+		{
+			name: "default format keeps one label per serial",
+			want: map[string]string{
+				"gpuid.github.com/chassis":       "1821325191344",
+				"gpuid.github.com/chassis-count": "1",
+				"gpuid.github.com/gpu-0":         "1761025346615",
+				"gpuid.github.com/gpu-1":         "1761125340953",
+			},
+		},
+		{
+			name: "custom domain keyed by module ID",
+			cfg:  LabelFormatConfig{Domain: "hw.ourco.io", ChassisKey: "host-serial", GPUKey: "gpu-module-{{.ModuleID}}"},
+			want: map[string]string{
+				"hw.ourco.io/host-serial":   "1821325191344",
+				"hw.ourco.io/chassis-count": "1",
+				"hw.ourco.io/gpu-module-1":  "1761025346615",
+				"hw.ourco.io/gpu-module-2":  "1761025346615",
+				"hw.ourco.io/gpu-module-3":  "1761125340953",
+				"hw.ourco.io/gpu-module-4":  "1761125340953",
+			},
+		},
+		{
+			name: "keyed by UUID with model value",
+			cfg:  LabelFormatConfig{GPUKey: "{{.UUID}}", GPUValue: "{{.Model}}"},
+			want: map[string]string{
+				"gpuid.github.com/chassis":       "1821325191344",
+				"gpuid.github.com/chassis-count": "1",
+				"gpuid.github.com/GPU-25e04bdf":  "NVIDIA-GB200",
+				"gpuid.github.com/GPU-a0540bed":  "NVIDIA-GB200",
+				"gpuid.github.com/GPU-aa321f5a":  "NVIDIA-GB200",
+				"gpuid.github.com/GPU-fd2305c8":  "NVIDIA-GB200",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewLabelFormat(tt.cfg)
+			if err != nil {
+				t.Fatalf("NewLabelFormat() unexpected error: %v", err)
+			}
+			got := calculateGPULabels(slog.Default(), f, gb200Serials)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("calculateGPULabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewLabelFormatInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  LabelFormatConfig
+	}{
+		{name: "invalid domain", cfg: LabelFormatConfig{Domain: "Not A Domain"}},
+		{name: "template syntax", cfg: LabelFormatConfig{GPUKey: "gpu-{{.Index"}},
+		{name: "unknown field", cfg: LabelFormatConfig{GPUKey: "gpu-{{.Slot}}"}},
+		{name: "invalid key", cfg: LabelFormatConfig{GPUKey: "gpu/{{.Index}}"}},
+		{name: "migrate from active domain", cfg: LabelFormatConfig{MigrateFrom: []string{DefaultLabelDomain}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewLabelFormat(tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestEnsureLabelsMigration(t *testing.T) {
+	ctx := context.Background()
+	serials := []*gpu.Serials{{GPU: []string{"1650924060039"}}}
+	newNode := func() *MockUpdater {
+		return NewMockUpdater(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name: "test-node",
+			Labels: map[string]string{
+				"gpuid.github.com/gpu-0": "1650924060039",
+				"hw.ourco.io/gpu-9":      "stale",
+				"hw.ourco.io.other/keep": "x",
+			},
+		}})
+	}
+
+	// Without a migration the old domain is left alone so consumers can switch over.
+	f, err := NewLabelFormat(LabelFormatConfig{Domain: "hw.ourco.io"})
+	if err != nil {
+		t.Fatalf("NewLabelFormat() unexpected error: %v", err)
+	}
+	m := newNode()
+	if err := EnsureLabels(ctx, slog.Default(), m, "test-node", serials, LabelOptions{Format: f}); err != nil {
+		t.Fatalf("EnsureLabels() unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"gpuid.github.com/gpu-0": "1650924060039",
+		"hw.ourco.io/gpu-0":      "1650924060039",
+		"hw.ourco.io.other/keep": "x",
+	}
+	if !reflect.DeepEqual(m.node.Labels, want) {
+		t.Errorf("labels = %v, want %v", m.node.Labels, want)
+	}
+
+	// Migrating removes the old domain's labels.
+	f, err = NewLabelFormat(LabelFormatConfig{Domain: "hw.ourco.io", MigrateFrom: []string{"gpuid.github.com"}})
+	if err != nil {
+		t.Fatalf("NewLabelFormat() unexpected error: %v", err)
+	}
+	m = newNode()
+	if err := EnsureLabels(ctx, slog.Default(), m, "test-node", serials, LabelOptions{Format: f}); err != nil {
+		t.Fatalf("EnsureLabels() unexpected error: %v", err)
+	}
+	delete(want, "gpuid.github.com/gpu-0")
+	if !reflect.DeepEqual(m.node.Labels, want) {
+		t.Errorf("labels = %v, want %v", m.node.Labels, want)
+	}
+}
@@ -0,0 +1,196 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// AnnotationInventory names the annotation, under the label domain, that holds
+	// the full per-GPU identity as compact JSON, for the attributes (UUID, model,
+	// bus ID) that don't fit in label values.
+	AnnotationInventory = "inventory"
+
+	// InventorySchemaVersion is the version of the AnnotationInventory document.
+	// Bump it on any incompatible change to Inventory.
+	InventorySchemaVersion = 1
+)
+
+// Inventory is the document stored in AnnotationInventory.
+type Inventory struct {
+	Version int            `json:"v"`
+	GPUs    []InventoryGPU `json:"gpus"`
+}
+
+// InventoryGPU is the identity of a single GPU in the inventory annotation.
+type InventoryGPU struct {
+	Serial  string `json:"serial"`
+	UUID    string `json:"uuid,omitempty"`
+	Model   string `json:"model,omitempty"`
+	Chassis string `json:"chassis,omitempty"`
+	BusID   string `json:"busId,omitempty"`
+}
+
+// ParseInventory decodes an AnnotationInventory value. Documents with a newer
+// schema version than this build understands are rejected.
+func ParseInventory(s string) (*Inventory, error) {
+	var inv Inventory
+	if err := json.Unmarshal([]byte(s), &inv); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory annotation: %w", err)
+	}
+	if inv.Version < 1 || inv.Version > InventorySchemaVersion {
+		return nil, fmt.Errorf("unsupported inventory schema version %d", inv.Version)
+	}
+	return &inv, nil
+}
+
+// calculateInventory returns the AnnotationInventory value for serials, or "" when
+// there are no GPUs so the annotation is removed. GPUs are sorted by chassis, then
+// UUID (serial when the UUID is unknown) so equal inventories encode identically.
+func calculateInventory(serials []*gpu.Serials) (string, error) {
+	gpus := make([]InventoryGPU, 0)
+	for _, s := range serials {
+		if s == nil {
+			continue
+		}
+		if len(s.Devices) == 0 {
+			for _, g := range s.GPU {
+				if g != "" {
+					gpus = append(gpus, InventoryGPU{Serial: g, Chassis: s.Chassis})
+				}
+			}
+		}
+		for _, d := range s.Devices {
+			gpus = append(gpus, InventoryGPU{
+				Serial:  d.Serial,
+				UUID:    d.UUID,
+				Model:   d.Model,
+				Chassis: s.Chassis,
+				BusID:   d.BusID,
+			})
+		}
+	}
+	if len(gpus) == 0 {
+		return "", nil
+	}
+
+	sort.Slice(gpus, func(i, j int) bool {
+		if gpus[i].Chassis != gpus[j].Chassis {
+			return gpus[i].Chassis < gpus[j].Chassis
+		}
+		if gpus[i].UUID != gpus[j].UUID {
+			return gpus[i].UUID < gpus[j].UUID
+		}
+		return gpus[i].Serial < gpus[j].Serial
+	})
+
+	b, err := json.Marshal(Inventory{Version: InventorySchemaVersion, GPUs: gpus})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal inventory annotation: %w", err)
+	}
+	return string(b), nil
+}
+
+// RecordedSerials returns the GPUs gpuid last recorded on n, grouped by chassis:
+// from the inventory annotation when it is present, otherwise from the GPU and
+// chassis labels under the active domain of f when it uses the default templates.
+// Returns nil when nothing is recorded.
+func RecordedSerials(f *LabelFormat, n *corev1.Node) []*gpu.Serials {
+	if n == nil {
+		return nil
+	}
+	if f == nil {
+		f = defaultLabelFormat
+	}
+	if v, ok := n.Annotations[f.Key(AnnotationInventory)]; ok {
+		if inv, err := ParseInventory(v); err == nil {
+			return inventorySerials(inv)
+		}
+	}
+	return labelSerials(f, n.Labels)
+}
+
+// inventorySerials groups the GPUs of an inventory document by chassis.
+func inventorySerials(inv *Inventory) []*gpu.Serials {
+	var out []*gpu.Serials
+	byChassis := make(map[string]*gpu.Serials)
+	for _, g := range inv.GPUs {
+		if g.Serial == "" {
+			continue
+		}
+		s, ok := byChassis[g.Chassis]
+		if !ok {
+			s = &gpu.Serials{Chassis: g.Chassis}
+			byChassis[g.Chassis] = s
+			out = append(out, s)
+		}
+		s.GPU = append(s.GPU, g.Serial)
+		s.Devices = append(s.Devices, gpu.Device{Serial: g.Serial, UUID: g.UUID, Model: g.Model, BusID: g.BusID})
+	}
+	return out
+}
+
+// labelSerials reads the serials back from the labels calculateGPULabels writes
+// with the default templates: chassis or chassis-I for the chassis, gpu-N or
+// chassis-I-gpu-N for the GPUs. Custom templates can render any key and value, so
+// their labels are not read back and nil is returned.
+func labelSerials(f *LabelFormat, labels map[string]string) []*gpu.Serials {
+	if !f.readable {
+		return nil
+	}
+
+	chassis := make(map[string]string) // chassis index ("" on a single chassis) to serial
+	gpus := make(map[string][]string)  // chassis index to GPU serials
+	for k, v := range labels {
+		name, ok := strings.CutPrefix(k, f.domain+"/")
+		if !ok || isDerivedLabel(name) {
+			continue
+		}
+		if name == "chassis" {
+			chassis[""] = v
+			continue
+		}
+		idx := ""
+		if rest, ok := strings.CutPrefix(name, "chassis-"); ok {
+			i, g, _ := strings.Cut(rest, "-")
+			if _, err := strconv.Atoi(i); err != nil || g == labelGPUCount {
+				// Not a chassis, or its derived GPU count.
+				continue
+			}
+			if g == "" {
+				chassis[i] = v
+				continue
+			}
+			idx, name = i, g
+		}
+		if n, ok := strings.CutPrefix(name, "gpu-"); ok {
+			if _, err := strconv.Atoi(n); err == nil {
+				gpus[idx] = append(gpus[idx], v)
+			}
+		}
+	}
+
+	var out []*gpu.Serials
+	for idx, serials := range gpus {
+		sort.Strings(serials)
+		out = append(out, &gpu.Serials{Chassis: chassis[idx], GPU: serials})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Chassis < out[j].Chassis })
+	return out
+}
+
+// isDerivedLabel reports whether name is the chassis count or a node-level
+// derived label. Per-chassis GPU counts are named after their chassis label.
+func isDerivedLabel(name string) bool {
+	switch name {
+	case labelChassisCount, labelProduct, labelArchitecture, labelGPUCount, labelDriverVersion, labelMIGMode:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,174 @@
+package node
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+func TestCalculateInventory(t *testing.T) {
+	got, err := calculateInventory([]*gpu.Serials{
+		{
+			Chassis: "1821325191344",
+			GPU:     []string{"1652824032109", "1652824031432"},
+			Devices: []gpu.Device{
+				{Serial: "1652824032109", UUID: "GPU-bbb", Model: "NVIDIA GB200", BusID: "00000009:01:00.0"},
+				{Serial: "1652824031432", UUID: "GPU-aaa", Model: "NVIDIA GB200", BusID: "00000008:01:00.0"},
+			},
+		},
+		nil,
+	})
+	if err != nil {
+		t.Fatalf("calculateInventory() unexpected error: %v", err)
+	}
+
+	want := `{"v":1,"gpus":[` +
+		`{"serial":"1652824031432","uuid":"GPU-aaa","model":"NVIDIA GB200","chassis":"1821325191344","busId":"00000008:01:00.0"},` +
+		`{"serial":"1652824032109","uuid":"GPU-bbb","model":"NVIDIA GB200","chassis":"1821325191344","busId":"00000009:01:00.0"}]}`
+	if got != want {
+		t.Errorf("calculateInventory() =\n%s\nwant\n%s", got, want)
+	}
+
+	inv, err := ParseInventory(got)
+	if err != nil {
+		t.Fatalf("ParseInventory() unexpected error: %v", err)
+	}
+	if inv.Version != InventorySchemaVersion || len(inv.GPUs) != 2 {
+		t.Errorf("ParseInventory() = %+v", inv)
+	}
+
+	// Serials without device details still produce an entry per GPU serial.
+	got, err = calculateInventory([]*gpu.Serials{{GPU: []string{"1650924060039"}}})
+	if err != nil || got != `{"v":1,"gpus":[{"serial":"1650924060039"}]}` {
+		t.Errorf("calculateInventory() without devices = %s, %v", got, err)
+	}
+
+	if got, _ := calculateInventory(nil); got != "" {
+		t.Errorf("calculateInventory(nil) = %q, want empty", got)
+	}
+}
+
+func TestParseInventoryVersion(t *testing.T) {
+	if _, err := ParseInventory(`{"v":99,"gpus":[]}`); err == nil {
+		t.Error("expected error for unsupported schema version")
+	}
+	if _, err := ParseInventory(`not json`); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestEnsureLabelsInventory(t *testing.T) {
+	ctx := context.Background()
+	log := slog.Default()
+	m := NewMockUpdater(&corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name:        "test-node",
+		Annotations: map[string]string{"other": "keep"},
+	}})
+	serials := []*gpu.Serials{{
+		GPU:     []string{"1650924060039"},
+		Devices: []gpu.Device{{Serial: "1650924060039", UUID: "GPU-aaa"}},
+	}}
+
+	if err := EnsureLabels(ctx, log, m, "test-node", serials, LabelOptions{Inventory: true}); err != nil {
+		t.Fatalf("EnsureLabels() unexpected error: %v", err)
+	}
+	if got := m.node.Annotations[defaultLabelFormat.Key(AnnotationInventory)]; got != `{"v":1,"gpus":[{"serial":"1650924060039","uuid":"GPU-aaa"}]}` {
+		t.Errorf("inventory annotation = %q", got)
+	}
+
+	// Unchanged inventory and labels: no patch.
+	before := m.updateCount
+	if err := EnsureLabels(ctx, log, m, "test-node", serials, LabelOptions{Inventory: true}); err != nil {
+		t.Fatalf("EnsureLabels() unexpected error: %v", err)
+	}
+	if m.updateCount != before {
+		t.Errorf("expected no patch for unchanged inventory, got %d", m.updateCount-before)
+	}
+
+	// GPUs gone: the annotation is removed with the labels.
+	if err := EnsureLabels(ctx, log, m, "test-node", nil, LabelOptions{Inventory: true}); err != nil {
+		t.Fatalf("EnsureLabels() unexpected error: %v", err)
+	}
+	if _, ok := m.node.Annotations[defaultLabelFormat.Key(AnnotationInventory)]; ok {
+		t.Error("inventory annotation should be removed when GPUs go away")
+	}
+	if m.node.Annotations["other"] != "keep" {
+		t.Error("unrelated annotation should be preserved")
+	}
+
+	// Disabling the option removes a previously written annotation.
+	if err := EnsureLabels(ctx, log, m, "test-node", serials, LabelOptions{Inventory: true}); err != nil {
+		t.Fatalf("EnsureLabels() unexpected error: %v", err)
+	}
+	if err := EnsureLabels(ctx, log, m, "test-node", serials, LabelOptions{}); err != nil {
+		t.Fatalf("EnsureLabels() unexpected error: %v", err)
+	}
+	if _, ok := m.node.Annotations[defaultLabelFormat.Key(AnnotationInventory)]; ok {
+		t.Error("inventory annotation should be removed when disabled")
+	}
+}
+
+func TestRecordedSerials(t *testing.T) {
+	serials := []*gpu.Serials{
+		{Chassis: "C2", GPU: []string{"S3"}, Devices: []gpu.Device{{Serial: "S3", UUID: "GPU-3"}}},
+		{Chassis: "C1", GPU: []string{"S2", "S1"}, Devices: []gpu.Device{{Serial: "S1", UUID: "GPU-1"}, {Serial: "S2", UUID: "GPU-2"}}},
+	}
+	summary := func(got []*gpu.Serials) map[string][]string {
+		out := make(map[string][]string)
+		for _, s := range got {
+			out[s.Chassis] = append(out[s.Chassis], s.GPU...)
+		}
+		return out
+	}
+	want := map[string][]string{"C1": {"S1", "S2"}, "C2": {"S3"}}
+
+	// From labels, with the derived labels ignored.
+	f := defaultLabelFormat
+	labels := calculateGPULabels(slog.Default(), f, serials)
+	for k, v := range calculateDerivedLabels(f, serials) {
+		labels[k] = v
+	}
+	labels["kubernetes.io/hostname"] = "node-1"
+	n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: labels}}
+	if got := summary(RecordedSerials(f, n)); !reflect.DeepEqual(got, want) {
+		t.Errorf("RecordedSerials() from labels = %v, want %v", got, want)
+	}
+
+	// A single chassis owns every GPU label.
+	n.Labels = calculateGPULabels(slog.Default(), f, serials[1:])
+	if got := summary(RecordedSerials(f, n)); !reflect.DeepEqual(got, map[string][]string{"C1": {"S1", "S2"}}) {
+		t.Errorf("RecordedSerials() single chassis = %v", got)
+	}
+
+	// Labels rendered with a custom template are not read back.
+	custom := mustLabelFormat(LabelFormatConfig{GPUValue: "{{.UUID}}"})
+	n.Labels = calculateGPULabels(slog.Default(), custom, serials)
+	if got := RecordedSerials(custom, n); got != nil {
+		t.Errorf("RecordedSerials() with a custom value template = %v, want nil", summary(got))
+	}
+
+	// The inventory annotation wins over the labels.
+	inv, err := calculateInventory(serials)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Labels = nil
+	n.Annotations = map[string]string{defaultLabelFormat.Key(AnnotationInventory): inv}
+	got := RecordedSerials(f, n)
+	if s := summary(got); !reflect.DeepEqual(s, want) {
+		t.Errorf("RecordedSerials() from inventory = %v, want %v", s, want)
+	}
+	if got[0].Devices[0].UUID != "GPU-1" {
+		t.Errorf("RecordedSerials() dropped device details: %+v", got[0].Devices)
+	}
+
+	if got := RecordedSerials(f, &corev1.Node{}); got != nil {
+		t.Errorf("RecordedSerials() of an unlabeled node = %v, want nil", got)
+	}
+}
@@ -16,16 +16,17 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/wait"
 
+	"github.com/mchmarny/gpuid/pkg/counter"
 	"github.com/mchmarny/gpuid/pkg/gpu"
 	"k8s.io/client-go/kubernetes"
+	corev1listers "k8s.io/client-go/listers/core/v1"
 )
 
 const (
-	// Label namespace and prefixes
-	labelNS            = "gpuid.github.com"
-	labelChassisPrefix = "chassis"
-	labelChassisCount  = "chassis-count"
-	labelGPUPrefix     = "gpu"
+	// Default label namespace (also the annotation namespace) and the fixed
+	// chassis count label name; other label names come from the LabelFormat templates.
+	labelNS           = "gpuid.github.com"
+	labelChassisCount = "chassis-count"
 
 	// Retry configuration in case of large number of GPU nodes being added all at once
 	maxRetries      = 5
@@ -33,6 +34,17 @@ const (
 	maxRetryBackoff = 45 * time.Second
 
 	notSetDefault = "na"
+
+	// Node API operation label values.
+	nodeOpGet         = "get"
+	nodeOpPatch       = "patch"
+	nodeOpPatchStatus = "patch_status"
+)
+
+var (
+	// Metrics for node API usage; saved calls are reads served from the informer cache.
+	counterNodeAPICalls = counter.New("gpuid_node_api_calls_total", "Total number of node API calls made", "op")
+	counterNodeAPISaved = counter.New("gpuid_node_api_calls_saved_total", "Total number of node API calls avoided by the informer cache", "op")
 )
 
 // labelValueRegex matches valid Kubernetes label values
@@ -77,35 +89,90 @@ func sanitizeLabelValue(value string) string {
 	return strings.TrimSpace(sanitized)
 }
 
+// Getter reads a node by name.
+type Getter interface {
+	GetNode(ctx context.Context, name string) (*corev1.Node, error)
+}
+
 // Updater is the contract the labeler depends on. Reads the current node and applies
 // a label patch so multiple controllers writing different labels never conflict.
+// PatchNode applies any strategic merge patch (e.g. annotations) to the node and
+// PatchNodeStatus applies one to its status subresource (e.g. conditions).
 type Updater interface {
-	GetNode(ctx context.Context, name string) (*corev1.Node, error)
+	Getter
 	PatchNodeLabels(ctx context.Context, name string, patch []byte) error
+	PatchNode(ctx context.Context, name string, patch []byte) error
+	PatchNodeStatus(ctx context.Context, name string, patch []byte) error
 }
 
 // Labeler implements Updater
 type Labeler struct {
 	client kubernetes.Interface
+	lister corev1listers.NodeLister
 }
 
-// NewLabelUpdater creates a new Labeler instance
+// NewLabelUpdater creates a new Labeler instance that reads nodes from the API server.
 func NewLabelUpdater(client kubernetes.Interface) Updater {
 	return &Labeler{client: client}
 }
 
+// NewCachedLabelUpdater creates a new Labeler instance that reads nodes from a shared
+// informer cache and only falls back to the API server when the node isn't cached yet.
+// Patches always go to the API server.
+func NewCachedLabelUpdater(client kubernetes.Interface, lister corev1listers.NodeLister) Updater {
+	return &Labeler{client: client, lister: lister}
+}
+
+// GetNode returns a copy of the node, from the informer cache when one is configured.
 func (l *Labeler) GetNode(ctx context.Context, name string) (*corev1.Node, error) {
+	if l.lister != nil {
+		n, err := l.lister.Get(name)
+		if err == nil {
+			counterNodeAPISaved.Increment(nodeOpGet)
+			// Cached objects are shared; hand out a copy so callers may mutate it.
+			return n.DeepCopy(), nil
+		}
+		if !errors.IsNotFound(err) {
+			return nil, err
+		}
+		// Not cached yet (e.g. node just joined); fall through to a live read.
+	}
+
+	counterNodeAPICalls.Increment(nodeOpGet)
 	return l.client.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
 }
 
 func (l *Labeler) PatchNodeLabels(ctx context.Context, name string, patch []byte) error {
+	return l.PatchNode(ctx, name, patch)
+}
+
+// PatchNode applies a strategic merge patch to the node object.
+func (l *Labeler) PatchNode(ctx context.Context, name string, patch []byte) error {
+	counterNodeAPICalls.Increment(nodeOpPatch)
 	_, err := l.client.CoreV1().Nodes().Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{})
 	return err
 }
 
+// PatchNodeStatus applies a strategic merge patch to the node status subresource.
+func (l *Labeler) PatchNodeStatus(ctx context.Context, name string, patch []byte) error {
+	counterNodeAPICalls.Increment(nodeOpPatchStatus)
+	_, err := l.client.CoreV1().Nodes().Patch(ctx, name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}, "status")
+	return err
+}
+
+// LabelOptions selects the optional node metadata written alongside the GPU labels.
+type LabelOptions struct {
+	Format    *LabelFormat // Label domain and key/value templates; nil uses the defaults
+	Inventory bool         // Maintain the AnnotationInventory JSON annotation
+	Derived   bool         // Add product, architecture, GPU count, driver and MIG mode labels
+}
+
 // EnsureLabels is the testable version that accepts an interface
-func EnsureLabels(ctx context.Context, log *slog.Logger, labeler Updater, nodeName string, serials []*gpu.Serials) error {
-	desiredLabels := calculateGPULabels(log, serials)
+func EnsureLabels(ctx context.Context, log *slog.Logger, labeler Updater, nodeName string, serials []*gpu.Serials, opts LabelOptions) error {
+	f, desiredLabels, desiredInventory, err := desiredState(log, serials, opts)
+	if err != nil {
+		return err
+	}
 
 	return wait.ExponentialBackoff(wait.Backoff{
 		Duration: retryBackoff,
@@ -114,7 +181,7 @@ func EnsureLabels(ctx context.Context, log *slog.Logger, labeler Updater, nodeNa
 		Steps:    maxRetries,
 		Cap:      maxRetryBackoff,
 	}, func() (bool, error) {
-		success, err := attemptLabelUpdate(ctx, log, labeler, nodeName, desiredLabels)
+		success, err := attemptLabelUpdate(ctx, log, labeler, f, nodeName, desiredLabels, desiredInventory)
 		if err != nil {
 			// Don't retry permission errors - they won't resolve with retries
 			if errors.IsForbidden(err) {
@@ -135,10 +202,91 @@ func EnsureLabels(ctx context.Context, log *slog.Logger, labeler Updater, nodeNa
 	})
 }
 
-// attemptLabelUpdate performs a single attempt to update node labels via a
-// strategic merge patch. Patch is conflict-free with other label writers and
-// avoids the Get/mutate/Update race entirely.
-func attemptLabelUpdate(ctx context.Context, log *slog.Logger, labeler Updater, nodeName string, desiredLabels map[string]string) (bool, error) {
+// LabelsDrifted reports whether the gpuid labels or inventory annotation of n
+// differ from the ones EnsureLabels writes for serials, e.g. after someone edited
+// or removed them.
+func LabelsDrifted(log *slog.Logger, n *corev1.Node, serials []*gpu.Serials, opts LabelOptions) (bool, error) {
+	f, desiredLabels, desiredInventory, err := desiredState(log, serials, opts)
+	if err != nil {
+		return false, err
+	}
+	if _, changed := inventoryChange(f, n.GetAnnotations(), desiredInventory); changed {
+		return true, nil
+	}
+	return needsLabelUpdate(n.GetLabels(), desiredLabels, f.owns), nil
+}
+
+// GPULabels returns the labels EnsureLabels writes for serials, for outputs that
+// hand them to another controller instead of patching the node.
+func GPULabels(log *slog.Logger, serials []*gpu.Serials, opts LabelOptions) map[string]string {
+	_, desiredLabels, _, err := desiredState(log, serials, LabelOptions{Format: opts.Format, Derived: opts.Derived})
+	if err != nil {
+		// Only the inventory annotation can fail, and it is not requested.
+		return nil
+	}
+	return desiredLabels
+}
+
+// HasGPULabels reports whether n carries any label gpuid manages.
+func HasGPULabels(n *corev1.Node, opts LabelOptions) bool {
+	f := opts.Format
+	if f == nil {
+		f = defaultLabelFormat
+	}
+	for k := range n.GetLabels() {
+		if f.owns(k) {
+			return true
+		}
+	}
+	return false
+}
+
+// desiredState returns the label format, and the labels and inventory annotation
+// value EnsureLabels writes for serials.
+func desiredState(log *slog.Logger, serials []*gpu.Serials, opts LabelOptions) (*LabelFormat, map[string]string, string, error) {
+	f := opts.Format
+	if f == nil {
+		f = defaultLabelFormat
+	}
+	desiredLabels := calculateGPULabels(log, f, serials)
+	if opts.Derived {
+		// Identity labels win on the unlikely event a key template collides.
+		for k, v := range calculateDerivedLabels(f, serials) {
+			if _, ok := desiredLabels[k]; !ok {
+				desiredLabels[k] = v
+			}
+		}
+	}
+
+	// An empty inventory removes the annotation, as does turning the option off.
+	var desiredInventory string
+	if opts.Inventory {
+		inv, err := calculateInventory(serials)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		desiredInventory = inv
+	}
+	return f, desiredLabels, desiredInventory, nil
+}
+
+// inventoryChange returns the patch value for the inventory annotation and
+// whether it changes: desired when it differs, nil when it is no longer desired.
+func inventoryChange(f *LabelFormat, annotations map[string]string, desired string) (any, bool) {
+	current, ok := annotations[f.Key(AnnotationInventory)]
+	switch {
+	case desired != "" && current != desired:
+		return desired, true
+	case desired == "" && ok:
+		return nil, true
+	}
+	return nil, false
+}
+
+// attemptLabelUpdate performs a single attempt to update node labels, and the
+// inventory annotation, via a strategic merge patch. Patch is conflict-free with
+// other label writers and avoids the Get/mutate/Update race entirely.
+func attemptLabelUpdate(ctx context.Context, log *slog.Logger, labeler Updater, f *LabelFormat, nodeName string, desiredLabels map[string]string, desiredInventory string) (bool, error) {
 	node, err := labeler.GetNode(ctx, nodeName)
 	if err != nil {
 		return false, fmt.Errorf("failed to get node %s: %w", nodeName, err)
@@ -153,19 +301,26 @@ func attemptLabelUpdate(ctx context.Context, log *slog.Logger, labeler Updater,
 		currentLabels = make(map[string]string)
 	}
 
-	if !needsLabelUpdate(currentLabels, desiredLabels) {
+	// Inventory annotation: set when it differs, null when it is no longer desired.
+	patchAnnotations := make(map[string]any)
+	if v, changed := inventoryChange(f, node.GetAnnotations(), desiredInventory); changed {
+		patchAnnotations[f.Key(AnnotationInventory)] = v
+	}
+
+	if !needsLabelUpdate(currentLabels, desiredLabels, f.owns) && len(patchAnnotations) == 0 {
 		log.Debug("node labels already up to date", "node", nodeName)
 		return true, nil
 	}
 
 	// Build patch: set every desired label, and explicitly null any stale
-	// gpuid-prefixed label that is no longer desired (strategic merge null = delete).
+	// gpuid label (active or migrated-from domain) that is no longer desired
+	// (strategic merge null = delete).
 	patchLabels := make(map[string]any, len(desiredLabels))
 	for k, v := range desiredLabels {
 		patchLabels[k] = v
 	}
 	for k := range currentLabels {
-		if !strings.HasPrefix(k, labelNS) {
+		if !f.owns(k) {
 			continue
 		}
 		if _, keep := desiredLabels[k]; !keep {
@@ -173,12 +328,14 @@ func attemptLabelUpdate(ctx context.Context, log *slog.Logger, labeler Updater,
 		}
 	}
 
-	patch := map[string]any{
-		"metadata": map[string]any{
-			"labels": patchLabels,
-		},
+	metadata := map[string]any{
+		"labels": patchLabels,
+	}
+	if len(patchAnnotations) > 0 {
+		metadata["annotations"] = patchAnnotations
 	}
-	patchBytes, err := json.Marshal(patch)
+
+	patchBytes, err := json.Marshal(map[string]any{"metadata": metadata})
 	if err != nil {
 		return false, fmt.Errorf("failed to marshal label patch: %w", err)
 	}
@@ -191,8 +348,10 @@ func attemptLabelUpdate(ctx context.Context, log *slog.Logger, labeler Updater,
 	return true, nil
 }
 
-// calculateGPULabels pre-calculates all GPU labels to minimize work in retry loop
-func calculateGPULabels(log *slog.Logger, serials []*gpu.Serials) map[string]string {
+// calculateGPULabels pre-calculates all GPU labels to minimize work in retry loop.
+// Keys and values come from the templates in f; a GPU whose key does not render
+// to a valid label is skipped, as is a key already taken by a different value.
+func calculateGPULabels(log *slog.Logger, f *LabelFormat, serials []*gpu.Serials) map[string]string {
 	labels := make(map[string]string)
 	if len(serials) == 0 {
 		return labels
@@ -228,57 +387,105 @@ func calculateGPULabels(log *slog.Logger, serials []*gpu.Serials) map[string]str
 			continue
 		}
 
+		data := LabelData{ChassisIndex: i, MultiChassis: multipleChassis}
+
 		// parse and sanitize chassis serial number
 		chassisSerial := sanitizeLabelValue(s.Chassis)
 
 		// set chassis label if serial number is present
 		if chassisSerial != notSetDefault {
-			chassisLabel := fmt.Sprintf("%s/%s", labelNS, labelChassisPrefix)
-			if multipleChassis {
-				chassisLabel = fmt.Sprintf("%s/%s-%d", labelNS, labelChassisPrefix, i)
+			data.Chassis = chassisSerial
+			if key, err := f.key(f.chassisKey, data); err != nil {
+				log.Warn("skipping chassis label", "chassis", chassisSerial, "err", err)
+			} else {
+				labels[key] = chassisSerial
 			}
-			labels[chassisLabel] = chassisSerial
 
 			// increment chassis count only for non-nil entries
 			chassisCount++
 		}
 
-		// sort GPU serials for predictable order
+		// sort GPU serials for predictable order; dies sharing a serial share its index
 		gpuSerials := make([]string, len(s.GPU))
 		copy(gpuSerials, s.GPU)
 		sort.Strings(gpuSerials)
+		serialIndex := make(map[string]int, len(gpuSerials))
+		for j, g := range gpuSerials {
+			if _, ok := serialIndex[g]; !ok {
+				serialIndex[g] = j
+			}
+		}
 
 		// GPU labels
-		for j, g := range gpuSerials {
-			if g == "" {
+		for j, d := range chassisDevices(s) {
+			if d.Serial == "" {
 				continue
 			}
 
-			// GPU label - no chassis prefix by default (h100)
-			gpuLabel := fmt.Sprintf("%s/%s-%d", labelNS, labelGPUPrefix, j)
-			if multipleChassis && chassisSerial != notSetDefault {
-				// if chassis has a serial number, then include its index in the GPU label
-				gpuLabel = fmt.Sprintf("%s/%s-%d-%s-%d", labelNS, labelChassisPrefix, i, labelGPUPrefix, j)
+			gd := data
+			gd.Index = serialIndex[d.Serial]
+			gd.DeviceIndex = j
+			gd.Serial = d.Serial
+			gd.UUID = d.UUID
+			gd.Model = d.Model
+			gd.ModuleID = d.ModuleID
+
+			key, err := f.key(f.gpuKey, gd)
+			if err != nil {
+				log.Warn("skipping GPU label", "serial", d.Serial, "err", err)
+				continue
+			}
+			value, err := f.value(f.gpuValue, gd)
+			if err != nil {
+				log.Warn("skipping GPU label", "serial", d.Serial, "err", err)
+				continue
+			}
+			if prev, ok := labels[key]; ok && prev != value {
+				log.Warn("GPU label key already used by another GPU, check the key template", "key", key, "kept", prev, "skipped", value)
+				continue
 			}
 
-			labels[gpuLabel] = sanitizeLabelValue(g)
+			labels[key] = value
 		}
 	}
 
 	// Chassis count, only if there is at least one chassis with a serial number
 	if chassisCount > 0 {
-		chassisCountLabel := fmt.Sprintf("%s/%s", labelNS, labelChassisCount)
-		labels[chassisCountLabel] = fmt.Sprintf("%d", chassisCount)
+		labels[f.Key(labelChassisCount)] = fmt.Sprintf("%d", chassisCount)
 	}
 
 	return labels
 }
 
+// chassisDevices returns the devices of s sorted by UUID, or one device per GPU
+// serial when s carries no device details.
+func chassisDevices(s *gpu.Serials) []gpu.Device {
+	if len(s.Devices) == 0 {
+		devices := make([]gpu.Device, 0, len(s.GPU))
+		for _, g := range s.GPU {
+			devices = append(devices, gpu.Device{Serial: g})
+		}
+		sort.Slice(devices, func(i, j int) bool { return devices[i].Serial < devices[j].Serial })
+		return devices
+	}
+
+	devices := make([]gpu.Device, len(s.Devices))
+	copy(devices, s.Devices)
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].UUID != devices[j].UUID {
+			return devices[i].UUID < devices[j].UUID
+		}
+		return devices[i].Serial < devices[j].Serial
+	})
+	return devices
+}
+
 // needsLabelUpdate checks if the current labels differ from desired labels.
-func needsLabelUpdate(current, desired map[string]string) bool {
+// owns selects the current labels gpuid manages.
+func needsLabelUpdate(current, desired map[string]string, owns func(string) bool) bool {
 	// Any gpuid label that is no longer desired must be removed.
 	for k := range current {
-		if strings.HasPrefix(k, labelNS) {
+		if owns(k) {
 			if _, exists := desired[k]; !exists {
 				return true
 			}
@@ -37,7 +37,11 @@ func (m *MockUpdater) GetNode(_ context.Context, _ string) (*corev1.Node, error)
 	return m.node.DeepCopy(), nil
 }
 
-func (m *MockUpdater) PatchNodeLabels(_ context.Context, _ string, patch []byte) error {
+func (m *MockUpdater) PatchNodeLabels(ctx context.Context, name string, patch []byte) error {
+	return m.PatchNode(ctx, name, patch)
+}
+
+func (m *MockUpdater) PatchNode(_ context.Context, _ string, patch []byte) error {
 	m.updateCount++
 
 	if m.shouldFail && m.updateCount <= m.failCount {
@@ -46,8 +50,13 @@ func (m *MockUpdater) PatchNodeLabels(_ context.Context, _ string, patch []byte)
 
 	var payload struct {
 		Metadata struct {
-			Labels map[string]*string `json:"labels"`
+			Labels      map[string]*string `json:"labels"`
+			Annotations map[string]*string `json:"annotations"`
 		} `json:"metadata"`
+		Spec struct {
+			Taints        *[]corev1.Taint `json:"taints"`
+			Unschedulable *bool           `json:"unschedulable"`
+		} `json:"spec"`
 	}
 	if err := json.Unmarshal(patch, &payload); err != nil {
 		return err
@@ -55,16 +64,53 @@ func (m *MockUpdater) PatchNodeLabels(_ context.Context, _ string, patch []byte)
 	if m.node.Labels == nil {
 		m.node.Labels = map[string]string{}
 	}
-	for k, v := range payload.Metadata.Labels {
-		if v == nil {
-			delete(m.node.Labels, k)
+	if m.node.Annotations == nil {
+		m.node.Annotations = map[string]string{}
+	}
+	applyMergePatch(m.node.Labels, payload.Metadata.Labels)
+	applyMergePatch(m.node.Annotations, payload.Metadata.Annotations)
+	if payload.Spec.Taints != nil {
+		// Taints have no merge key: the list is replaced.
+		m.node.Spec.Taints = *payload.Spec.Taints
+	}
+	if payload.Spec.Unschedulable != nil {
+		m.node.Spec.Unschedulable = *payload.Spec.Unschedulable
+	}
+	return nil
+}
+
+func (m *MockUpdater) PatchNodeStatus(_ context.Context, _ string, patch []byte) error {
+	m.updateCount++
+
+	var payload struct {
+		Status struct {
+			Conditions []corev1.NodeCondition `json:"conditions"`
+		} `json:"status"`
+	}
+	if err := json.Unmarshal(patch, &payload); err != nil {
+		return err
+	}
+	for _, c := range payload.Status.Conditions {
+		if cur := findCondition(m.node.Status.Conditions, c.Type); cur != nil {
+			*cur = c
 			continue
 		}
-		m.node.Labels[k] = *v
+		m.node.Status.Conditions = append(m.node.Status.Conditions, c)
 	}
 	return nil
 }
 
+// applyMergePatch sets non-nil values and deletes keys patched to null.
+func applyMergePatch(dst map[string]string, patch map[string]*string) {
+	for k, v := range patch {
+		if v == nil {
+			delete(dst, k)
+			continue
+		}
+		dst[k] = *v
+	}
+}
+
 func (m *MockUpdater) SetFailure(shouldFail bool, failCount int) {
 	m.shouldFail = shouldFail
 	m.failCount = failCount
@@ -208,7 +254,7 @@ func TestEnsureLabels(t *testing.T) {
 			}
 
 			// Test the function
-			err := EnsureLabels(ctx, logger, mockUpdater, "test-node", tt.serials)
+			err := EnsureLabels(ctx, logger, mockUpdater, "test-node", tt.serials, LabelOptions{})
 
 			if tt.shouldFail && tt.failCount >= maxRetries {
 				if err == nil {
@@ -304,7 +350,7 @@ func TestCalculateGPULabels(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			labels := calculateGPULabels(logger, tt.serials)
+			labels := calculateGPULabels(logger, defaultLabelFormat, tt.serials)
 
 			if len(labels) != len(tt.expectedLabels) {
 				t.Errorf("Expected %d labels, got %d", len(tt.expectedLabels), len(labels))
@@ -367,7 +413,7 @@ func TestNeedsLabelUpdate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := needsLabelUpdate(tt.current, tt.desired)
+			result := needsLabelUpdate(tt.current, tt.desired, defaultLabelFormat.owns)
 			if result != tt.expected {
 				t.Errorf("Expected %v, got %v", tt.expected, result)
 			}
@@ -376,6 +422,50 @@ func TestNeedsLabelUpdate(t *testing.T) {
 }
 
 // TestLabelerImplementsInterface ensures Labeler implements Updater interface at compile time
+func TestLabelsDrifted(t *testing.T) {
+	ctx := context.Background()
+	serials := []*gpu.Serials{{Chassis: "chassis1", GPU: []string{"gpu0", "gpu1"}}}
+	opts := LabelOptions{Inventory: true}
+
+	n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"existing": "label"}}}
+	if HasGPULabels(n, opts) {
+		t.Error("HasGPULabels() = true before labeling")
+	}
+	if drifted, err := LabelsDrifted(slog.Default(), n, serials, opts); err != nil || !drifted {
+		t.Errorf("LabelsDrifted() before labeling = %v, %v; want true", drifted, err)
+	}
+
+	m := NewMockUpdater(n)
+	if err := EnsureLabels(ctx, slog.Default(), m, "node1", serials, opts); err != nil {
+		t.Fatalf("EnsureLabels() error = %v", err)
+	}
+	if !HasGPULabels(m.node, opts) {
+		t.Error("HasGPULabels() = false after labeling")
+	}
+	if drifted, err := LabelsDrifted(slog.Default(), m.node, serials, opts); err != nil || drifted {
+		t.Errorf("LabelsDrifted() after labeling = %v, %v; want false", drifted, err)
+	}
+
+	edited := m.node.DeepCopy()
+	edited.Labels["gpuid.github.com/gpu-1"] = "other"
+	if drifted, _ := LabelsDrifted(slog.Default(), edited, serials, opts); !drifted {
+		t.Error("LabelsDrifted() = false for an edited label")
+	}
+
+	stripped := m.node.DeepCopy()
+	delete(stripped.Annotations, defaultLabelFormat.Key(AnnotationInventory))
+	if drifted, _ := LabelsDrifted(slog.Default(), stripped, serials, opts); !drifted {
+		t.Error("LabelsDrifted() = false for a removed inventory annotation")
+	}
+
+	// Labels of other writers don't count.
+	other := m.node.DeepCopy()
+	other.Labels["example.com/team"] = "a"
+	if drifted, _ := LabelsDrifted(slog.Default(), other, serials, opts); drifted {
+		t.Error("LabelsDrifted() = true for a label gpuid doesn't own")
+	}
+}
+
 func TestLabelerImplementsInterface(t *testing.T) {
 	var _ Updater = (*Labeler)(nil)
 	t.Log("Labeler correctly implements Updater interface")
@@ -400,7 +490,7 @@ func BenchmarkCalculateGPULabels(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		calculateGPULabels(logger, serials)
+		calculateGPULabels(logger, defaultLabelFormat, serials)
 	}
 }
 
@@ -505,3 +595,23 @@ func TestSanitizeLabelValuePatterns(t *testing.T) {
 		})
 	}
 }
+
+func TestGPULabels(t *testing.T) {
+	serials := []*gpu.Serials{{Chassis: "chassis1", GPU: []string{"gpu0", "gpu1"}}}
+	opts := LabelOptions{Inventory: true}
+
+	m := NewMockUpdater(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node1", Labels: map[string]string{"existing": "label"}}})
+	if err := EnsureLabels(context.Background(), slog.Default(), m, "node1", serials, opts); err != nil {
+		t.Fatalf("EnsureLabels() error = %v", err)
+	}
+
+	got := GPULabels(slog.Default(), serials, opts)
+	if len(got) == 0 || len(got) != len(m.node.Labels)-1 {
+		t.Fatalf("GPULabels() = %v, want the gpuid labels of %v", got, m.node.Labels)
+	}
+	for k, v := range got {
+		if m.node.Labels[k] != v {
+			t.Errorf("GPULabels()[%q] = %q, node label = %q", k, v, m.node.Labels[k])
+		}
+	}
+}
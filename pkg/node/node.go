@@ -21,8 +21,17 @@ type Info struct {
 // GetNodeProviderID retrieves and parses the provider ID of a given Kubernetes node.
 // It returns a Info struct containing the cloud provider, instance identifier, and raw provider ID.
 // Supports AWS, GCP, Azure and BareMetal. Returns an error if node cannot be fetched or if provider is unrecognized.
-func GetNodeProviderID(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, node string) (*Info, error) {
-	n, err := Get(ctx, log, cs, node)
+// The node is read through getter, so a cached Labeler avoids a live API call per pod.
+func GetNodeProviderID(ctx context.Context, log *slog.Logger, getter Getter, node string) (*Info, error) {
+	if strings.TrimSpace(node) == "" {
+		return nil, fmt.Errorf("node name is required")
+	}
+
+	if getter == nil {
+		return nil, fmt.Errorf("node getter is nil")
+	}
+
+	n, err := getter.GetNode(ctx, node)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get node: %w", err)
 	}
@@ -42,6 +51,12 @@ func GetNodeProviderID(ctx context.Context, log *slog.Logger, cs *kubernetes.Cli
 	return parseNodeInfo(log, providerID)
 }
 
+// ParseProviderID parses a node provider ID, e.g. to identify a node that has
+// already been deleted.
+func ParseProviderID(log *slog.Logger, providerID string) (*Info, error) {
+	return parseNodeInfo(log, providerID)
+}
+
 func parseNodeInfo(log *slog.Logger, providerID string) (*Info, error) {
 	if providerID == "" {
 		return nil, fmt.Errorf("node providerID is empty")
@@ -1,10 +1,17 @@
 package node
 
 import (
+	"context"
 	"reflect"
 	"testing"
 
 	"github.com/mchmarny/gpuid/pkg/logger"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
 )
 
 func TestParseNodeInfo(t *testing.T) {
@@ -81,3 +88,76 @@ func TestParseNodeInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestGetNodeProviderID(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewTestLogger(t)
+
+	n := &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "gpu-node"},
+		Spec:       corev1.NodeSpec{ProviderID: "aws:///us-west-2a/i-0123456789abcdef0"},
+	}
+
+	got, err := GetNodeProviderID(ctx, log, NewMockUpdater(n), "gpu-node")
+	if err != nil {
+		t.Fatalf("GetNodeProviderID() unexpected error: %v", err)
+	}
+	if got.Identifier != "i-0123456789abcdef0" {
+		t.Errorf("GetNodeProviderID() identifier = %q, want i-0123456789abcdef0", got.Identifier)
+	}
+
+	if _, err := GetNodeProviderID(ctx, log, NewMockUpdater(nil), "gpu-node"); err == nil {
+		t.Error("GetNodeProviderID() expected error for missing node")
+	}
+	if _, err := GetNodeProviderID(ctx, log, nil, "gpu-node"); err == nil {
+		t.Error("GetNodeProviderID() expected error for nil getter")
+	}
+	if _, err := GetNodeProviderID(ctx, log, NewMockUpdater(n), " "); err == nil {
+		t.Error("GetNodeProviderID() expected error for empty node name")
+	}
+}
+
+func TestCachedLabeler_GetNode(t *testing.T) {
+	ctx := context.Background()
+
+	cached := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cached", Labels: map[string]string{"src": "cache"}}}
+	live := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "live", Labels: map[string]string{"src": "api"}}}
+	cs := fake.NewClientset(live)
+
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})
+	if err := indexer.Add(cached); err != nil {
+		t.Fatalf("failed to seed indexer: %v", err)
+	}
+	l := NewCachedLabelUpdater(cs, corev1listers.NewNodeLister(indexer))
+
+	got, err := l.GetNode(ctx, "cached")
+	if err != nil {
+		t.Fatalf("GetNode(cached) unexpected error: %v", err)
+	}
+	if got.Labels["src"] != "cache" {
+		t.Errorf("GetNode(cached) should come from cache, got labels %v", got.Labels)
+	}
+
+	// Mutating the returned copy must not affect the cache.
+	got.Labels["src"] = "mutated"
+	again, _ := l.GetNode(ctx, "cached")
+	if again.Labels["src"] != "cache" {
+		t.Error("GetNode() returned a shared cache object")
+	}
+
+	got, err = l.GetNode(ctx, "live")
+	if err != nil {
+		t.Fatalf("GetNode(live) unexpected error: %v", err)
+	}
+	if got.Labels["src"] != "api" {
+		t.Errorf("GetNode(live) should fall back to the API server, got labels %v", got.Labels)
+	}
+
+	for _, a := range cs.Actions() {
+		if a.GetVerb() == "get" {
+			if ga, ok := a.(k8stesting.GetAction); ok && ga.GetName() == "cached" {
+				t.Error("cached node should not be fetched from the API server")
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Names of the annotations, under the label domain, set on GPU workload pods so
+// they can read, e.g. through a downwardAPI volume, which physical GPUs they run on.
+const (
+	AnnotationPodGPUSerials = "gpu-serials"
+	AnnotationPodChassis    = "chassis"
+	// AnnotationPodExact is "true" when the serials are the GPUs allocated to the
+	// pod, and "false" when they are every GPU on its node.
+	AnnotationPodExact = "gpu-serials-exact"
+)
+
+// PodAnnotationKeys lists the keys of the workload pod annotations managed by
+// gpuid under the domain of f.
+func PodAnnotationKeys(f *LabelFormat) []string {
+	return []string{f.Key(AnnotationPodGPUSerials), f.Key(AnnotationPodChassis), f.Key(AnnotationPodExact)}
+}
+
+// PodAnnotationPatch returns the strategic merge patch that sets the workload
+// annotations for gpus and chassis on a pod whose current annotations are
+// current, or nil when they are already in place. Exact serials are never
+// replaced by node-level ones, so the agent and the controller can both run.
+// The keys are under the domain of f.
+func PodAnnotationPatch(f *LabelFormat, current map[string]string, gpus, chassis []string, exact bool) ([]byte, error) {
+	if len(gpus) == 0 {
+		return nil, fmt.Errorf("at least one GPU serial is required")
+	}
+	if !exact && current[f.Key(AnnotationPodExact)] == "true" {
+		return nil, nil
+	}
+
+	desired := map[string]string{
+		f.Key(AnnotationPodGPUSerials): strings.Join(gpus, ","),
+		f.Key(AnnotationPodChassis):    strings.Join(chassis, ","),
+		f.Key(AnnotationPodExact):      strconv.FormatBool(exact),
+	}
+
+	annotations := make(map[string]any, len(desired))
+	for k, v := range desired {
+		cur, ok := current[k]
+		switch {
+		case v == "" && ok:
+			annotations[k] = nil
+		case v != "" && cur != v:
+			annotations[k] = v
+		}
+	}
+	if len(annotations) == 0 {
+		return nil, nil
+	}
+
+	patch, err := json.Marshal(map[string]any{
+		"metadata": map[string]any{"annotations": annotations},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal pod annotation patch: %w", err)
+	}
+	return patch, nil
+}
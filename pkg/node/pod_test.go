@@ -0,0 +1,90 @@
+package node
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestPodAnnotationPatch(t *testing.T) {
+	f := mustLabelFormat(LabelFormatConfig{Domain: "hw.example.com"})
+	tests := []struct {
+		name    string
+		current map[string]string
+		gpus    []string
+		chassis []string
+		exact   bool
+		want    map[string]any // annotations in the patch; nil means no patch
+		wantErr bool
+	}{
+		{
+			name:    "new pod",
+			gpus:    []string{"S1", "S2"},
+			chassis: []string{"C1"},
+			exact:   true,
+			want: map[string]any{
+				f.Key(AnnotationPodGPUSerials): "S1,S2",
+				f.Key(AnnotationPodChassis):    "C1",
+				f.Key(AnnotationPodExact):      "true",
+			},
+		},
+		{
+			name: "unchanged",
+			current: map[string]string{
+				f.Key(AnnotationPodGPUSerials): "S1",
+				f.Key(AnnotationPodExact):      "false",
+			},
+			gpus: []string{"S1"},
+		},
+		{
+			name: "exact not replaced by node level",
+			current: map[string]string{
+				f.Key(AnnotationPodGPUSerials): "S1",
+				f.Key(AnnotationPodExact):      "true",
+			},
+			gpus: []string{"S1", "S2"},
+		},
+		{
+			name: "chassis removed",
+			current: map[string]string{
+				f.Key(AnnotationPodGPUSerials): "S1",
+				f.Key(AnnotationPodChassis):    "C1",
+				f.Key(AnnotationPodExact):      "true",
+			},
+			gpus:  []string{"S1"},
+			exact: true,
+			want:  map[string]any{f.Key(AnnotationPodChassis): nil},
+		},
+		{
+			name:    "no GPUs",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			patch, err := PodAnnotationPatch(f, tt.current, tt.gpus, tt.chassis, tt.exact)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("PodAnnotationPatch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				if patch != nil {
+					t.Errorf("PodAnnotationPatch() = %s, want no patch", patch)
+				}
+				return
+			}
+
+			var got struct {
+				Metadata struct {
+					Annotations map[string]any `json:"annotations"`
+				} `json:"metadata"`
+			}
+			if err := json.Unmarshal(patch, &got); err != nil {
+				t.Fatalf("invalid patch %s: %v", patch, err)
+			}
+			if !reflect.DeepEqual(got.Metadata.Annotations, tt.want) {
+				t.Errorf("PodAnnotationPatch() annotations = %v, want %v", got.Metadata.Annotations, tt.want)
+			}
+		})
+	}
+}
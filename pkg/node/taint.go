@@ -0,0 +1,273 @@
+package node
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+const (
+	// AnnotationHealthIssues names the annotation, under the label domain, that
+	// lists the GPU health issues behind the health taint.
+	AnnotationHealthIssues = "health-issues"
+
+	// IssueMissingGPU is reported when the driver sees more GPUs than nvidia-smi read.
+	IssueMissingGPU = "missing_gpu"
+
+	// Health taint action label values.
+	taintApplied    = "applied"
+	taintRemoved    = "removed"
+	taintSuppressed = "suppressed"
+)
+
+var (
+	counterTaint = counter.New("gpuid_health_taint_total", "Total number of health taint changes, and taints suppressed by the max percentage limit", "action")
+
+	// taintBackoff re-reads the node after a conflicting taint patch; the node
+	// cache needs a moment to catch up with the write that caused the conflict.
+	taintBackoff = wait.Backoff{Duration: 200 * time.Millisecond, Factor: 2, Jitter: 0.1, Steps: 5}
+)
+
+// ParseTaint parses a taint in kubectl format: key[=value]:Effect.
+func ParseTaint(spec string) (corev1.Taint, error) {
+	var t corev1.Taint
+
+	i := strings.LastIndex(spec, ":")
+	if i < 0 {
+		return t, fmt.Errorf("invalid taint %q: expected key[=value]:Effect", spec)
+	}
+	kv, effect := spec[:i], corev1.TaintEffect(spec[i+1:])
+	switch effect {
+	case corev1.TaintEffectNoSchedule, corev1.TaintEffectPreferNoSchedule, corev1.TaintEffectNoExecute:
+	default:
+		return t, fmt.Errorf("invalid taint effect %q: expected NoSchedule, PreferNoSchedule or NoExecute", effect)
+	}
+
+	t.Key, t.Value, _ = strings.Cut(kv, "=")
+	t.Effect = effect
+	if t.Key == "" {
+		return t, fmt.Errorf("invalid taint %q: key is required", spec)
+	}
+	return t, nil
+}
+
+// HealthIssues returns the health issues in serials as sorted "<gpu>:<issue>"
+// entries, where <gpu> is the UUID (serial when unknown), plus a
+// "missing_gpu:<missing>/<attached>" entry when the driver reports GPUs that
+// nvidia-smi did not read.
+func HealthIssues(serials []*gpu.Serials) []string {
+	var issues []string
+	read, attached := 0, 0
+	for _, s := range serials {
+		if s == nil {
+			continue
+		}
+		attached = max(attached, s.Attached)
+		if len(s.Devices) == 0 {
+			read += len(s.GPU)
+		}
+		for _, d := range s.Devices {
+			read++
+			id := d.UUID
+			if id == "" {
+				id = d.Serial
+			}
+			for _, i := range d.Issues {
+				issues = append(issues, id+":"+i)
+			}
+		}
+	}
+	if attached > read {
+		issues = append(issues, fmt.Sprintf("%s:%d/%d", IssueMissingGPU, attached-read, attached))
+	}
+	sort.Strings(issues)
+	return issues
+}
+
+// Tainter applies a taint to nodes with unhealthy GPUs and removes it once they
+// recover. It never lets the taint cover more than maxPercent of the scanned
+// nodes, so a bad driver rollout or a parsing bug can't drain the fleet.
+type Tainter struct {
+	updater    Updater
+	format     *LabelFormat
+	list       func() ([]*corev1.Node, error)
+	taint      corev1.Taint
+	maxPercent int
+
+	// mu serializes decisions so concurrent workers can't exceed the limit
+	// together; written holds taint state written by this process that the
+	// node cache may not reflect yet.
+	mu      sync.Mutex
+	written map[string]bool
+}
+
+// NewTainter creates a Tainter. list returns the nodes used to enforce the limit,
+// typically from the shared node informer. Annotation keys are under the domain
+// of f.
+func NewTainter(updater Updater, f *LabelFormat, list func() ([]*corev1.Node, error), taint corev1.Taint, maxPercent int) *Tainter {
+	return &Tainter{
+		updater:    updater,
+		format:     f,
+		list:       list,
+		taint:      taint,
+		maxPercent: maxPercent,
+		written:    make(map[string]bool),
+	}
+}
+
+// Reconcile taints nodeName when issues is non-empty and removes the taint when
+// it is empty. The issues are recorded in AnnotationHealthIssues. A taint that
+// would exceed the limit is skipped and logged, not returned as an error.
+func (t *Tainter) Reconcile(ctx context.Context, log *slog.Logger, nodeName string, issues []string) error {
+	if nodeName == "" {
+		return fmt.Errorf("node name is required")
+	}
+	if t == nil || t.updater == nil {
+		return fmt.Errorf("node updater is nil")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	unhealthy := len(issues) > 0
+	desiredIssues := strings.Join(issues, ",")
+
+	err := retry.OnError(taintBackoff, errors.IsConflict, func() error {
+		n, err := t.updater.GetNode(ctx, nodeName)
+		if err != nil {
+			return fmt.Errorf("failed to get node %s: %w", nodeName, err)
+		}
+
+		tainted := hasTaint(n.Spec.Taints, t.taint)
+		currentIssues, hasIssues := n.Annotations[t.format.Key(AnnotationHealthIssues)]
+		if tainted == unhealthy && currentIssues == desiredIssues && (hasIssues || !unhealthy) {
+			return nil
+		}
+
+		if unhealthy && !tainted {
+			ok, lErr := t.withinLimit(nodeName)
+			if lErr != nil {
+				return lErr
+			}
+			if !ok {
+				counterTaint.Increment(taintSuppressed)
+				log.Warn("not tainting unhealthy node, max taint percentage reached", "node", nodeName, "max_percent", t.maxPercent, "issues", desiredIssues)
+				return nil
+			}
+		}
+
+		taints := withoutTaint(n.Spec.Taints, t.taint)
+		if unhealthy {
+			taints = append(taints, t.taint)
+		}
+
+		var issuesValue any
+		if unhealthy {
+			issuesValue = desiredIssues
+		}
+
+		// Taints have no merge key, so the whole list is replaced; the resource
+		// version turns a concurrent change by another writer into a conflict.
+		patch, err := json.Marshal(map[string]any{
+			"metadata": map[string]any{
+				"resourceVersion": n.ResourceVersion,
+				"annotations":     map[string]any{t.format.Key(AnnotationHealthIssues): issuesValue},
+			},
+			"spec": map[string]any{"taints": taints},
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal taint patch: %w", err)
+		}
+
+		if err := t.updater.PatchNode(ctx, nodeName, patch); err != nil {
+			return err
+		}
+
+		switch {
+		case unhealthy && !tainted:
+			counterTaint.Increment(taintApplied)
+			log.Warn("tainted node with unhealthy GPUs", "node", nodeName, "taint", t.taint.ToString(), "issues", desiredIssues)
+		case !unhealthy && tainted:
+			counterTaint.Increment(taintRemoved)
+			log.Info("removed health taint, GPUs recovered", "node", nodeName, "taint", t.taint.ToString())
+		}
+		t.written[nodeName] = unhealthy
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to reconcile health taint on node %s: %w", nodeName, err)
+	}
+	return nil
+}
+
+// withinLimit reports whether tainting nodeName keeps the tainted share of scanned
+// nodes within maxPercent. At least one node may always be tainted.
+func (t *Tainter) withinLimit(nodeName string) (bool, error) {
+	nodes, err := t.list()
+	if err != nil {
+		return false, fmt.Errorf("failed to list nodes: %w", err)
+	}
+
+	fleet, tainted := 1, 0 // nodeName is in the fleet even before its first scan is recorded
+	live := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		live[n.Name] = true
+		if n.Name == nodeName {
+			continue
+		}
+		if _, scanned := n.Annotations[t.format.Key(AnnotationLastScan)]; !scanned {
+			continue
+		}
+		fleet++
+
+		isTainted := hasTaint(n.Spec.Taints, t.taint)
+		if w, ok := t.written[n.Name]; ok {
+			isTainted = w
+		}
+		if isTainted {
+			tainted++
+		}
+	}
+
+	// Forget what was written to nodes that have since been deleted.
+	for name := range t.written {
+		if !live[name] && name != nodeName {
+			delete(t.written, name)
+		}
+	}
+
+	limit := max(1, fleet*t.maxPercent/100)
+	return tainted+1 <= limit, nil
+}
+
+func hasTaint(taints []corev1.Taint, t corev1.Taint) bool {
+	for i := range taints {
+		if taints[i].MatchTaint(&t) {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutTaint(taints []corev1.Taint, t corev1.Taint) []corev1.Taint {
+	out := make([]corev1.Taint, 0, len(taints))
+	for i := range taints {
+		if !taints[i].MatchTaint(&t) {
+			out = append(out, taints[i])
+		}
+	}
+	return out
+}
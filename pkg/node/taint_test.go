@@ -0,0 +1,153 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+)
+
+var testTaint = corev1.Taint{Key: "gpuid.github.com/gpu-unhealthy", Value: "true", Effect: corev1.TaintEffectNoSchedule}
+
+func TestParseTaint(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    corev1.Taint
+		wantErr bool
+	}{
+		{spec: "gpuid.github.com/gpu-unhealthy=true:NoSchedule", want: testTaint},
+		{spec: "gpu-unhealthy:NoExecute", want: corev1.Taint{Key: "gpu-unhealthy", Effect: corev1.TaintEffectNoExecute}},
+		{spec: "gpu-unhealthy=true", wantErr: true},
+		{spec: "gpu-unhealthy=true:Sometimes", wantErr: true},
+		{spec: "=true:NoSchedule", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := ParseTaint(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTaint() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("ParseTaint() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHealthIssues(t *testing.T) {
+	serials := []*gpu.Serials{{
+		Attached: 3,
+		GPU:      []string{"1", "2"},
+		Devices: []gpu.Device{
+			{Serial: "1", UUID: "GPU-a", Issues: []string{gpu.IssueUncorrectableECC}},
+			{Serial: "2", UUID: "GPU-b"},
+		},
+	}}
+
+	want := []string{"GPU-a:" + gpu.IssueUncorrectableECC, IssueMissingGPU + ":1/3"}
+	if got := HealthIssues(serials); !reflect.DeepEqual(got, want) {
+		t.Errorf("HealthIssues() = %v, want %v", got, want)
+	}
+
+	serials[0].Attached = 2
+	serials[0].Devices[0].Issues = nil
+	if got := HealthIssues(serials); len(got) != 0 {
+		t.Errorf("HealthIssues() = %v, want none", got)
+	}
+}
+
+func TestTainterReconcile(t *testing.T) {
+	ctx := context.Background()
+	other := corev1.Taint{Key: "other", Effect: corev1.TaintEffectNoSchedule}
+	m := NewMockUpdater(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+		Spec:       corev1.NodeSpec{Taints: []corev1.Taint{other}},
+	})
+	tainter := NewTainter(m, nil, func() ([]*corev1.Node, error) { return nil, nil }, testTaint, 10)
+
+	if err := tainter.Reconcile(ctx, slog.Default(), "node-1", []string{"GPU-a:uncorrectable_ecc"}); err != nil {
+		t.Fatalf("Reconcile() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(m.node.Spec.Taints, []corev1.Taint{other, testTaint}) {
+		t.Errorf("taints = %v", m.node.Spec.Taints)
+	}
+	if m.node.Annotations[defaultLabelFormat.Key(AnnotationHealthIssues)] != "GPU-a:uncorrectable_ecc" {
+		t.Errorf("health issues annotation = %q", m.node.Annotations[defaultLabelFormat.Key(AnnotationHealthIssues)])
+	}
+
+	// Still unhealthy with the same issues: no patch.
+	before := m.updateCount
+	if err := tainter.Reconcile(ctx, slog.Default(), "node-1", []string{"GPU-a:uncorrectable_ecc"}); err != nil {
+		t.Fatalf("Reconcile() unexpected error: %v", err)
+	}
+	if m.updateCount != before {
+		t.Error("expected no patch when nothing changed")
+	}
+
+	// Recovered: the taint and annotation go, other taints stay.
+	if err := tainter.Reconcile(ctx, slog.Default(), "node-1", nil); err != nil {
+		t.Fatalf("Reconcile() unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(m.node.Spec.Taints, []corev1.Taint{other}) {
+		t.Errorf("taints = %v", m.node.Spec.Taints)
+	}
+	if _, ok := m.node.Annotations[defaultLabelFormat.Key(AnnotationHealthIssues)]; ok {
+		t.Error("health issues annotation should be removed")
+	}
+}
+
+func TestTainterMaxPercent(t *testing.T) {
+	// 20 scanned nodes, 2 already tainted: a 10% limit allows no more.
+	var fleet []*corev1.Node
+	for i := range 20 {
+		n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("node-%d", i),
+			Annotations: map[string]string{defaultLabelFormat.Key(AnnotationLastScan): "2026-01-01T00:00:00Z"},
+		}}
+		if i < 2 {
+			n.Spec.Taints = []corev1.Taint{testTaint}
+		}
+		fleet = append(fleet, n)
+	}
+	list := func() ([]*corev1.Node, error) { return fleet, nil }
+
+	m := NewMockUpdater(fleet[5].DeepCopy())
+	if err := NewTainter(m, nil, list, testTaint, 10).Reconcile(context.Background(), slog.Default(), "node-5", []string{"missing_gpu:1/8"}); err != nil {
+		t.Fatalf("Reconcile() unexpected error: %v", err)
+	}
+	if len(m.node.Spec.Taints) != 0 {
+		t.Error("taint should be suppressed by the max percentage limit")
+	}
+
+	if err := NewTainter(m, nil, list, testTaint, 15).Reconcile(context.Background(), slog.Default(), "node-5", []string{"missing_gpu:1/8"}); err != nil {
+		t.Fatalf("Reconcile() unexpected error: %v", err)
+	}
+	if len(m.node.Spec.Taints) != 1 {
+		t.Error("taint should be applied within the limit")
+	}
+}
+
+func TestTainterForgetsDeletedNodes(t *testing.T) {
+	fleet := []*corev1.Node{{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}}}
+	list := func() ([]*corev1.Node, error) { return fleet, nil }
+	tn := NewTainter(NewMockUpdater(fleet[0].DeepCopy()), nil, list, testTaint, 100)
+	tn.written["deleted"] = true
+	tn.written["node-1"] = false
+
+	if _, err := tn.withinLimit("node-1"); err != nil {
+		t.Fatalf("withinLimit() unexpected error: %v", err)
+	}
+	if _, ok := tn.written["deleted"]; ok {
+		t.Error("written state of a deleted node should be dropped")
+	}
+	if _, ok := tn.written["node-1"]; !ok {
+		t.Error("written state of a live node should be kept")
+	}
+}
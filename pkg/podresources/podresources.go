@@ -0,0 +1,108 @@
+// Package podresources reads device allocations from the kubelet pod-resources
+// API, which reports the device IDs (GPU UUIDs for the NVIDIA device plugin)
+// assigned to each container on the node.
+package podresources
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+const (
+	// DefaultSocket is where the kubelet serves the pod-resources API.
+	DefaultSocket = "/var/lib/kubelet/pod-resources/kubelet.sock"
+
+	// maxMessageSize bounds List responses; the kubelet uses the same limit.
+	maxMessageSize = 16 * 1024 * 1024
+
+	// listTimeout bounds a single List call.
+	listTimeout = 10 * time.Second
+)
+
+// Allocation is the set of devices of one resource assigned to a pod, across
+// all of its containers.
+type Allocation struct {
+	Namespace string
+	Pod       string
+	DeviceIDs []string // sorted, deduplicated
+}
+
+// Key returns the namespace/name of the pod.
+func (a Allocation) Key() string {
+	return a.Namespace + "/" + a.Pod
+}
+
+// Client lists device allocations over the kubelet pod-resources socket.
+type Client struct {
+	conn   *grpc.ClientConn
+	client podresourcesapi.PodResourcesListerClient
+}
+
+// New creates a Client for the Unix socket at path. The connection is made
+// lazily, so a missing socket surfaces on the first List.
+func New(path string) (*Client, error) {
+	if path == "" {
+		return nil, fmt.Errorf("pod-resources socket path is required")
+	}
+	conn, err := grpc.NewClient("unix://"+path,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultCallOptions(grpc.MaxCallRecvMsgSize(maxMessageSize)),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pod-resources client for %s: %w", path, err)
+	}
+	return &Client{conn: conn, client: podresourcesapi.NewPodResourcesListerClient(conn)}, nil
+}
+
+// List returns the pods on the node that have devices of resource allocated,
+// sorted by namespace/name.
+func (c *Client) List(ctx context.Context, resource string) ([]Allocation, error) {
+	lctx, cancel := context.WithTimeout(ctx, listTimeout)
+	defer cancel()
+
+	resp, err := c.client.List(lctx, &podresourcesapi.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pod resources: %w", err)
+	}
+	return allocations(resp.GetPodResources(), resource), nil
+}
+
+// Close closes the connection to the kubelet.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+func allocations(pods []*podresourcesapi.PodResources, resource string) []Allocation {
+	var out []Allocation
+	for _, p := range pods {
+		seen := make(map[string]bool)
+		for _, c := range p.GetContainers() {
+			for _, d := range c.GetDevices() {
+				if d.GetResourceName() != resource {
+					continue
+				}
+				for _, id := range d.GetDeviceIds() {
+					seen[id] = true
+				}
+			}
+		}
+		if len(seen) == 0 {
+			continue
+		}
+
+		a := Allocation{Namespace: p.GetNamespace(), Pod: p.GetName(), DeviceIDs: make([]string, 0, len(seen))}
+		for id := range seen {
+			a.DeviceIDs = append(a.DeviceIDs, id)
+		}
+		sort.Strings(a.DeviceIDs)
+		out = append(out, a)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key() < out[j].Key() })
+	return out
+}
@@ -0,0 +1,107 @@
+package podresources
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	podresourcesapi "k8s.io/kubelet/pkg/apis/podresources/v1"
+)
+
+// fakeLister serves a fixed List response.
+type fakeLister struct {
+	podresourcesapi.UnimplementedPodResourcesListerServer
+	pods []*podresourcesapi.PodResources
+}
+
+func (f *fakeLister) List(context.Context, *podresourcesapi.ListPodResourcesRequest) (*podresourcesapi.ListPodResourcesResponse, error) {
+	return &podresourcesapi.ListPodResourcesResponse{PodResources: f.pods}, nil
+}
+
+// serve starts a fake pod-resources server on a Unix socket and returns its path.
+func serve(t *testing.T, pods []*podresourcesapi.PodResources) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kubelet.sock")
+	lis, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", path, err)
+	}
+	srv := grpc.NewServer()
+	podresourcesapi.RegisterPodResourcesListerServer(srv, &fakeLister{pods: pods})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+	return path
+}
+
+func devices(resource string, ids ...string) *podresourcesapi.ContainerDevices {
+	return &podresourcesapi.ContainerDevices{ResourceName: resource, DeviceIds: ids}
+}
+
+func TestList(t *testing.T) {
+	path := serve(t, []*podresourcesapi.PodResources{
+		{
+			Namespace: "team-b",
+			Name:      "infer-0",
+			Containers: []*podresourcesapi.ContainerResources{
+				{Name: "main", Devices: []*podresourcesapi.ContainerDevices{devices("nvidia.com/gpu", "GPU-3")}},
+			},
+		},
+		{
+			Namespace: "team-a",
+			Name:      "train-0",
+			Containers: []*podresourcesapi.ContainerResources{
+				{Name: "main", Devices: []*podresourcesapi.ContainerDevices{devices("nvidia.com/gpu", "GPU-2", "GPU-1")}},
+				{Name: "sidecar", Devices: []*podresourcesapi.ContainerDevices{
+					devices("nvidia.com/gpu", "GPU-1"),
+					devices("example.com/nic", "nic-0"),
+				}},
+			},
+		},
+		{
+			Namespace: "team-a",
+			Name:      "cpu-only",
+			Containers: []*podresourcesapi.ContainerResources{
+				{Name: "main", Devices: []*podresourcesapi.ContainerDevices{devices("example.com/nic", "nic-1")}},
+			},
+		},
+	})
+
+	c, err := New(path)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+
+	got, err := c.List(context.Background(), "nvidia.com/gpu")
+	if err != nil {
+		t.Fatalf("List() error = %v", err)
+	}
+	want := []Allocation{
+		{Namespace: "team-a", Pod: "train-0", DeviceIDs: []string{"GPU-1", "GPU-2"}},
+		{Namespace: "team-b", Pod: "infer-0", DeviceIDs: []string{"GPU-3"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List() = %+v, want %+v", got, want)
+	}
+}
+
+func TestListNoSocket(t *testing.T) {
+	c, err := New(filepath.Join(t.TempDir(), "missing.sock"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.List(context.Background(), "nvidia.com/gpu"); err == nil {
+		t.Error("List() expected an error for a missing socket")
+	}
+}
+
+func TestNewRequiresPath(t *testing.T) {
+	if _, err := New(""); err == nil {
+		t.Error("New(\"\") expected an error")
+	}
+}
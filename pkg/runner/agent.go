@@ -0,0 +1,302 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/node"
+	"github.com/mchmarny/gpuid/pkg/podresources"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// allocationLister lists the devices of a resource allocated to pods on this node.
+type allocationLister interface {
+	List(ctx context.Context, resource string) ([]podresources.Allocation, error)
+}
+
+// deviceRef is where a GPU UUID lives.
+type deviceRef struct {
+	serial  string
+	chassis string
+}
+
+// agent runs on a GPU node and exports exact pod to GPU assignments: the kubelet
+// pod-resources API reports the device IDs (GPU UUIDs) given to each pod, and the
+// local nvidia-smi output maps them to serial numbers. A record is exported when
+// an allocation appears and again, with End set, when it goes away. State is in
+// memory, so a restart re-exports the start records of running pods.
+type agent struct {
+	log     *slog.Logger
+	cs      kubernetes.Interface
+	cmd     *Command
+	lister  allocationLister
+	collect func(ctx context.Context) ([]*gpu.Serials, error)
+
+	machine string
+	devices map[string]deviceRef // GPU UUID -> serial and chassis; nil until collected
+	active  map[string]*gpu.AttributionRecord
+}
+
+// runAgent runs the agent on cmd.NodeName until ctx is canceled.
+func runAgent(ctx context.Context, log *slog.Logger, cs kubernetes.Interface, cmd *Command) error {
+	lister, err := podresources.New(cmd.PodResSocket)
+	if err != nil {
+		return err
+	}
+	defer lister.Close()
+
+	if !cmd.exporter.SupportsAttribution() {
+		log.Warn("exporter does not support workload attribution records, they will be dropped", "exporter", cmd.ExporterType)
+	}
+
+	a := newAgent(log, cs, cmd, lister, func(ctx context.Context) ([]*gpu.Serials, error) {
+		return gpu.GetLocalSerialNumbers(ctx, log, cmd.execOptions())
+	})
+
+	if info, iErr := node.GetNodeProviderID(ctx, log, node.NewLabelUpdater(cs), cmd.NodeName); iErr != nil {
+		log.Warn("failed to get node provider ID", "node", cmd.NodeName, "err", iErr)
+	} else {
+		a.machine = info.Identifier
+	}
+
+	log.Info("starting agent", "node", cmd.NodeName, "socket", cmd.PodResSocket, "interval", cmd.AgentInterval)
+	ticker := time.NewTicker(cmd.AgentInterval)
+	defer ticker.Stop()
+
+	for {
+		a.sync(ctx)
+		select {
+		case <-ctx.Done():
+			log.Info("agent shutdown complete")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func newAgent(log *slog.Logger, cs kubernetes.Interface, cmd *Command, lister allocationLister, collect func(context.Context) ([]*gpu.Serials, error)) *agent {
+	return &agent{
+		log:     log.With("component", "agent", "node", cmd.NodeName),
+		cs:      cs,
+		cmd:     cmd,
+		lister:  lister,
+		collect: collect,
+		active:  make(map[string]*gpu.AttributionRecord),
+	}
+}
+
+// sync compares the current allocations with the exported ones and exports the
+// differences. Failed exports are retried on the next sync.
+func (a *agent) sync(ctx context.Context) {
+	if a.devices == nil {
+		if err := a.refreshDevices(ctx); err != nil {
+			a.log.Error("failed to collect GPU serial numbers", "err", err)
+			return
+		}
+	}
+
+	allocs, err := a.lister.List(ctx, a.cmd.WorkloadResource)
+	if err != nil {
+		a.log.Error("failed to list pod resources", "err", err)
+		return
+	}
+
+	// Devices unknown to the last scan mean the GPUs changed; rescan once.
+	for _, al := range allocs {
+		if slices.ContainsFunc(al.DeviceIDs, func(id string) bool { _, ok := a.devices[id]; return !ok }) {
+			if err := a.refreshDevices(ctx); err != nil {
+				a.log.Warn("failed to rescan GPUs for unknown device IDs", "err", err)
+			}
+			break
+		}
+	}
+
+	now := time.Now().UTC()
+	current := make(map[string]bool, len(allocs))
+	for _, al := range allocs {
+		key := al.Key()
+		current[key] = true
+
+		if prev, ok := a.active[key]; ok {
+			if a.sameDevices(prev, al) && a.samePod(ctx, key, prev) {
+				continue
+			}
+			// The name was reused by a new pod, or its devices changed.
+			if !a.finish(ctx, key, prev, now) {
+				continue
+			}
+		}
+		a.start(ctx, key, al)
+	}
+
+	for key, rec := range a.active {
+		if !current[key] {
+			a.finish(ctx, key, rec, now)
+		}
+	}
+}
+
+// start exports the start record of a new allocation.
+func (a *agent) start(ctx context.Context, key string, al podresources.Allocation) {
+	pod, err := a.cs.CoreV1().Pods(al.Namespace).Get(ctx, al.Pod, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			a.log.Warn("failed to get pod", "pod", key, "err", err)
+		}
+		return
+	}
+
+	r := &gpu.AttributionRecord{
+		Cluster:   a.cmd.Cluster,
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		UID:       string(pod.UID),
+		Owner:     podOwner(pod),
+		Node:      a.cmd.NodeName,
+		Machine:   a.machine,
+		Requested: int64(len(al.DeviceIDs)),
+		Exact:     true,
+		Start:     time.Now().UTC(),
+	}
+	if pod.Status.StartTime != nil {
+		r.Start = pod.Status.StartTime.UTC()
+	}
+
+	gpus, chassis := make(map[string]bool), make(map[string]bool)
+	for _, id := range al.DeviceIDs {
+		d, ok := a.devices[id]
+		if !ok {
+			// MIG devices and GPUs added since the last scan aren't mapped.
+			a.log.Debug("allocated device not found in GPU scan", "pod", key, "device", id)
+			r.Exact = false
+			continue
+		}
+		gpus[d.serial] = true
+		if d.chassis != "" {
+			chassis[d.chassis] = true
+		}
+	}
+	r.GPUs, r.Chassis = sortedKeys(gpus), sortedKeys(chassis)
+	if len(r.GPUs) == 0 {
+		a.log.Warn("no allocated device of pod maps to a GPU serial", "pod", key, "devices", strings.Join(al.DeviceIDs, ","))
+		return
+	}
+
+	if a.cmd.WorkloadAnnot {
+		if err := annotatePod(ctx, a.log, a.cs, a.cmd.labelFormat, pod, r.GPUs, r.Chassis, r.Exact); err != nil {
+			a.log.Warn("failed to annotate workload pod", "pod", key, "err", err)
+			return
+		}
+	}
+
+	if err := a.export(ctx, attributionStart, r); err != nil {
+		a.log.Error("failed to export workload attribution", "pod", key, "err", err)
+		return
+	}
+	a.active[key] = r
+}
+
+// finish exports the end record of an allocation that went away and reports
+// whether it was exported.
+func (a *agent) finish(ctx context.Context, key string, rec *gpu.AttributionRecord, now time.Time) bool {
+	end := *rec
+	end.End = now
+	if end.End.Before(end.Start) {
+		end.End = end.Start
+	}
+	if err := a.export(ctx, attributionEnd, &end); err != nil {
+		a.log.Error("failed to export workload attribution", "pod", key, "err", err)
+		return false
+	}
+	delete(a.active, key)
+	return true
+}
+
+func (a *agent) export(ctx context.Context, kind string, r *gpu.AttributionRecord) error {
+	ectx, cancel := context.WithTimeout(ctx, a.cmd.Timeout)
+	defer cancel()
+
+	if err := a.cmd.exporter.ExportAttributions(ectx, a.log, []*gpu.AttributionRecord{r}); err != nil {
+		if errors.Is(err, ErrAttributionUnsupported) {
+			return nil
+		}
+		counterAttribution.Increment(kind, "failure")
+		return fmt.Errorf("failed to export %s record: %w", kind, err)
+	}
+	counterAttribution.Increment(kind, "success")
+	a.log.Debug("workload attribution exported", "kind", kind, "pod", r.Namespace+"/"+r.Pod, "gpus", strings.Join(r.GPUs, ","))
+	return nil
+}
+
+// samePod reports whether the pod at key is still the one rec was exported for.
+// The kubelet reports allocations by name, so a pod recreated under the same
+// name (a StatefulSet replica, for example) only shows in its UID. When the pod
+// can't be read rec is kept and checked again on the next sync.
+func (a *agent) samePod(ctx context.Context, key string, rec *gpu.AttributionRecord) bool {
+	pod, err := a.cs.CoreV1().Pods(rec.Namespace).Get(ctx, rec.Pod, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return false
+		}
+		a.log.Warn("failed to get pod", "pod", key, "err", err)
+		return true
+	}
+	return string(pod.UID) == rec.UID
+}
+
+// sameDevices reports whether rec was exported for al's devices.
+func (a *agent) sameDevices(rec *gpu.AttributionRecord, al podresources.Allocation) bool {
+	return rec.Requested == int64(len(al.DeviceIDs)) && slices.Equal(rec.GPUs, a.serialsOf(al.DeviceIDs))
+}
+
+// serialsOf returns the sorted, deduplicated serials of the mapped device IDs.
+func (a *agent) serialsOf(ids []string) []string {
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if d, ok := a.devices[id]; ok {
+			set[d.serial] = true
+		}
+	}
+	return sortedKeys(set)
+}
+
+// refreshDevices rescans the local GPUs and rebuilds the UUID map.
+func (a *agent) refreshDevices(ctx context.Context) error {
+	cctx, cancel := context.WithTimeout(ctx, a.cmd.Timeout)
+	defer cancel()
+
+	serials, err := a.collect(cctx)
+	if err != nil {
+		return err
+	}
+	devices := make(map[string]deviceRef)
+	for _, s := range serials {
+		if s == nil {
+			continue
+		}
+		for _, d := range s.Devices {
+			devices[d.UUID] = deviceRef{serial: d.Serial, chassis: s.Chassis}
+		}
+	}
+	a.devices = devices
+	a.log.Debug("GPU devices scanned", "devices", len(devices))
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
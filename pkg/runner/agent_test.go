@@ -0,0 +1,189 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/podresources"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// fakeAllocations returns a settable set of allocations.
+type fakeAllocations struct {
+	allocs []podresources.Allocation
+}
+
+func (f *fakeAllocations) List(context.Context, string) ([]podresources.Allocation, error) {
+	return f.allocs, nil
+}
+
+func TestAgentSync(t *testing.T) {
+	ctx := context.Background()
+
+	pod := workloadPod(corev1.PodRunning, 2)
+	cs := fake.NewClientset(pod)
+
+	backend := &recordingBackend{}
+	cmd := NewCommand(WithClusterName("test"), WithAgentMode(true), WithNodeName("node-1"))
+	cmd.exporter = &Exporter{Type: "test", backend: backend}
+
+	scans := 0
+	collect := func(context.Context) ([]*gpu.Serials, error) {
+		scans++
+		return []*gpu.Serials{{
+			Chassis: "C1",
+			GPU:     []string{"S1", "S2", "S3"},
+			Devices: []gpu.Device{
+				{Serial: "S1", UUID: "GPU-1"},
+				{Serial: "S2", UUID: "GPU-2"},
+				{Serial: "S3", UUID: "GPU-3"},
+			},
+		}}, nil
+	}
+
+	lister := &fakeAllocations{allocs: []podresources.Allocation{
+		{Namespace: pod.Namespace, Pod: pod.Name, DeviceIDs: []string{"GPU-1", "GPU-3"}},
+	}}
+	a := newAgent(slog.Default(), cs, cmd, lister, collect)
+	a.machine = "i-123"
+
+	// New allocation: one exact start record with the allocated serials only.
+	a.sync(ctx)
+	a.sync(ctx)
+	if len(backend.records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(backend.records))
+	}
+	r := backend.records[0]
+	if !r.Exact || r.Requested != 2 || len(r.GPUs) != 2 || r.GPUs[0] != "S1" || r.GPUs[1] != "S3" ||
+		r.Chassis[0] != "C1" || r.Owner != "Job/train" || r.UID != string(pod.UID) || r.Machine != "i-123" || !r.End.IsZero() {
+		t.Errorf("unexpected start record: %+v", r)
+	}
+	if scans != 1 {
+		t.Errorf("expected 1 GPU scan, got %d", scans)
+	}
+
+	// Annotations are off by default.
+	if got, _ := cs.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{}); len(got.Annotations) != 0 {
+		t.Errorf("expected no annotations, got %v", got.Annotations)
+	}
+
+	// Allocation gone: one end record.
+	lister.allocs = nil
+	a.sync(ctx)
+	if len(backend.records) != 2 || backend.records[1].End.IsZero() || backend.records[1].UID != r.UID {
+		t.Fatalf("expected an end record, got %+v", backend.records)
+	}
+	if len(a.active) != 0 {
+		t.Errorf("expected no active allocations, got %d", len(a.active))
+	}
+
+	// Unknown device (e.g. a MIG instance): rescanned once, exported as inexact.
+	lister.allocs = []podresources.Allocation{
+		{Namespace: pod.Namespace, Pod: pod.Name, DeviceIDs: []string{"GPU-2", "MIG-9"}},
+	}
+	a.sync(ctx)
+	if scans != 2 {
+		t.Errorf("expected a rescan for an unknown device, got %d scans", scans)
+	}
+	if len(backend.records) != 3 || backend.records[2].Exact || backend.records[2].GPUs[0] != "S2" {
+		t.Errorf("expected an inexact start record, got %+v", backend.records[len(backend.records)-1])
+	}
+}
+
+func TestAgentRecreatedPod(t *testing.T) {
+	ctx := context.Background()
+	pod := workloadPod(corev1.PodRunning, 1)
+	cs := fake.NewClientset(pod)
+
+	backend := &recordingBackend{}
+	cmd := NewCommand(WithClusterName("test"), WithAgentMode(true), WithNodeName("node-1"))
+	cmd.exporter = &Exporter{Type: "test", backend: backend}
+
+	lister := &fakeAllocations{allocs: []podresources.Allocation{
+		{Namespace: pod.Namespace, Pod: pod.Name, DeviceIDs: []string{"GPU-1"}},
+	}}
+	a := newAgent(slog.Default(), cs, cmd, lister, func(context.Context) ([]*gpu.Serials, error) {
+		return []*gpu.Serials{{GPU: []string{"S1"}, Devices: []gpu.Device{{Serial: "S1", UUID: "GPU-1"}}}}, nil
+	})
+	a.sync(ctx)
+
+	// Recreated under the same name between syncs, on the same GPU.
+	if err := cs.CoreV1().Pods(pod.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	recreated := workloadPod(corev1.PodRunning, 1)
+	recreated.UID = "uid-2"
+	if _, err := cs.CoreV1().Pods(pod.Namespace).Create(ctx, recreated, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	a.sync(ctx)
+
+	if len(backend.records) != 3 {
+		t.Fatalf("expected start, end and start records, got %+v", backend.records)
+	}
+	if end := backend.records[1]; end.UID != string(pod.UID) || end.End.IsZero() {
+		t.Errorf("expected an end record for the old pod, got %+v", end)
+	}
+	if start := backend.records[2]; start.UID != "uid-2" || !start.End.IsZero() {
+		t.Errorf("expected a start record for the new pod, got %+v", start)
+	}
+}
+
+func TestAgentSkipsMissingPod(t *testing.T) {
+	backend := &recordingBackend{}
+	cmd := NewCommand(WithClusterName("test"), WithAgentMode(true), WithNodeName("node-1"))
+	cmd.exporter = &Exporter{Type: "test", backend: backend}
+
+	lister := &fakeAllocations{allocs: []podresources.Allocation{
+		{Namespace: "team-a", Pod: "gone", DeviceIDs: []string{"GPU-1"}},
+	}}
+	a := newAgent(slog.Default(), fake.NewClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "team-a"}}), cmd, lister,
+		func(context.Context) ([]*gpu.Serials, error) {
+			return []*gpu.Serials{{GPU: []string{"S1"}, Devices: []gpu.Device{{Serial: "S1", UUID: "GPU-1"}}}}, nil
+		})
+
+	a.sync(context.Background())
+	if len(backend.records) != 0 || len(a.active) != 0 {
+		t.Errorf("expected nothing exported for a missing pod, got %+v", backend.records)
+	}
+}
+
+func TestAgentAnnotatesPod(t *testing.T) {
+	ctx := context.Background()
+	pod := workloadPod(corev1.PodRunning, 1)
+	// Node-level annotations from the controller are replaced by exact ones.
+	pod.Annotations = map[string]string{
+		"gpuid.github.com/gpu-serials":       "S1,S2",
+		"gpuid.github.com/gpu-serials-exact": "false",
+	}
+	cs := fake.NewClientset(pod)
+
+	cmd := NewCommand(WithClusterName("test"), WithAgentMode(true), WithNodeName("node-1"), WithWorkloadAnnotations(true))
+	cmd.exporter = &Exporter{Type: "test", backend: &recordingBackend{}}
+
+	lister := &fakeAllocations{allocs: []podresources.Allocation{
+		{Namespace: pod.Namespace, Pod: pod.Name, DeviceIDs: []string{"GPU-2"}},
+	}}
+	a := newAgent(slog.Default(), cs, cmd, lister, func(context.Context) ([]*gpu.Serials, error) {
+		return []*gpu.Serials{{GPU: []string{"S1", "S2"}, Devices: []gpu.Device{
+			{Serial: "S1", UUID: "GPU-1"},
+			{Serial: "S2", UUID: "GPU-2"},
+		}}}, nil
+	})
+	a.sync(ctx)
+
+	got, err := cs.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Annotations["gpuid.github.com/gpu-serials"] != "S2" || got.Annotations["gpuid.github.com/gpu-serials-exact"] != "true" {
+		t.Errorf("unexpected annotations: %v", got.Annotations)
+	}
+	if _, ok := got.Annotations["gpuid.github.com/chassis"]; ok {
+		t.Errorf("expected no chassis annotation, got %v", got.Annotations)
+	}
+}
@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/node"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+)
+
+var counterPodAnnotation = counter.New("gpuid_workload_annotation_total", "Total number of GPU serial annotation patches on workload pods", "result")
+
+// annotatePod sets the GPU serial annotations, under the domain of f, on a
+// workload pod unless they are already in place.
+func annotatePod(ctx context.Context, log *slog.Logger, cs kubernetes.Interface, f *node.LabelFormat, pod *corev1.Pod, gpus, chassis []string, exact bool) error {
+	patch, err := node.PodAnnotationPatch(f, pod.Annotations, gpus, chassis, exact)
+	if err != nil || patch == nil {
+		return err
+	}
+
+	if _, err := cs.CoreV1().Pods(pod.Namespace).Patch(ctx, pod.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{}); err != nil {
+		counterPodAnnotation.Increment("failure")
+		return fmt.Errorf("failed to annotate pod %s/%s: %w", pod.Namespace, pod.Name, err)
+	}
+
+	counterPodAnnotation.Increment("success")
+	log.Debug("workload pod annotated", "pod", podKey(pod), "gpus", strings.Join(gpus, ","), "exact", exact)
+	return nil
+}
@@ -0,0 +1,532 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/node"
+	"github.com/mchmarny/gpuid/pkg/shard"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// maxAttributionRetries bounds the export retries of one workload pod.
+	maxAttributionRetries = 5
+
+	// Attribution record kind label values.
+	attributionStart = "start"
+	attributionEnd   = "end"
+)
+
+var counterAttribution = counter.New("gpuid_attribution_records_total", "Total number of workload attribution records exported", "kind", "result")
+
+// nodeScan is the last successful GPU scan of a node.
+type nodeScan struct {
+	machine string
+	chassis []string
+	gpus    []string
+}
+
+// workloadItem is a queued workload pod. Deleted pods are queued by UID, so the
+// end record of one isn't lost to a pod recreated under the same name.
+type workloadItem struct {
+	key string
+	uid types.UID // set for deleted pods
+}
+
+// attributor ties pods that request GPUs to the GPUs of the node they run on. It
+// exports a record when each pod starts running and when it finishes
+// (WORKLOAD_ATTRIBUTION) and annotates running pods with the serials
+// (WORKLOAD_ANNOTATIONS). It relies
+// on the device plugin scans for the node inventory, so a pod on a node that
+// hasn't been scanned yet waits for the scan. State is in memory, so a restart
+// re-exports the start records of running pods: delivery is at least once.
+type attributor struct {
+	log      *slog.Logger
+	cs       kubernetes.Interface
+	cmd      *Command
+	members  *shard.Membership
+	informer cache.SharedIndexInformer
+
+	mu      sync.Mutex
+	q       workqueue.TypedRateLimitingInterface[workloadItem] // nil between runs
+	nodes   map[string]nodeScan
+	waiting map[string]map[string]bool // node -> pod keys waiting for its scan
+	started map[types.UID]bool         // pods with an exported start record
+	done    map[types.UID]bool         // pods with an exported end record
+	deleted map[types.UID]*corev1.Pod  // last state of deleted pods
+}
+
+// newAttributor creates an attributor with an informer over pods in every
+// namespace that are bound to a node.
+func newAttributor(ctx context.Context, log *slog.Logger, cs kubernetes.Interface, cmd *Command, members *shard.Membership) (*attributor, error) {
+	sel := fields.OneTermNotEqualSelector("spec.nodeName", "").String()
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.FieldSelector = sel
+			return cs.CoreV1().Pods(metav1.NamespaceAll).List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.FieldSelector = sel
+			return cs.CoreV1().Pods(metav1.NamespaceAll).Watch(ctx, opts)
+		},
+	}
+
+	informer := cache.NewSharedIndexInformer(
+		cache.ToListWatcherWithWatchListSemantics(lw, cs),
+		&corev1.Pod{},
+		cmd.Resync,
+		cache.Indexers{},
+	)
+	if err := informer.SetTransform(transformWorkloadPod(corev1.ResourceName(cmd.WorkloadResource), cmd.labelFormat)); err != nil {
+		return nil, fmt.Errorf("failed to set workload pod informer transform: %w", err)
+	}
+
+	if cmd.WorkloadAttrib && !cmd.exporter.SupportsAttribution() {
+		log.Warn("exporter does not support workload attribution records, they will be dropped", "exporter", cmd.ExporterType)
+	}
+
+	return &attributor{
+		log:      log.With("component", "attribution"),
+		cs:       cs,
+		cmd:      cmd,
+		members:  members,
+		informer: informer,
+		nodes:    make(map[string]nodeScan),
+		waiting:  make(map[string]map[string]bool),
+		started:  make(map[types.UID]bool),
+		done:     make(map[types.UID]bool),
+		deleted:  make(map[types.UID]*corev1.Pod),
+	}, nil
+}
+
+// observeNode records the GPUs scanned on a node and re-evaluates the pods
+// that were waiting for it.
+func (a *attributor) observeNode(nodeName, machine string, serials []*gpu.Serials) {
+	scan := nodeScan{machine: machine}
+	for _, s := range serials {
+		if s == nil {
+			continue
+		}
+		if s.Chassis != "" {
+			scan.chassis = append(scan.chassis, s.Chassis)
+		}
+		scan.gpus = append(scan.gpus, s.GPU...)
+	}
+	sort.Strings(scan.chassis)
+	sort.Strings(scan.gpus)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.nodes[nodeName] = scan
+	if a.q != nil {
+		for key := range a.waiting[nodeName] {
+			a.q.Add(workloadItem{key: key})
+		}
+	}
+	delete(a.waiting, nodeName)
+}
+
+// forgetWaiting drops the pods waiting for the scan of nodeName, e.g. after the
+// node was deleted.
+func (a *attributor) forgetWaiting(nodeName string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.waiting, nodeName)
+}
+
+// run processes workload pod events until ctx is canceled. Like the controller,
+// each run gets a fresh queue and event handler. Pods waiting for a node deleted
+// from nodes are dropped.
+func (a *attributor) run(ctx context.Context, nodes cache.SharedIndexInformer) error {
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[workloadItem]())
+	a.mu.Lock()
+	a.q = q
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		a.q = nil
+		a.mu.Unlock()
+		q.ShutDown()
+	}()
+
+	tracked := func(pod *corev1.Pod) bool {
+		return gpuRequest(pod, corev1.ResourceName(a.cmd.WorkloadResource)) > 0 && ownsNode(a.members, pod.Spec.NodeName)
+	}
+	enqueue := func(obj any) {
+		if pod, ok := obj.(*corev1.Pod); ok && tracked(pod) {
+			q.Add(workloadItem{key: podKey(pod)})
+		}
+	}
+
+	reg, err := a.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueue,
+		UpdateFunc: func(_, newObj any) {
+			enqueue(newObj)
+		},
+		DeleteFunc: func(obj any) {
+			if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = t.Obj
+			}
+			pod, ok := obj.(*corev1.Pod)
+			if !ok || !tracked(pod) {
+				return
+			}
+			a.mu.Lock()
+			a.deleted[pod.UID] = pod
+			a.mu.Unlock()
+			q.Add(workloadItem{key: podKey(pod), uid: pod.UID})
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add workload pod event handler: %w", err)
+	}
+	defer func() {
+		if rErr := a.informer.RemoveEventHandler(reg); rErr != nil {
+			a.log.Warn("failed to remove workload pod event handler", "err", rErr)
+		}
+	}()
+
+	nodeReg, err := nodes.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj any) {
+			if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = t.Obj
+			}
+			if n, ok := obj.(*corev1.Node); ok {
+				a.forgetWaiting(n.Name)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add workload node event handler: %w", err)
+	}
+	defer func() {
+		if rErr := nodes.RemoveEventHandler(nodeReg); rErr != nil {
+			a.log.Warn("failed to remove workload node event handler", "err", rErr)
+		}
+	}()
+
+	if !cache.WaitForCacheSync(ctx.Done(), a.informer.HasSynced, reg.HasSynced) {
+		return errors.New("failed to wait for workload pod cache sync")
+	}
+
+	go func() {
+		<-ctx.Done()
+		q.ShutDownWithDrain()
+	}()
+
+	for {
+		item, shutdown := q.Get()
+		if shutdown {
+			return nil
+		}
+		func() {
+			defer q.Done(item)
+			if err := a.sync(ctx, item); err != nil {
+				if ctx.Err() == nil && q.NumRequeues(item) < maxAttributionRetries {
+					a.log.Warn("failed to export workload attribution, retrying", "pod", item.key, "err", err)
+					q.AddRateLimited(item)
+					return
+				}
+				a.log.Error("failed to export workload attribution, giving up", "pod", item.key, "err", err)
+				if item.uid != "" {
+					a.forget(item.uid)
+				}
+			}
+			q.Forget(item)
+		}()
+	}
+}
+
+// sync exports the start or end record of the pod in item if one is due.
+func (a *attributor) sync(ctx context.Context, item workloadItem) error {
+	if item.uid != "" {
+		return a.syncDeleted(ctx, item.uid)
+	}
+
+	key := item.key
+	obj, exists, err := a.informer.GetIndexer().GetByKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to get pod from cache: %w", err)
+	}
+	if !exists {
+		// Deletions are queued by UID; see syncDeleted.
+		return nil
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok || !ownsNode(a.members, pod.Spec.NodeName) {
+		// The node may have moved to another replica since the pod was queued.
+		return nil
+	}
+
+	a.mu.Lock()
+	started, done := a.started[pod.UID], a.done[pod.UID]
+	scan, scanned := a.nodes[pod.Spec.NodeName]
+	a.mu.Unlock()
+
+	finished := pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed
+	switch {
+	case done, started && !finished:
+		return nil
+	case pod.Status.Phase != corev1.PodRunning && !finished:
+		return nil
+	case !scanned:
+		a.mu.Lock()
+		// Nodes that moved to another replica won't be scanned here.
+		for name := range a.waiting {
+			if !ownsNode(a.members, name) {
+				delete(a.waiting, name)
+			}
+		}
+		if a.waiting[pod.Spec.NodeName] == nil {
+			a.waiting[pod.Spec.NodeName] = make(map[string]bool)
+		}
+		a.waiting[pod.Spec.NodeName][key] = true
+		a.mu.Unlock()
+		a.log.Debug("waiting for node scan", "pod", key, "node", pod.Spec.NodeName)
+		return nil
+	}
+
+	rec := a.record(pod, scan, finished)
+	kind := attributionStart
+	if finished {
+		kind = attributionEnd
+	} else if a.cmd.WorkloadAnnot {
+		actx, cancel := context.WithTimeout(ctx, a.cmd.Timeout)
+		defer cancel()
+		if err := annotatePod(actx, a.log, a.cs, a.cmd.labelFormat, pod, rec.GPUs, rec.Chassis, rec.Exact); err != nil {
+			return err
+		}
+	}
+	if err := a.export(ctx, kind, rec); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	if finished {
+		a.done[pod.UID] = true
+	} else {
+		a.started[pod.UID] = true
+	}
+	a.mu.Unlock()
+	return nil
+}
+
+// syncDeleted exports the end record of the deleted pod uid if its start was
+// exported, then drops its state.
+func (a *attributor) syncDeleted(ctx context.Context, uid types.UID) error {
+	a.mu.Lock()
+	pod := a.deleted[uid]
+	started, done := a.started[uid], a.done[uid]
+	var scan nodeScan
+	if pod != nil {
+		scan = a.nodes[pod.Spec.NodeName]
+		if w := a.waiting[pod.Spec.NodeName]; w != nil {
+			delete(w, podKey(pod))
+			if len(w) == 0 {
+				delete(a.waiting, pod.Spec.NodeName)
+			}
+		}
+	}
+	a.mu.Unlock()
+
+	if pod != nil && started && !done {
+		if err := a.export(ctx, attributionEnd, a.record(pod, scan, true)); err != nil {
+			return err
+		}
+	}
+	a.forget(uid)
+	return nil
+}
+
+// forget drops the state of a deleted pod.
+func (a *attributor) forget(uid types.UID) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.deleted, uid)
+	delete(a.started, uid)
+	delete(a.done, uid)
+}
+
+// export writes r through the exporter when WORKLOAD_ATTRIBUTION is enabled.
+func (a *attributor) export(ctx context.Context, kind string, r *gpu.AttributionRecord) error {
+	if !a.cmd.WorkloadAttrib {
+		return nil
+	}
+
+	ectx, cancel := context.WithTimeout(ctx, a.cmd.Timeout)
+	defer cancel()
+
+	if err := a.cmd.exporter.ExportAttributions(ectx, a.log, []*gpu.AttributionRecord{r}); err != nil {
+		if errors.Is(err, ErrAttributionUnsupported) {
+			return nil
+		}
+		counterAttribution.Increment(kind, "failure")
+		return err
+	}
+	counterAttribution.Increment(kind, "success")
+	a.log.Debug("workload attribution exported", "kind", kind, "pod", r.Namespace+"/"+r.Pod, "node", r.Node)
+	return nil
+}
+
+// record builds the attribution record of pod on the scanned node. Without the
+// device assignments from the kubelet every GPU on the node is listed, which is
+// exact only when the pod requested all of them.
+func (a *attributor) record(pod *corev1.Pod, scan nodeScan, finished bool) *gpu.AttributionRecord {
+	requested := gpuRequest(pod, corev1.ResourceName(a.cmd.WorkloadResource))
+	r := &gpu.AttributionRecord{
+		Cluster:   a.cmd.Cluster,
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		UID:       string(pod.UID),
+		Owner:     podOwner(pod),
+		Node:      pod.Spec.NodeName,
+		Machine:   scan.machine,
+		Chassis:   scan.chassis,
+		GPUs:      scan.gpus,
+		Requested: requested,
+		Exact:     requested == int64(len(scan.gpus)),
+		Start:     time.Now().UTC(),
+	}
+	if pod.Status.StartTime != nil {
+		r.Start = pod.Status.StartTime.UTC()
+	}
+	if finished {
+		r.End = podFinishedAt(pod)
+		if r.End.Before(r.Start) {
+			r.End = r.Start
+		}
+	}
+	return r
+}
+
+// podFinishedAt returns when the last container of pod terminated, or now if
+// the pod was deleted before reporting it.
+func podFinishedAt(pod *corev1.Pod) time.Time {
+	var end time.Time
+	for _, s := range pod.Status.ContainerStatuses {
+		if t := s.State.Terminated; t != nil && t.FinishedAt.After(end) {
+			end = t.FinishedAt.Time
+		}
+	}
+	if end.IsZero() {
+		return time.Now().UTC()
+	}
+	return end.UTC()
+}
+
+// podOwner returns the Kind/name of the pod's controlling owner, if any.
+func podOwner(pod *corev1.Pod) string {
+	if ref := metav1.GetControllerOf(pod); ref != nil {
+		return ref.Kind + "/" + ref.Name
+	}
+	return ""
+}
+
+// gpuRequest returns the number of GPUs pod requests: the larger of the sum over
+// its containers and its largest init container, as the scheduler counts it.
+// Extended resources can't be overcommitted, so a limit alone counts as a request.
+func gpuRequest(pod *corev1.Pod, name corev1.ResourceName) int64 {
+	get := func(c *corev1.Container) int64 {
+		if q, ok := c.Resources.Requests[name]; ok {
+			return q.Value()
+		}
+		if q, ok := c.Resources.Limits[name]; ok {
+			return q.Value()
+		}
+		return 0
+	}
+
+	var sum, initMax int64
+	for i := range pod.Spec.Containers {
+		sum += get(&pod.Spec.Containers[i])
+	}
+	for i := range pod.Spec.InitContainers {
+		initMax = max(initMax, get(&pod.Spec.InitContainers[i]))
+	}
+	return max(sum, initMax)
+}
+
+// transformWorkloadPod strips workload pods down to what attribution uses:
+// identity, the gpuid annotations, owner, node, phase, start and finish times,
+// and the GPU resource of each container. Pods that don't request the resource keep only their identity.
+func transformWorkloadPod(name corev1.ResourceName, f *node.LabelFormat) cache.TransformFunc {
+	keys := node.PodAnnotationKeys(f)
+	return func(obj any) (any, error) {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			return obj, nil
+		}
+
+		slim := &corev1.Pod{
+			TypeMeta: pod.TypeMeta,
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            pod.Name,
+				Namespace:       pod.Namespace,
+				UID:             pod.UID,
+				ResourceVersion: pod.ResourceVersion,
+			},
+		}
+		if gpuRequest(pod, name) == 0 {
+			return slim, nil
+		}
+
+		for _, k := range keys {
+			if v, ok := pod.Annotations[k]; ok {
+				if slim.Annotations == nil {
+					slim.Annotations = make(map[string]string, len(keys))
+				}
+				slim.Annotations[k] = v
+			}
+		}
+		slim.OwnerReferences = pod.OwnerReferences
+		slim.DeletionTimestamp = pod.DeletionTimestamp
+		slim.Spec.NodeName = pod.Spec.NodeName
+		slim.Spec.Containers = slimResources(pod.Spec.Containers, name)
+		slim.Spec.InitContainers = slimResources(pod.Spec.InitContainers, name)
+		slim.Status.Phase = pod.Status.Phase
+		slim.Status.StartTime = pod.Status.StartTime
+		for _, s := range pod.Status.ContainerStatuses {
+			if s.State.Terminated != nil {
+				slim.Status.ContainerStatuses = append(slim.Status.ContainerStatuses, corev1.ContainerStatus{
+					Name:  s.Name,
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: s.State.Terminated.FinishedAt}},
+				})
+			}
+		}
+		return slim, nil
+	}
+}
+
+// slimResources keeps the name and the GPU request and limit of each container.
+func slimResources(containers []corev1.Container, name corev1.ResourceName) []corev1.Container {
+	if len(containers) == 0 {
+		return nil
+	}
+	out := make([]corev1.Container, len(containers))
+	for i := range containers {
+		out[i].Name = containers[i].Name
+		if q, ok := containers[i].Resources.Requests[name]; ok {
+			out[i].Resources.Requests = corev1.ResourceList{name: q}
+		}
+		if q, ok := containers[i].Resources.Limits[name]; ok {
+			out[i].Resources.Limits = corev1.ResourceList{name: q}
+		}
+	}
+	return out
+}
@@ -0,0 +1,371 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/node"
+	"github.com/mchmarny/gpuid/pkg/shard"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+// recordingBackend captures the readings and attribution records it is asked to write.
+type recordingBackend struct {
+	mu       sync.Mutex
+	readings []*gpu.SerialNumberReading
+	records  []*gpu.AttributionRecord
+}
+
+func (b *recordingBackend) Write(_ context.Context, _ *slog.Logger, readings []*gpu.SerialNumberReading) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.readings = append(b.readings, readings...)
+	return nil
+}
+
+func (b *recordingBackend) WriteAttributions(_ context.Context, _ *slog.Logger, records []*gpu.AttributionRecord) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.records = append(b.records, records...)
+	return nil
+}
+
+func (b *recordingBackend) Close(context.Context) error  { return nil }
+func (b *recordingBackend) Health(context.Context) error { return nil }
+
+func workloadPod(phase corev1.PodPhase, gpus int64) *corev1.Pod {
+	isController := true
+	start := metav1.NewTime(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC))
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "train-0",
+			Namespace: "team-a",
+			UID:       types.UID("uid-1"),
+			OwnerReferences: []metav1.OwnerReference{
+				{Kind: "Job", Name: "train", Controller: &isController},
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: "node-1",
+			Containers: []corev1.Container{{
+				Name: "main",
+				Resources: corev1.ResourceRequirements{
+					Limits: corev1.ResourceList{"nvidia.com/gpu": *resource.NewQuantity(gpus, resource.DecimalSI)},
+				},
+			}},
+		},
+		Status: corev1.PodStatus{Phase: phase, StartTime: &start},
+	}
+}
+
+func TestGPURequest(t *testing.T) {
+	pod := workloadPod(corev1.PodRunning, 2)
+	pod.Spec.Containers = append(pod.Spec.Containers, corev1.Container{
+		Name: "sidecar",
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+		},
+	})
+	pod.Spec.InitContainers = []corev1.Container{{
+		Name: "init",
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("4")},
+		},
+	}}
+
+	if got := gpuRequest(pod, "nvidia.com/gpu"); got != 4 {
+		t.Errorf("gpuRequest() = %d, want 4 (largest init container)", got)
+	}
+	pod.Spec.InitContainers = nil
+	if got := gpuRequest(pod, "nvidia.com/gpu"); got != 3 {
+		t.Errorf("gpuRequest() = %d, want 3", got)
+	}
+	if got := gpuRequest(pod, "amd.com/gpu"); got != 0 {
+		t.Errorf("gpuRequest() = %d, want 0 for another resource", got)
+	}
+}
+
+func TestTransformWorkloadPod(t *testing.T) {
+	f, err := node.NewLabelFormat(node.LabelFormatConfig{Domain: "hw.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	transform := transformWorkloadPod("nvidia.com/gpu", f)
+
+	pod := workloadPod(corev1.PodRunning, 8)
+	pod.Spec.Containers[0].Env = []corev1.EnvVar{{Name: "A", Value: "b"}}
+	pod.Labels = map[string]string{"app": "train"}
+	pod.Annotations = map[string]string{"hw.example.com/gpu-serials": "S1", "gpuid.github.com/gpu-serials": "S2"}
+
+	obj, err := transform(pod)
+	if err != nil {
+		t.Fatalf("transform() error = %v", err)
+	}
+	slim := obj.(*corev1.Pod)
+	if gpuRequest(slim, "nvidia.com/gpu") != 8 || slim.Spec.NodeName != "node-1" || podOwner(slim) != "Job/train" {
+		t.Errorf("transform() dropped attribution fields: %+v", slim)
+	}
+	if slim.Labels != nil || slim.Spec.Containers[0].Env != nil {
+		t.Errorf("transform() kept unused fields: %+v", slim)
+	}
+	if !reflect.DeepEqual(slim.Annotations, map[string]string{"hw.example.com/gpu-serials": "S1"}) {
+		t.Errorf("transform() annotations = %v, want only the active domain's", slim.Annotations)
+	}
+
+	obj, err = transform(workloadPod(corev1.PodRunning, 0))
+	if err != nil {
+		t.Fatalf("transform() error = %v", err)
+	}
+	if slim := obj.(*corev1.Pod); slim.Spec.NodeName != "" || slim.Name != "train-0" {
+		t.Errorf("transform() of a non-GPU pod = %+v, want identity only", slim)
+	}
+}
+
+func TestAttributorLifecycle(t *testing.T) {
+	ctx := context.Background()
+	backend := &recordingBackend{}
+	cmd := NewCommand(WithClusterName("test"), WithWorkloadAttribution(true))
+	cmd.exporter = &Exporter{Type: "test", backend: backend}
+
+	a := &attributor{
+		log:      slog.Default(),
+		cmd:      cmd,
+		informer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &corev1.Pod{}, 0, cache.Indexers{}),
+		nodes:    make(map[string]nodeScan),
+		waiting:  make(map[string]map[string]bool),
+		started:  make(map[types.UID]bool),
+		done:     make(map[types.UID]bool),
+		deleted:  make(map[types.UID]*corev1.Pod),
+	}
+	store := a.informer.GetIndexer()
+
+	pod := workloadPod(corev1.PodRunning, 2)
+	key := podKey(pod)
+	if err := store.Add(pod); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not scanned yet: the pod waits for its node.
+	if err := a.sync(ctx, workloadItem{key: key}); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if len(backend.records) != 0 || !a.waiting["node-1"][key] {
+		t.Fatalf("expected pod to wait for the node scan, records = %d", len(backend.records))
+	}
+
+	a.observeNode("node-1", "i-123", []*gpu.Serials{{Chassis: "C1", GPU: []string{"G2", "G1"}}})
+	if len(a.waiting) != 0 {
+		t.Errorf("expected waiting pods to be released, got %v", a.waiting)
+	}
+
+	// Running: one start record, repeated syncs don't export again.
+	for range 2 {
+		if err := a.sync(ctx, workloadItem{key: key}); err != nil {
+			t.Fatalf("sync() error = %v", err)
+		}
+	}
+	if len(backend.records) != 1 {
+		t.Fatalf("expected 1 start record, got %d", len(backend.records))
+	}
+	r := backend.records[0]
+	if r.Owner != "Job/train" || r.Machine != "i-123" || !r.Exact || !r.End.IsZero() ||
+		len(r.GPUs) != 2 || r.GPUs[0] != "G1" || r.Chassis[0] != "C1" {
+		t.Errorf("unexpected start record: %+v", r)
+	}
+
+	// Succeeded: one end record with the container finish time.
+	finished := time.Date(2026, 1, 2, 5, 0, 0, 0, time.UTC)
+	done := pod.DeepCopy()
+	done.Status.Phase = corev1.PodSucceeded
+	done.Status.ContainerStatuses = []corev1.ContainerStatus{{
+		Name:  "main",
+		State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{FinishedAt: metav1.NewTime(finished)}},
+	}}
+	if err := store.Update(done); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sync(ctx, workloadItem{key: key}); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if len(backend.records) != 2 || !backend.records[1].End.Equal(finished) {
+		t.Fatalf("expected an end record at %v, got %+v", finished, backend.records)
+	}
+
+	// Deleted after finishing: nothing more is exported and the state is dropped.
+	if err := store.Delete(done); err != nil {
+		t.Fatal(err)
+	}
+	a.deleted[done.UID] = done
+	if err := a.sync(ctx, workloadItem{key: key, uid: done.UID}); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if len(backend.records) != 2 || len(a.started) != 0 || len(a.done) != 0 || len(a.deleted) != 0 {
+		t.Errorf("unexpected state after delete: records=%d started=%v done=%v", len(backend.records), a.started, a.done)
+	}
+}
+
+func TestAttributorWaiting(t *testing.T) {
+	ctx := context.Background()
+	cmd := NewCommand(WithClusterName("test"), WithWorkloadAttribution(true))
+	cmd.exporter = &Exporter{Type: "test", backend: &recordingBackend{}}
+
+	a := &attributor{
+		log:      slog.Default(),
+		cmd:      cmd,
+		informer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &corev1.Pod{}, 0, cache.Indexers{}),
+		nodes:    make(map[string]nodeScan),
+		waiting:  make(map[string]map[string]bool),
+		started:  make(map[types.UID]bool),
+		done:     make(map[types.UID]bool),
+		deleted:  make(map[types.UID]*corev1.Pod),
+	}
+	pod := workloadPod(corev1.PodRunning, 1)
+	key := podKey(pod)
+	if err := a.informer.GetIndexer().Add(pod); err != nil {
+		t.Fatal(err)
+	}
+
+	// A deleted pod stops waiting for its node.
+	if err := a.sync(ctx, workloadItem{key: key}); err != nil || !a.waiting["node-1"][key] {
+		t.Fatalf("expected pod to wait for the node scan, err = %v", err)
+	}
+	a.deleted[pod.UID] = pod
+	if err := a.sync(ctx, workloadItem{key: key, uid: pod.UID}); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if len(a.waiting) != 0 {
+		t.Errorf("expected deleted pod to stop waiting, got %v", a.waiting)
+	}
+
+	// So does every pod on a deleted node.
+	if err := a.sync(ctx, workloadItem{key: key}); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	a.forgetWaiting("node-1")
+	if len(a.waiting) != 0 {
+		t.Errorf("expected pods of a deleted node to stop waiting, got %v", a.waiting)
+	}
+
+	// Pods on nodes owned by another replica never wait here; before its first
+	// refresh a member owns no nodes.
+	members, err := shard.NewMembership(fake.NewClientset(), shard.Config{
+		Namespace: "gpuid", Group: "gpuid", Identity: "gpuid-0", LeaseDuration: 15 * time.Second, RenewPeriod: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.members = members
+	if err := a.sync(ctx, workloadItem{key: key}); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+	if len(a.waiting) != 0 {
+		t.Errorf("expected pods of unowned nodes not to wait, got %v", a.waiting)
+	}
+}
+
+func TestAttributorAnnotations(t *testing.T) {
+	ctx := context.Background()
+	pod := workloadPod(corev1.PodRunning, 2)
+	cs := fake.NewClientset(pod)
+
+	backend := &recordingBackend{}
+	cmd := NewCommand(WithClusterName("test"), WithWorkloadAnnotations(true))
+	cmd.exporter = &Exporter{Type: "test", backend: backend}
+
+	a := &attributor{
+		log:      slog.Default(),
+		cs:       cs,
+		cmd:      cmd,
+		informer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &corev1.Pod{}, 0, cache.Indexers{}),
+		nodes:    map[string]nodeScan{"node-1": {chassis: []string{"C1"}, gpus: []string{"G1", "G2", "G3"}}},
+		waiting:  make(map[string]map[string]bool),
+		started:  make(map[types.UID]bool),
+		done:     make(map[types.UID]bool),
+		deleted:  make(map[types.UID]*corev1.Pod),
+	}
+	if err := a.informer.GetIndexer().Add(pod); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pod has 2 of the node's 3 GPUs, so the serials are node-level.
+	if err := a.sync(ctx, workloadItem{key: podKey(pod)}); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+
+	got, err := cs.CoreV1().Pods(pod.Namespace).Get(ctx, pod.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Annotations["gpuid.github.com/gpu-serials"] != "G1,G2,G3" || got.Annotations["gpuid.github.com/chassis"] != "C1" ||
+		got.Annotations["gpuid.github.com/gpu-serials-exact"] != "false" {
+		t.Errorf("unexpected annotations: %v", got.Annotations)
+	}
+	if len(backend.records) != 0 {
+		t.Errorf("expected no records without WORKLOAD_ATTRIBUTION, got %d", len(backend.records))
+	}
+}
+
+func TestAttributorRecreatedPod(t *testing.T) {
+	ctx := context.Background()
+	backend := &recordingBackend{}
+	cmd := NewCommand(WithClusterName("test"), WithWorkloadAttribution(true))
+	cmd.exporter = &Exporter{Type: "test", backend: backend}
+
+	a := &attributor{
+		log:      slog.Default(),
+		cmd:      cmd,
+		informer: cache.NewSharedIndexInformer(&cache.ListWatch{}, &corev1.Pod{}, 0, cache.Indexers{}),
+		nodes:    map[string]nodeScan{"node-1": {chassis: []string{"C1"}, gpus: []string{"G1", "G2"}}},
+		waiting:  make(map[string]map[string]bool),
+		started:  make(map[types.UID]bool),
+		done:     make(map[types.UID]bool),
+		deleted:  make(map[types.UID]*corev1.Pod),
+	}
+	store := a.informer.GetIndexer()
+
+	old := workloadPod(corev1.PodRunning, 2)
+	key := podKey(old)
+	if err := store.Add(old); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sync(ctx, workloadItem{key: key}); err != nil {
+		t.Fatalf("sync() error = %v", err)
+	}
+
+	// The pod is deleted and recreated under the same name before the deletion
+	// is processed: the old pod still gets its end record.
+	if err := store.Delete(old); err != nil {
+		t.Fatal(err)
+	}
+	a.deleted[old.UID] = old
+	recreated := workloadPod(corev1.PodRunning, 2)
+	recreated.UID = "uid-2"
+	if err := store.Add(recreated); err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range []workloadItem{{key: key}, {key: key, uid: old.UID}} {
+		if err := a.sync(ctx, item); err != nil {
+			t.Fatalf("sync(%v) error = %v", item, err)
+		}
+	}
+
+	if len(backend.records) != 3 {
+		t.Fatalf("expected start, start and end records, got %d", len(backend.records))
+	}
+	if end := backend.records[2]; end.UID != "uid-1" || end.End.IsZero() {
+		t.Errorf("expected an end record for uid-1, got %+v", end)
+	}
+	if a.started[old.UID] || !a.started[recreated.UID] || len(a.deleted) != 0 {
+		t.Errorf("unexpected state: started=%v deleted=%v", a.started, a.deleted)
+	}
+}
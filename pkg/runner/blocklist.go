@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/mchmarny/gpuid/pkg/blocklist"
+	"github.com/mchmarny/gpuid/pkg/node"
+	"github.com/mchmarny/gpuid/pkg/shard"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// recheckOnBlocklistChange matches the serials recorded on every owned node
+// against the blocklist whenever it changes, so GPUs already in the fleet are
+// flagged, and cleared once delisted, without waiting for their next scan.
+func recheckOnBlocklistChange(ctx context.Context, log *slog.Logger, bl *blocklist.Blocklist, nodes func() []*corev1.Node, labeler node.Updater, cmd *Command, members *shard.Membership, recorder record.EventRecorder) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-bl.Changes():
+			recheckBlocklist(ctx, log, bl, nodes(), labeler, cmd, members, recorder)
+		}
+	}
+}
+
+// recheckBlocklist marks the owned nodes in nodes whose recorded serials match
+// bl. Failures are logged; the node is checked again on its next scan.
+func recheckBlocklist(ctx context.Context, log *slog.Logger, bl *blocklist.Blocklist, nodes []*corev1.Node, labeler node.Updater, cmd *Command, members *shard.Membership, recorder record.EventRecorder) {
+	checked, flagged := 0, 0
+	for _, n := range nodes {
+		if !ownsNode(members, n.Name) {
+			continue
+		}
+		recorded := node.RecordedSerials(cmd.labelFormat, n)
+		if len(recorded) == 0 && n.Annotations[cmd.labelFormat.Key(node.AnnotationBlocklisted)] == "" {
+			continue
+		}
+		checked++
+
+		matches := bl.Match(recorded)
+		rctx, cancel := context.WithTimeout(ctx, cmd.Timeout)
+		changed, err := node.MarkBlocklisted(rctx, log, labeler, cmd.labelFormat, n.Name, matches, cmd.BlocklistCordon)
+		cancel()
+		if err != nil {
+			log.Error("failed to record blocklisted serials after blocklist change", "node", n.Name, "err", err)
+			continue
+		}
+		if changed && len(matches) > 0 {
+			flagged++
+			if recorder != nil {
+				recorder.Eventf(nodeRef(n.Name), corev1.EventTypeWarning, eventReasonBlocklisted,
+					"Blocklisted serial numbers found on node: %s", strings.Join(matches, ", "))
+			}
+		}
+	}
+	log.Info("blocklist changed, re-checked recorded serials", "nodes", checked, "newly_flagged", flagged)
+}
@@ -0,0 +1,57 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mchmarny/gpuid/pkg/blocklist"
+	"github.com/mchmarny/gpuid/pkg/node"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRecheckBlocklist(t *testing.T) {
+	ctx := context.Background()
+	cpu := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cpu"}}
+	cs := fake.NewClientset(gpuNode("node-1"), cpu)
+	nodes := []*corev1.Node{gpuNode("node-1"), cpu}
+
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	if err := os.WriteFile(path, []byte("S2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	bl, err := blocklist.New(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bl.Refresh(ctx, slog.Default()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-bl.Changes():
+	default:
+		t.Fatal("expected a change signal after the first load")
+	}
+
+	cmd := NewCommand(WithBlocklistSource(path))
+	recheckBlocklist(ctx, slog.Default(), bl, nodes, node.NewLabelUpdater(cs), cmd, nil, nil)
+
+	n, err := cs.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := n.Annotations["gpuid.github.com/blocklisted-serials"]; got != "S2" {
+		t.Errorf("blocklisted annotation = %q, want %q", got, "S2")
+	}
+	n, err = cs.CoreV1().Nodes().Get(ctx, "cpu", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := n.Annotations["gpuid.github.com/blocklisted-serials"]; ok {
+		t.Error("node without recorded serials should not be annotated")
+	}
+}
@@ -0,0 +1,514 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/blocklist"
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/inventory"
+	"github.com/mchmarny/gpuid/pkg/nfd"
+	"github.com/mchmarny/gpuid/pkg/node"
+	"github.com/mchmarny/gpuid/pkg/shard"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+)
+
+var (
+	// gaugeFirstWork reports the delay between the start of a controller term
+	// (process start or leadership acquisition) and its first work item.
+	gaugeFirstWork = counter.NewGauge("gpuid_time_to_first_work_seconds", "Seconds from the start of the current controller term to its first work item")
+)
+
+// controller owns the informers for the lifetime of the process and runs the
+// work queue and workers for as long as a run context lives. Separating the two
+// lets a replica that loses its lease tear down the workers and warm-restart them
+// later against the same, already synced cache instead of exiting.
+type controller struct {
+	log     *slog.Logger
+	cs      *kubernetes.Clientset
+	cfg     *rest.Config
+	cmd     *Command
+	members *shard.Membership
+
+	// labeler reads nodes from nodeInformer and is safe to share across workers and runs.
+	labeler      node.Updater
+	informer     cache.SharedIndexInformer
+	nodeInformer cache.SharedIndexInformer
+
+	// actions are the optional per-node steps shared by every worker.
+	actions nodeActions
+	// events sends the recorded Events; nil unless a feature that emits them is enabled.
+	events record.EventBroadcaster
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+	stopCh    chan struct{}
+	wg        sync.WaitGroup
+}
+
+// newController builds the controller and its informers. The informer list/watch
+// calls are bound to ctx, so it must be the process context — not a lease context.
+// When members is non-nil only pods on nodes owned by this replica are processed.
+func newController(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, cfg *rest.Config, cmd *Command, members *shard.Membership) (*controller, error) {
+	if log == nil {
+		return nil, errors.New("logger is nil")
+	}
+	if cs == nil {
+		return nil, errors.New("kubernetes clientset is nil")
+	}
+	if cfg == nil {
+		return nil, errors.New("kubernetes config is nil")
+	}
+	if cmd == nil {
+		return nil, errors.New("command configuration is nil")
+	}
+
+	informer, err := newPodInformer(ctx, cs, cmd, transformPod)
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop the /status entry of deleted pods on every replica, not only in the
+	// term that happens to process the key next.
+	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{DeleteFunc: statuses.forget}); err != nil {
+		return nil, fmt.Errorf("failed to add pod status event handler: %w", err)
+	}
+
+	// Shared node cache so provider ID lookups and label diffing don't cost a
+	// live GET per processed pod during large rollouts.
+	nodeInformer := cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				return cs.CoreV1().Nodes().List(ctx, opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				return cs.CoreV1().Nodes().Watch(ctx, opts)
+			},
+		},
+		&corev1.Node{},
+		cmd.Resync,
+		cache.Indexers{},
+	)
+
+	nodeLister := corev1listers.NewNodeLister(nodeInformer.GetIndexer())
+	labeler := node.NewCachedLabelUpdater(cs, nodeLister)
+
+	// Deleted nodes drop out of the expected inventory report right away rather
+	// than counting their GPUs until the replica restarts.
+	if exp := cmd.expected; exp != nil {
+		if _, err := nodeInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			DeleteFunc: func(obj any) {
+				if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = t.Obj
+				}
+				if n, ok := obj.(*corev1.Node); ok {
+					exp.Forget(n.Name)
+				}
+			},
+		}); err != nil {
+			return nil, fmt.Errorf("failed to add expected inventory event handler: %w", err)
+		}
+	}
+
+	var inv *inventory.Writer
+	var features *nfd.Writer
+	if cmd.InventoryCRD || cmd.LabelOutput == LabelOutputNodeFeature {
+		dc, dErr := dynamic.NewForConfig(cfg)
+		if dErr != nil {
+			return nil, fmt.Errorf("failed to create dynamic client: %w", dErr)
+		}
+		if cmd.InventoryCRD {
+			inv = inventory.NewWriter(dc, labeler)
+		}
+		if cmd.LabelOutput == LabelOutputNodeFeature {
+			features = nfd.NewWriter(dc, cmd.FeatureNamespace)
+		}
+	}
+
+	var tainter *node.Tainter
+	if cmd.HealthTaint {
+		taint, tErr := node.ParseTaint(cmd.HealthTaintSpec)
+		if tErr != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidTaint, tErr)
+		}
+		tainter = node.NewTainter(labeler, cmd.labelFormat, func() ([]*corev1.Node, error) {
+			return nodeLister.List(labels.Everything())
+		}, taint, cmd.TaintMaxPercent)
+	}
+
+	var bl *blocklist.Blocklist
+	var events record.EventBroadcaster
+	var recorder record.EventRecorder
+	if cmd.BlocklistSource != "" {
+		b, bErr := blocklist.New(cmd.BlocklistSource, cs)
+		if bErr != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidBlocklist, bErr)
+		}
+		// Start with whatever loads now; a failed load is retried on every refresh.
+		if rErr := b.Refresh(ctx, log); rErr != nil {
+			log.Error("failed to load blocklist", "source", cmd.BlocklistSource, "err", rErr)
+		}
+		bl = b
+	}
+
+	var duplicates *inventory.SerialIndex
+	if cmd.DuplicateDetect {
+		duplicates = inventory.NewSerialIndex(cmd.DuplicateWindow, func(name string) bool {
+			_, gErr := nodeLister.Get(name)
+			return gErr == nil
+		})
+	}
+
+	var workloads *attributor
+	if cmd.WorkloadAttrib || cmd.WorkloadAnnot {
+		if workloads, err = newAttributor(ctx, log, cs, cmd, members); err != nil {
+			return nil, err
+		}
+	}
+
+	var removals *remover
+	if cmd.NodeRemovals {
+		removals = newRemover(log, cmd, members)
+	}
+
+	var keeper *labelKeeper
+	if cmd.LabelRepair || cmd.StaleLabelTTL > 0 {
+		keeper = newLabelKeeper(log, cmd, labeler, members, informer.GetStore(), listNodes(log, nodeLister.List))
+	}
+
+	if bl != nil || cmd.expected != nil || duplicates != nil {
+		events = record.NewBroadcaster()
+		events.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: cs.CoreV1().Events("")})
+		recorder = events.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "gpuid"})
+	}
+
+	return &controller{
+		log:          log,
+		cs:           cs,
+		cfg:          cfg,
+		cmd:          cmd,
+		members:      members,
+		labeler:      labeler,
+		informer:     informer,
+		nodeInformer: nodeInformer,
+		actions: nodeActions{
+			inventory:  inv,
+			features:   features,
+			tainter:    tainter,
+			blocklist:  bl,
+			expected:   cmd.expected,
+			duplicates: duplicates,
+			workloads:  workloads,
+			keeper:     keeper,
+			removals:   removals,
+			recorder:   recorder,
+		},
+		events: events,
+		stopCh: make(chan struct{}),
+	}, nil
+}
+
+// newPodInformer builds the pod informer for the configured namespace and selector.
+// A non-nil transform is applied to every object before it is cached.
+func newPodInformer(ctx context.Context, cs kubernetes.Interface, cmd *Command, transform cache.TransformFunc) (cache.SharedIndexInformer, error) {
+	// Create ListWatch for pod informer with proper error handling
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			opts.LabelSelector = cmd.PodLabelSelector
+			return cs.CoreV1().Pods(cmd.Namespace).List(ctx, opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.LabelSelector = cmd.PodLabelSelector
+			return cs.CoreV1().Pods(cmd.Namespace).Watch(ctx, opts)
+		},
+	}
+
+	informer := cache.NewSharedIndexInformer(
+		cache.ToListWatcherWithWatchListSemantics(lw, cs),
+		&corev1.Pod{},
+		cmd.Resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+
+	if transform != nil {
+		if err := informer.SetTransform(transform); err != nil {
+			return nil, fmt.Errorf("failed to set pod informer transform: %w", err)
+		}
+	}
+
+	return informer, nil
+}
+
+// start launches the informers and the blocklist and manifest refreshes. Safe to call multiple times; only the first call has effect.
+func (c *controller) start() {
+	c.startOnce.Do(func() {
+		c.log.Info("starting kubernetes informers")
+		c.wg.Go(func() {
+			c.informer.Run(c.stopCh)
+		})
+		c.wg.Go(func() {
+			c.nodeInformer.Run(c.stopCh)
+		})
+		if w := c.actions.workloads; w != nil {
+			c.wg.Go(func() {
+				w.informer.Run(c.stopCh)
+			})
+		}
+		if bl := c.actions.blocklist; bl != nil {
+			c.wg.Go(func() {
+				c.refreshEvery("blocklist", c.cmd.BlocklistRefresh, bl.Refresh)
+			})
+		}
+		if exp := c.actions.expected; exp != nil {
+			c.wg.Go(func() {
+				c.refreshEvery("expected inventory", c.cmd.ExpectedRefresh, exp.Refresh)
+			})
+		}
+	})
+}
+
+// firstPassDone reports whether every pod in pods that is still ready has been
+// processed, or has failed for good, since it was listed.
+func firstPassDone(store cache.Store, pods []any) bool {
+	for _, obj := range pods {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			continue
+		}
+		cur, exists, err := store.Get(pod)
+		if err != nil || !exists {
+			continue
+		}
+		p, ok := cur.(*corev1.Pod)
+		if !ok || p.UID != pod.UID || !podReady(p) {
+			continue
+		}
+		if !processed.Has(string(p.UID)) && !failed.Has(failureKey(p)) {
+			return false
+		}
+	}
+	return true
+}
+
+// refreshEvery calls refresh every period until stop. The initial load happens
+// before the controller starts, so the first call waits a full period.
+func (c *controller) refreshEvery(what string, period time.Duration, refresh func(context.Context, *slog.Logger) error) {
+	ctx := wait.ContextForChannel(c.stopCh)
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := refresh(ctx, c.log); err != nil {
+				c.log.Error("failed to refresh "+what+", keeping the previous one", "err", err)
+			}
+		}
+	}
+}
+
+// warmup starts the informers and blocks until their caches sync or ctx is
+// canceled. Followers call it before campaigning so a newly elected leader can
+// start workers immediately instead of listing the cluster from scratch.
+func (c *controller) warmup(ctx context.Context) bool {
+	c.start()
+	if !cache.WaitForCacheSync(ctx.Done(), c.cachesSynced()...) {
+		return false
+	}
+	c.log.Info("informer caches synced, standing by")
+	return true
+}
+
+// cachesSynced returns the HasSynced funcs of every informer the controller runs.
+func (c *controller) cachesSynced() []cache.InformerSynced {
+	synced := []cache.InformerSynced{c.informer.HasSynced, c.nodeInformer.HasSynced}
+	if w := c.actions.workloads; w != nil {
+		synced = append(synced, w.informer.HasSynced)
+	}
+	return synced
+}
+
+// stop shuts the informers down and waits for them to exit.
+func (c *controller) stop() {
+	c.stopOnce.Do(func() {
+		close(c.stopCh)
+	})
+	c.wg.Wait()
+	if c.events != nil {
+		c.events.Shutdown()
+	}
+}
+
+// run starts the informers if needed, then processes pods with cmd.Workers workers
+// until ctx is canceled. Every run gets a fresh queue and event handler; adding the
+// handler replays the cached pods, so a warm restart re-evaluates everything
+// without relisting from the API server.
+func (c *controller) run(ctx context.Context) error {
+	log := c.log
+	log.Info("starting controller...")
+
+	termStart := time.Now()
+	c.start()
+
+	// Rate-limiting queue to handle pod events without overwhelming the API server.
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
+	defer q.ShutDown()
+
+	enqueueIfReady := func(obj any) {
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			log.Warn("received non-pod object in event handler")
+			return
+		}
+
+		if !podReady(pod) {
+			log.Debug("skipping non-ready pod", "pod", pod.Name, "phase", pod.Status.Phase)
+			return
+		}
+
+		if !ownsNode(c.members, pod.Spec.NodeName) {
+			log.Debug("skipping pod on node owned by another replica", "pod", pod.Name, "node", pod.Spec.NodeName)
+			return
+		}
+
+		key, err := cache.MetaNamespaceKeyFunc(pod)
+		if err != nil {
+			log.Warn("failed to generate cache key", "pod", pod.Name, "err", err)
+			return
+		}
+
+		log.Debug("enqueueing ready pod", "pod", pod.Name, "key", key)
+		q.Add(key)
+	}
+
+	reg, err := c.informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueueIfReady,
+		UpdateFunc: func(oldObj, newObj any) {
+			if old, ok := oldObj.(*corev1.Pod); ok {
+				if cur, ok := newObj.(*corev1.Pod); ok && !podChanged(old, cur) {
+					return
+				}
+			}
+			enqueueIfReady(newObj)
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add event handler: %w", err)
+	}
+	defer func() {
+		if rErr := c.informer.RemoveEventHandler(reg); rErr != nil {
+			log.Warn("failed to remove event handler", "err", rErr)
+		}
+	}()
+
+	// Wait for cache to sync before starting workers so we have a consistent view.
+	if !cache.WaitForCacheSync(ctx.Done(), c.informer.HasSynced, c.nodeInformer.HasSynced, reg.HasSynced) {
+		return errors.New("failed to wait for informer cache sync")
+	}
+
+	// Record how long this term took to reach its first work item; on a warm
+	// standby this is the failover latency the informer pre-sync is meant to cut.
+	var firstWork sync.Once
+	onWork := func() {
+		firstWork.Do(func() {
+			d := time.Since(termStart)
+			gaugeFirstWork.Set(d.Seconds())
+			log.Info("first work item picked up", "since_start", d)
+		})
+	}
+
+	var wg sync.WaitGroup
+
+	log.Info("cache synced, starting workers")
+	for i := range c.cmd.Workers {
+		log.Debug("starting worker", "worker_id", i)
+		wg.Go(func() {
+			do(ctx, log.With("worker_id", i), c.cs, c.cfg, c.informer.GetIndexer(), q, c.labeler, c.actions, c.cmd, c.members, onWork)
+		})
+	}
+
+	if w := c.actions.workloads; w != nil {
+		wg.Go(func() {
+			if wErr := w.run(ctx, c.nodeInformer); wErr != nil {
+				log.Error("workload attribution stopped", "err", wErr)
+			}
+		})
+	}
+
+	if r := c.actions.removals; r != nil {
+		wg.Go(func() {
+			if rErr := r.run(ctx, c.nodeInformer); rErr != nil {
+				log.Error("node removal records stopped", "err", rErr)
+			}
+		})
+	}
+
+	if bl := c.actions.blocklist; bl != nil {
+		nodes := listNodes(log, corev1listers.NewNodeLister(c.nodeInformer.GetIndexer()).List)
+		wg.Go(func() {
+			recheckOnBlocklistChange(ctx, log, bl, nodes, c.labeler, c.cmd, c.members, c.actions.recorder)
+		})
+	}
+
+	if exp := c.actions.expected; exp != nil {
+		// Observations belong to this term; a replica that stops leading must
+		// not keep publishing them.
+		defer exp.Reset()
+		// A sharded replica never scans the whole fleet, so it only reports GPUs
+		// missing from the chassis it scanned.
+		if c.members == nil {
+			pending := c.informer.GetStore().List()
+			wg.Go(func() {
+				if wErr := wait.PollUntilContextCancel(ctx, time.Second, true, func(context.Context) (bool, error) {
+					return firstPassDone(c.informer.GetStore(), pending), nil
+				}); wErr == nil {
+					log.Info("first pass over the fleet done, reporting missing GPUs")
+					exp.Complete()
+				}
+			})
+		}
+	}
+
+	if k := c.actions.keeper; k != nil {
+		wg.Go(func() {
+			if kErr := k.run(ctx, c.nodeInformer); kErr != nil {
+				log.Error("label drift repair stopped", "err", kErr)
+			}
+		})
+	}
+
+	if c.members != nil {
+		wg.Go(func() {
+			rebalanceOnChange(ctx, log, c.members, c.informer.GetStore().List, enqueueIfReady)
+		})
+	}
+
+	<-ctx.Done()
+	log.Info("shutdown signal received, draining work queue")
+	q.ShutDownWithDrain()
+
+	wg.Wait()
+	log.Info("controller shutdown complete")
+
+	return nil
+}
@@ -0,0 +1,276 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/node"
+	"github.com/mchmarny/gpuid/pkg/shard"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+const (
+	// maxRepairRetries bounds the label repairs of one node per drift.
+	maxRepairRetries = 5
+
+	// maxStaleSweep caps the period between stale label sweeps.
+	maxStaleSweep = time.Minute
+
+	// Label keeper action label values.
+	labelActionRepair = "repair"
+	labelActionRemove = "remove"
+)
+
+var counterLabelDrift = counter.New("gpuid_label_drift_total", "Total number of node label repairs and stale label removals", "action", "result")
+
+// labelKeeper keeps node labels in line with the GPUs gpuid last found, outside
+// of the pod events that drive scans. With LABEL_REPAIR it re-applies the labels
+// of the last scan when someone edits or strips them. With STALE_LABEL_TTL it
+// removes the labels of nodes that have had no collection pod for that long,
+// e.g. after the device plugin was removed. Scans are kept in memory, so after a
+// restart a node is repaired only once its collection pod has been scanned again.
+type labelKeeper struct {
+	log     *slog.Logger
+	cmd     *Command
+	labeler node.Updater
+	members *shard.Membership
+	pods    cache.Store // collection pods
+	nodes   func() []*corev1.Node
+
+	mu       sync.Mutex
+	scans    map[string][]*gpu.Serials // last labeled serials by node
+	orphaned map[string]time.Time      // when a labeled node was first seen without a collection pod
+}
+
+func newLabelKeeper(log *slog.Logger, cmd *Command, labeler node.Updater, members *shard.Membership, pods cache.Store, nodes func() []*corev1.Node) *labelKeeper {
+	return &labelKeeper{
+		log:      log.With("component", "label-keeper"),
+		cmd:      cmd,
+		labeler:  labeler,
+		members:  members,
+		pods:     pods,
+		nodes:    nodes,
+		scans:    make(map[string][]*gpu.Serials),
+		orphaned: make(map[string]time.Time),
+	}
+}
+
+// observe records the serials about to be labeled on nodeName.
+func (k *labelKeeper) observe(nodeName string, serials []*gpu.Serials) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.scans[nodeName] = serials
+}
+
+// scan returns the last labeled serials of nodeName.
+func (k *labelKeeper) scan(nodeName string) ([]*gpu.Serials, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	s, ok := k.scans[nodeName]
+	return s, ok
+}
+
+// run repairs drifted labels of the nodes in informer and sweeps stale labels
+// until ctx is canceled.
+func (k *labelKeeper) run(ctx context.Context, informer cache.SharedIndexInformer) error {
+	if k.cmd.StaleLabelTTL > 0 {
+		go k.sweepEvery(ctx, min(k.cmd.StaleLabelTTL, maxStaleSweep))
+	}
+	if !k.cmd.LabelRepair {
+		<-ctx.Done()
+		return nil
+	}
+
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
+	defer q.ShutDown()
+
+	enqueueIfDrifted := func(obj any) {
+		n, ok := obj.(*corev1.Node)
+		if !ok || !ownsNode(k.members, n.Name) {
+			return
+		}
+		if k.drifted(n) {
+			q.Add(n.Name)
+		}
+	}
+
+	reg, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: enqueueIfDrifted,
+		UpdateFunc: func(_, newObj any) {
+			enqueueIfDrifted(newObj)
+		},
+		DeleteFunc: func(obj any) {
+			if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = t.Obj
+			}
+			if n, ok := obj.(*corev1.Node); ok {
+				k.forget(n.Name)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add label repair event handler: %w", err)
+	}
+	defer func() {
+		if rErr := informer.RemoveEventHandler(reg); rErr != nil {
+			k.log.Warn("failed to remove label repair event handler", "err", rErr)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		q.ShutDownWithDrain()
+	}()
+
+	for {
+		name, shutdown := q.Get()
+		if shutdown {
+			return nil
+		}
+		func() {
+			defer q.Done(name)
+			if err := k.repair(ctx, name); err != nil {
+				counterLabelDrift.Increment(labelActionRepair, "failure")
+				if ctx.Err() == nil && q.NumRequeues(name) < maxRepairRetries {
+					k.log.Warn("failed to repair node labels, retrying", "node", name, "err", err)
+					q.AddRateLimited(name)
+					return
+				}
+				k.log.Error("failed to repair node labels, giving up until the node changes", "node", name, "err", err)
+			}
+			q.Forget(name)
+		}()
+	}
+}
+
+// drifted reports whether the labels of n differ from its last scan.
+func (k *labelKeeper) drifted(n *corev1.Node) bool {
+	serials, ok := k.scan(n.Name)
+	if !ok {
+		return false
+	}
+	drifted, err := node.LabelsDrifted(k.log, n, serials, k.cmd.labelOptions())
+	if err != nil {
+		k.log.Warn("failed to compare node labels", "node", n.Name, "err", err)
+		return false
+	}
+	return drifted
+}
+
+// repair re-applies the labels of the last scan of nodeName.
+func (k *labelKeeper) repair(ctx context.Context, nodeName string) error {
+	serials, ok := k.scan(nodeName)
+	if !ok {
+		return nil
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, k.cmd.Timeout)
+	defer cancel()
+
+	if err := node.EnsureLabels(rctx, k.log, k.labeler, nodeName, serials, k.cmd.labelOptions()); err != nil {
+		return err
+	}
+	counterLabelDrift.Increment(labelActionRepair, "success")
+	k.log.Info("node labels drifted from the last scan, re-applied", "node", nodeName)
+	return nil
+}
+
+// sweepEvery removes stale labels every period until ctx is canceled.
+func (k *labelKeeper) sweepEvery(ctx context.Context, period time.Duration) {
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			k.sweep(ctx, time.Now())
+		}
+	}
+}
+
+// sweep removes the GPU labels of nodes that have had no collection pod for
+// StaleLabelTTL as of now.
+func (k *labelKeeper) sweep(ctx context.Context, now time.Time) {
+	withPod := make(map[string]bool)
+	for _, obj := range k.pods.List() {
+		if pod, ok := obj.(*corev1.Pod); ok && pod.Spec.NodeName != "" {
+			withPod[pod.Spec.NodeName] = true
+		}
+	}
+
+	opts := k.cmd.labelOptions()
+	labeled := make(map[string]bool)
+	for _, n := range k.nodes() {
+		if !ownsNode(k.members, n.Name) || !node.HasGPULabels(n, opts) {
+			continue
+		}
+		labeled[n.Name] = true
+		if withPod[n.Name] {
+			k.mu.Lock()
+			delete(k.orphaned, n.Name)
+			k.mu.Unlock()
+			continue
+		}
+
+		k.mu.Lock()
+		since, ok := k.orphaned[n.Name]
+		if !ok {
+			k.orphaned[n.Name] = now
+		}
+		k.mu.Unlock()
+		if !ok || now.Sub(since) < k.cmd.StaleLabelTTL {
+			continue
+		}
+
+		// Forget the scan first so the repair doesn't put the labels back.
+		k.forget(n.Name)
+		rctx, cancel := context.WithTimeout(ctx, k.cmd.Timeout)
+		err := node.EnsureLabels(rctx, k.log, k.labeler, n.Name, nil, opts)
+		cancel()
+		if err != nil {
+			counterLabelDrift.Increment(labelActionRemove, "failure")
+			k.log.Error("failed to remove stale node labels", "node", n.Name, "err", err)
+			continue
+		}
+		counterLabelDrift.Increment(labelActionRemove, "success")
+		k.log.Info("removed GPU labels of node without a collection pod", "node", n.Name, "since", since)
+	}
+
+	// Drop nodes that were deleted or lost their labels otherwise.
+	k.mu.Lock()
+	for name := range k.orphaned {
+		if !labeled[name] {
+			delete(k.orphaned, name)
+		}
+	}
+	k.mu.Unlock()
+}
+
+// forget drops the state of nodeName.
+func (k *labelKeeper) forget(nodeName string) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	delete(k.scans, nodeName)
+	delete(k.orphaned, nodeName)
+}
+
+// listNodes returns a func listing the nodes in lister.
+func listNodes(log *slog.Logger, list func(labels.Selector) ([]*corev1.Node, error)) func() []*corev1.Node {
+	return func() []*corev1.Node {
+		nodes, err := list(labels.Everything())
+		if err != nil {
+			log.Warn("failed to list nodes", "err", err)
+		}
+		return nodes
+	}
+}
@@ -0,0 +1,128 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/node"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestLabelKeeperRepair(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cs := fake.NewClientset(gpuNode("node-1"))
+	informer := informers.NewSharedInformerFactory(cs, 0).Core().V1().Nodes().Informer()
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatal("node cache did not sync")
+	}
+
+	cmd := NewCommand(WithLabelRepair(true))
+	k := newLabelKeeper(slog.Default(), cmd, node.NewLabelUpdater(cs), nil, cache.NewStore(cache.MetaNamespaceKeyFunc), nil)
+	k.observe("node-1", []*gpu.Serials{{Chassis: "C1", GPU: []string{"S1", "S2"}}})
+
+	done := make(chan error)
+	go func() { done <- k.run(ctx, informer) }()
+	// Let run register its handler before the edit.
+	time.Sleep(100 * time.Millisecond)
+
+	n, err := cs.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	delete(n.Labels, "gpuid.github.com/gpu-1")
+	if _, err := cs.CoreV1().Nodes().Update(ctx, n, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n, err := cs.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n.Labels["gpuid.github.com/gpu-1"] == "S2" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the removed label to be re-applied, got %v", n.Labels)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("run() error = %v", err)
+	}
+}
+
+func TestLabelKeeperSweep(t *testing.T) {
+	ctx := context.Background()
+	ttl := 10 * time.Minute
+
+	cs := fake.NewClientset(gpuNode("orphan"), gpuNode("scanned"))
+	pods := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	if err := pods.Add(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "p", Namespace: "gpuid"},
+		Spec:       corev1.PodSpec{NodeName: "scanned"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	nodes := func() []*corev1.Node {
+		list, err := cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]*corev1.Node, 0, len(list.Items))
+		for i := range list.Items {
+			out = append(out, &list.Items[i])
+		}
+		return out
+	}
+
+	cmd := NewCommand(WithStaleLabelTTL(ttl))
+	k := newLabelKeeper(slog.Default(), cmd, node.NewLabelUpdater(cs), nil, pods, nodes)
+	k.observe("orphan", []*gpu.Serials{{Chassis: "C1", GPU: []string{"S1", "S2"}}})
+
+	hasLabels := func(name string) bool {
+		n, err := cs.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return node.HasGPULabels(n, cmd.labelOptions())
+	}
+
+	now := time.Now()
+	k.sweep(ctx, now)
+	if !hasLabels("orphan") {
+		t.Fatal("expected labels to be kept on the first sweep")
+	}
+
+	k.sweep(ctx, now.Add(ttl-time.Second))
+	if !hasLabels("orphan") {
+		t.Fatal("expected labels to be kept before the TTL")
+	}
+
+	k.sweep(ctx, now.Add(ttl))
+	if hasLabels("orphan") {
+		t.Error("expected the labels of the orphaned node to be removed")
+	}
+	if !hasLabels("scanned") {
+		t.Error("expected the labels of the node with a collection pod to be kept")
+	}
+	if _, ok := k.scan("orphan"); ok {
+		t.Error("expected the scan of the orphaned node to be forgotten")
+	}
+	if len(k.orphaned) != 0 {
+		t.Errorf("expected no orphaned nodes left, got %v", k.orphaned)
+	}
+}
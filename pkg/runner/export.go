@@ -34,6 +34,13 @@ type ExporterConfig struct {
 	BatchSize  int           `json:"batch_size,omitempty" yaml:"batch_size,omitempty"`
 	RetryCount int           `json:"retry_count,omitempty" yaml:"retry_count,omitempty"`
 	Timeout    time.Duration `json:"timeout,omitempty" yaml:"timeout,omitempty"`
+
+	// Attribution is set when workload attribution records will be exported, so
+	// backends only prepare storage for them when needed.
+	Attribution bool `json:"attribution,omitempty" yaml:"attribution,omitempty"`
+	// Removals is set when node removal records will be exported, so backends
+	// only migrate storage for them when needed.
+	Removals bool `json:"removals,omitempty" yaml:"removals,omitempty"`
 }
 
 // ExporterBackend defines the interface that all exporter implementations must satisfy.
@@ -48,6 +55,17 @@ type ExporterBackend interface {
 	Health(ctx context.Context) error
 }
 
+// AttributionBackend is implemented by exporters that can also write workload
+// attribution records. It is optional so third-party backends keep compiling.
+type AttributionBackend interface {
+	// WriteAttributions exports the provided workload attribution records.
+	WriteAttributions(ctx context.Context, log *slog.Logger, records []*gpu.AttributionRecord) error
+}
+
+// ErrAttributionUnsupported is returned when the configured exporter does not
+// implement AttributionBackend.
+var ErrAttributionUnsupported = fmt.Errorf("exporter does not support workload attribution records")
+
 // Exporter wraps an ExporterBackend with metadata and provides a high-level interface
 // for exporting GPU serial number data in distributed systems.
 type Exporter struct {
@@ -97,7 +115,7 @@ func GetExporter(ctx context.Context, log *slog.Logger, config ExporterConfig) (
 			return nil, fmt.Errorf("failed to initialize S3 exporter: %w", err)
 		}
 	case ExporterTypePostgres:
-		e.backend, err = postgres.New(ctx)
+		e.backend, err = postgres.New(ctx, config.Attribution, config.Removals)
 		if err != nil {
 			return nil, fmt.Errorf("failed to initialize PostgreSQL exporter: %w", err)
 		}
@@ -200,6 +218,75 @@ func (e *Exporter) Export(ctx context.Context, log *slog.Logger, cluster string,
 	return nil
 }
 
+// ExportReadings validates and writes readings that don't come from a pod scan,
+// such as the removal records of a deleted node.
+func (e *Exporter) ExportReadings(ctx context.Context, log *slog.Logger, records []*gpu.SerialNumberReading) error {
+	if e == nil || e.backend == nil {
+		return fmt.Errorf("exporter not initialized")
+	}
+
+	valid := make([]*gpu.SerialNumberReading, 0, len(records))
+	for _, r := range records {
+		if r == nil {
+			continue
+		}
+		if err := r.Validate(); err != nil {
+			log.Warn("skipping invalid record", "error", err, "serial", r.GPU)
+			continue
+		}
+		valid = append(valid, r)
+	}
+	if len(valid) == 0 {
+		return nil
+	}
+
+	if err := e.backend.Write(ctx, log, valid); err != nil {
+		return fmt.Errorf("exporter backend failed (type=%s): %w", e.Type, err)
+	}
+	return nil
+}
+
+// SupportsAttribution reports whether the backend can write attribution records.
+func (e *Exporter) SupportsAttribution() bool {
+	if e == nil {
+		return false
+	}
+	_, ok := e.backend.(AttributionBackend)
+	return ok
+}
+
+// ExportAttributions validates and writes workload attribution records.
+func (e *Exporter) ExportAttributions(ctx context.Context, log *slog.Logger, records []*gpu.AttributionRecord) error {
+	if e == nil || e.backend == nil {
+		return fmt.Errorf("exporter not initialized")
+	}
+
+	ab, ok := e.backend.(AttributionBackend)
+	if !ok {
+		return fmt.Errorf("%w (type=%s)", ErrAttributionUnsupported, e.Type)
+	}
+
+	valid := make([]*gpu.AttributionRecord, 0, len(records))
+	for _, r := range records {
+		if r == nil {
+			continue
+		}
+		if err := r.Validate(); err != nil {
+			log.Warn("skipping invalid attribution record", "error", err, "pod", r.Namespace+"/"+r.Pod)
+			continue
+		}
+		valid = append(valid, r)
+	}
+	if len(valid) == 0 {
+		return nil
+	}
+
+	if err := ab.WriteAttributions(ctx, log, valid); err != nil {
+		return fmt.Errorf("exporter backend failed to write attributions (type=%s): %w", e.Type, err)
+	}
+	return nil
+}
+
 // Close performs cleanup of the exporter's resources.
 func (e *Exporter) Close(ctx context.Context) error {
 	if e == nil || e.backend == nil {
@@ -0,0 +1,100 @@
+package runner
+
+import (
+	"errors"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// failureReason classifies why processing a pod failed. It is used as the
+// `reason` label on gpuid_export_failure_total and selects the retry policy.
+type failureReason string
+
+const (
+	reasonExecTransport     failureReason = "exec_transport"     // exec stream could not be established or broke
+	reasonCommandNotFound   failureReason = "command_not_found"  // no container has the collection binary
+	reasonDriverError       failureReason = "driver_error"       // binary ran but exited non-zero (e.g. driver not loaded)
+	reasonParseError        failureReason = "parse_error"        // output was not valid nvidia-smi XML
+	reasonImagePull         failureReason = "image_pull"         // ephemeral collection image could not be pulled
+	reasonFallbackExhausted failureReason = "fallback_exhausted" // pod carries the maximum failed ephemeral containers
+	reasonLabelForbidden    failureReason = "label_forbidden"    // RBAC denies writing the node or its GPUInventory
+	reasonNodeAPI           failureReason = "node_api"           // other node read/patch failures
+	reasonExportFailure     failureReason = "export_failure"     // exporter rejected or failed the write
+)
+
+// unlimited retries until the pod goes away or processing succeeds.
+const unlimited = -1
+
+// retryPolicy is the number of rate-limited retries allowed per failure reason.
+// Permanent failures are not retried: the pod is re-evaluated on its next update
+// (e.g. a restart after a driver or image fix) instead of spinning in the queue.
+// Driver errors get a few attempts because the driver may still be loading at boot.
+var retryPolicy = map[failureReason]int{
+	reasonExecTransport:     unlimited,
+	reasonCommandNotFound:   0,
+	reasonDriverError:       3,
+	reasonParseError:        0,
+	reasonImagePull:         3,
+	reasonFallbackExhausted: 0,
+	reasonLabelForbidden:    0,
+	reasonNodeAPI:           unlimited,
+	reasonExportFailure:     unlimited,
+}
+
+// failure carries the classification of a processing error.
+type failure struct {
+	reason failureReason
+	err    error
+}
+
+func (f *failure) Error() string { return f.err.Error() }
+func (f *failure) Unwrap() error { return f.err }
+
+func fail(reason failureReason, err error) error { return &failure{reason: reason, err: err} }
+
+// reasonOf returns the failure reason of err, or "" if it is unclassified.
+func reasonOf(err error) failureReason {
+	var f *failure
+	if errors.As(err, &f) {
+		return f.reason
+	}
+	return ""
+}
+
+// shouldRetry reports whether a failed item that has already been requeued
+// requeues times may be retried again. Unclassified errors are not retried.
+func shouldRetry(err error, requeues int) bool {
+	limit, ok := retryPolicy[reasonOf(err)]
+	if !ok {
+		return false
+	}
+	return limit == unlimited || requeues < limit
+}
+
+// collectionReason classifies an error returned by gpu.GetSerialNumbers.
+func collectionReason(err error) failureReason {
+	switch {
+	case errors.Is(err, gpu.ErrCommandNotFound):
+		return reasonCommandNotFound
+	case errors.Is(err, gpu.ErrDriver):
+		return reasonDriverError
+	case errors.Is(err, gpu.ErrParse):
+		return reasonParseError
+	case errors.Is(err, gpu.ErrImagePull):
+		return reasonImagePull
+	case errors.Is(err, gpu.ErrEphemeralExhausted):
+		return reasonFallbackExhausted
+	default:
+		return reasonExecTransport
+	}
+}
+
+// labelReason classifies an error returned while labeling the node or writing
+// its GPUInventory resource.
+func labelReason(err error) failureReason {
+	if apierrors.IsForbidden(err) {
+		return reasonLabelForbidden
+	}
+	return reasonNodeAPI
+}
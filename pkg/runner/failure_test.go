@@ -0,0 +1,71 @@
+package runner
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestCollectionReason(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want failureReason
+	}{
+		{name: "not found", err: fmt.Errorf("exec: %w", gpu.ErrCommandNotFound), want: reasonCommandNotFound},
+		{name: "driver", err: fmt.Errorf("exec: %w", gpu.ErrDriver), want: reasonDriverError},
+		{name: "parse", err: fmt.Errorf("exec: %w", gpu.ErrParse), want: reasonParseError},
+		{name: "image pull", err: fmt.Errorf("ephemeral: %w", gpu.ErrImagePull), want: reasonImagePull},
+		{name: "fallback exhausted", err: fmt.Errorf("ephemeral: %w", gpu.ErrEphemeralExhausted), want: reasonFallbackExhausted},
+		{name: "transport", err: errors.New("connection reset"), want: reasonExecTransport},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := collectionReason(tt.err); got != tt.want {
+				t.Errorf("collectionReason() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLabelReason(t *testing.T) {
+	forbidden := apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, "n1", errors.New("rbac"))
+	if got := labelReason(fmt.Errorf("patch: %w", forbidden)); got != reasonLabelForbidden {
+		t.Errorf("labelReason(forbidden) = %q, want %q", got, reasonLabelForbidden)
+	}
+	if got := labelReason(errors.New("timeout")); got != reasonNodeAPI {
+		t.Errorf("labelReason(timeout) = %q, want %q", got, reasonNodeAPI)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		requeues int
+		want     bool
+	}{
+		{name: "transport always retried", err: fail(reasonExecTransport, errors.New("x")), requeues: 100, want: true},
+		{name: "export always retried", err: fail(reasonExportFailure, errors.New("x")), requeues: 100, want: true},
+		{name: "not found never retried", err: fail(reasonCommandNotFound, errors.New("x")), requeues: 0, want: false},
+		{name: "parse never retried", err: fail(reasonParseError, errors.New("x")), requeues: 0, want: false},
+		{name: "forbidden never retried", err: fail(reasonLabelForbidden, errors.New("x")), requeues: 0, want: false},
+		{name: "driver within budget", err: fail(reasonDriverError, errors.New("x")), requeues: 2, want: true},
+		{name: "driver budget exhausted", err: fail(reasonDriverError, errors.New("x")), requeues: 3, want: false},
+		{name: "wrapped", err: fmt.Errorf("outer: %w", fail(reasonNodeAPI, errors.New("x"))), requeues: 5, want: true},
+		{name: "unclassified", err: errors.New("x"), requeues: 0, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := shouldRetry(tt.err, tt.requeues); got != tt.want {
+				t.Errorf("shouldRetry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
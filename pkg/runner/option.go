@@ -5,9 +5,16 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"path"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/mchmarny/gpuid/pkg/blocklist"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/inventory"
+	"github.com/mchmarny/gpuid/pkg/node"
+	"github.com/mchmarny/gpuid/pkg/podresources"
 )
 
 const (
@@ -33,6 +40,45 @@ const (
 	EnvVarLeaseRetry       = "LEASE_RETRY_PERIOD"
 	EnvVarPodName          = "POD_NAME"
 	EnvVarPodNamespace     = "POD_NAMESPACE"
+	EnvVarSharding         = "SHARDING"
+	EnvVarExecTransport    = "EXEC_TRANSPORT"
+	EnvVarExecCommand      = "EXEC_COMMAND"
+	EnvVarExecSearchPaths  = "EXEC_SEARCH_PATHS"
+	EnvVarContainerDetect  = "CONTAINER_AUTO_DETECT"
+	EnvVarEphemeral        = "EPHEMERAL_FALLBACK"
+	EnvVarEphemeralImage   = "EPHEMERAL_IMAGE"
+	EnvVarNodeConditions   = "NODE_CONDITIONS"
+	EnvVarInventoryCond    = "INVENTORY_CHANGED_CONDITION"
+	EnvVarInventoryCRD     = "INVENTORY_CRD"
+	EnvVarInventoryAnnot   = "INVENTORY_ANNOTATION"
+	EnvVarLabelDomain      = "LABEL_DOMAIN"
+	EnvVarChassisKeyTmpl   = "LABEL_CHASSIS_KEY_TEMPLATE"
+	EnvVarGPUKeyTmpl       = "LABEL_GPU_KEY_TEMPLATE"
+	EnvVarGPUValueTmpl     = "LABEL_GPU_VALUE_TEMPLATE"
+	EnvVarLabelMigrate     = "LABEL_MIGRATE_FROM"
+	EnvVarDerivedLabels    = "DERIVED_LABELS"
+	EnvVarHealthTaint      = "HEALTH_TAINT"
+	EnvVarHealthTaintSpec  = "HEALTH_TAINT_SPEC"
+	EnvVarTaintMaxPercent  = "HEALTH_TAINT_MAX_PERCENT"
+	EnvVarBlocklistSource  = "BLOCKLIST_SOURCE"
+	EnvVarBlocklistRefresh = "BLOCKLIST_REFRESH"
+	EnvVarBlocklistCordon  = "BLOCKLIST_CORDON"
+	EnvVarExpectedInv      = "EXPECTED_INVENTORY"
+	EnvVarExpectedRefresh  = "EXPECTED_INVENTORY_REFRESH"
+	EnvVarDuplicateDetect  = "DUPLICATE_SERIAL_DETECTION"
+	EnvVarDuplicateWindow  = "DUPLICATE_SERIAL_WINDOW"
+	EnvVarWorkloadAttrib   = "WORKLOAD_ATTRIBUTION"
+	EnvVarWorkloadResource = "WORKLOAD_GPU_RESOURCE"
+	EnvVarWorkloadAnnot    = "WORKLOAD_ANNOTATIONS"
+	EnvVarNodeRemovals     = "NODE_REMOVAL_RECORDS"
+	EnvVarLabelRepair      = "LABEL_REPAIR"
+	EnvVarStaleLabelTTL    = "STALE_LABEL_TTL"
+	EnvVarLabelOutput      = "LABEL_OUTPUT"
+	EnvVarFeatureNS        = "NODE_FEATURE_NAMESPACE"
+	EnvVarAgentMode        = "AGENT_MODE"
+	EnvVarNodeName         = "NODE_NAME"
+	EnvVarPodResSocket     = "POD_RESOURCES_SOCKET"
+	EnvVarAgentInterval    = "AGENT_INTERVAL"
 
 	// Default values for configuration parameters
 	DefaultExporterType     = ExporterTypeStdout // Simple stdout output for easy debugging
@@ -58,6 +104,100 @@ const (
 	DefaultLeaseDuration = 15 * time.Second
 	DefaultLeaseRenew    = 10 * time.Second
 	DefaultLeaseRetry    = 2 * time.Second
+
+	// Sharding is disabled by default; when enabled it replaces leader election.
+	DefaultSharding = false
+
+	// Try WebSocket exec first and fall back to SPDY for older API servers.
+	DefaultExecTransport = gpu.TransportAuto
+
+	// The collection command runs without a shell so distroless images work. When the
+	// binary is not on PATH each search path is tried; if the configured container
+	// lacks it, the pod's other containers are tried too.
+	DefaultExecCommand     = "nvidia-smi -q -x"
+	DefaultExecSearchPaths = "/usr/bin/nvidia-smi,/usr/local/nvidia/bin/nvidia-smi,/usr/local/bin/nvidia-smi,/run/nvidia/driver/usr/bin/nvidia-smi"
+	DefaultContainerDetect = true
+
+	// Ephemeral container fallback is opt-in: it mutates the device-plugin pod and the
+	// debug container cannot be removed afterwards.
+	DefaultEphemeral      = false
+	DefaultEphemeralImage = "nvcr.io/nvidia/cuda:12.6.3-base-ubuntu24.04"
+
+	// Node conditions need patch access to nodes/status, so they are opt-in.
+	DefaultNodeConditions = false
+	DefaultInventoryCond  = false
+
+	// The GPUInventory CRD must be installed before enabling it.
+	DefaultInventoryCRD = false
+
+	// The inventory annotation duplicates the labels in more detail, so it is opt-in.
+	DefaultInventoryAnnot = false
+
+	// Label keys default to the original gpuid.github.com/gpu-N scheme. Migration is
+	// a second step: once consumers read the new domain, list the old one in
+	// LABEL_MIGRATE_FROM to remove its labels.
+	DefaultLabelDomain    = node.DefaultLabelDomain
+	DefaultChassisKeyTmpl = node.DefaultChassisKeyTemplate
+	DefaultGPUKeyTmpl     = node.DefaultGPUKeyTemplate
+	DefaultGPUValueTmpl   = node.DefaultGPUValueTemplate
+	DefaultLabelMigrate   = ""
+
+	// Derived attribute labels (product, architecture, ...) are opt-in.
+	DefaultDerivedLabels = false
+
+	// Health tainting is a remediation action, so it is opt-in and capped at a
+	// share of the scanned nodes.
+	DefaultHealthTaint     = false
+	DefaultHealthTaintSpec = "gpuid.github.com/gpu-unhealthy=true:NoSchedule"
+	DefaultTaintMaxPercent = 10
+
+	// The serial blocklist is disabled until a source is set; cordoning a node
+	// that carries a blocklisted part is opt-in on top of that.
+	DefaultBlocklistSource  = ""
+	DefaultBlocklistRefresh = 5 * time.Minute
+	DefaultBlocklistCordon  = false
+
+	// Reconciliation against an expected inventory manifest (e.g. a CMDB export)
+	// is disabled until a source is set.
+	DefaultExpectedInv     = ""
+	DefaultExpectedRefresh = 5 * time.Minute
+
+	// Duplicate serial detection is opt-in. Sightings older than the window are
+	// ignored; it should cover the time between scans of a node.
+	DefaultDuplicateDetect = false
+	DefaultDuplicateWindow = 24 * time.Hour
+
+	// Workload attribution is opt-in: it watches every pod in the cluster that
+	// requests the GPU resource and exports a record when it starts and finishes.
+	DefaultWorkloadAttrib   = false
+	DefaultWorkloadResource = "nvidia.com/gpu"
+
+	// Annotating running GPU pods with their serials is opt-in as well.
+	DefaultWorkloadAnnot = false
+
+	// Exporting a removed record per GPU of a deleted node is opt-in, since it
+	// adds a record type to the readings stream.
+	DefaultNodeRemovals = false
+
+	// Re-applying drifted labels is opt-in. Removing the labels of nodes without a
+	// collection pod is off with a zero TTL.
+	DefaultLabelRepair   = false
+	DefaultStaleLabelTTL = time.Duration(0)
+
+	// Label outputs.
+	LabelOutputNode        = "node"
+	LabelOutputNodeFeature = "nodefeature"
+
+	// Labels are patched onto nodes directly unless node-feature-discovery is
+	// asked to apply them from a NodeFeature written to the gpuid namespace.
+	DefaultLabelOutput = LabelOutputNode
+	DefaultFeatureNS   = "gpuid"
+
+	// Agent mode runs gpuid on each GPU node instead of as a controller and reports
+	// exact pod to GPU assignments from the kubelet pod-resources API.
+	DefaultAgentMode     = false
+	DefaultPodResSocket  = podresources.DefaultSocket
+	DefaultAgentInterval = 30 * time.Second
 )
 
 var (
@@ -73,6 +213,30 @@ var (
 	ErrInvalidResync     = fmt.Errorf("resync period must be >= 0 (0 disables periodic resync)")
 	ErrInvalidServerPort = fmt.Errorf("server port must be a valid integer between 1000 and 65535")
 	ErrInvalidLease      = fmt.Errorf("lease duration > renew deadline > retry period must hold")
+	ErrShardingAndLeader = fmt.Errorf("sharding and leader election are mutually exclusive")
+	ErrTaintAndSharding  = fmt.Errorf("health taint and sharding are mutually exclusive: the max taint percentage is enforced per replica")
+	ErrDupAndSharding    = fmt.Errorf("duplicate serial detection and sharding are mutually exclusive: the serial index is per replica")
+	ErrInvalidTransport  = fmt.Errorf("exec transport must be one of: auto, websocket, spdy")
+	ErrNoExecCommand     = fmt.Errorf("exec command must be specified")
+	ErrInvalidSearchPath = fmt.Errorf("exec search paths must be absolute")
+	ErrNoEphemeralImage  = fmt.Errorf("ephemeral image must be specified when ephemeral fallback is enabled")
+	ErrInvalidLabelFmt   = fmt.Errorf("invalid label format")
+	ErrInvalidTaint      = fmt.Errorf("invalid health taint")
+	ErrInvalidTaintPct   = fmt.Errorf("health taint max percent must be between 1 and 100")
+	ErrInvalidBlocklist  = fmt.Errorf("invalid blocklist source")
+	ErrInvalidBLRefresh  = fmt.Errorf("blocklist refresh period must be > 0")
+	ErrInvalidExpected   = fmt.Errorf("invalid expected inventory source")
+	ErrInvalidExpRefresh = fmt.Errorf("expected inventory refresh period must be > 0")
+	ErrInvalidDupWindow  = fmt.Errorf("duplicate serial window must be > 0")
+	ErrNoGPUResource     = fmt.Errorf("workload GPU resource must be specified when workload attribution or annotations are enabled")
+	ErrInvalidStaleTTL   = fmt.Errorf("stale label TTL must be >= 0")
+	ErrInvalidLabelOut   = fmt.Errorf("label output must be one of: node, nodefeature")
+	ErrNoFeatureNS       = fmt.Errorf("node feature namespace must be specified with nodefeature label output")
+	ErrNeedsNodeLabels   = fmt.Errorf("inventory annotation, label repair and stale label TTL require node label output")
+	ErrNoNodeName        = fmt.Errorf("node name must be specified in agent mode")
+	ErrNoPodResSocket    = fmt.Errorf("pod-resources socket must be specified in agent mode")
+	ErrInvalidAgentInt   = fmt.Errorf("agent interval must be > 0")
+	ErrAgentExclusive    = fmt.Errorf("agent mode can't be combined with leader election or sharding")
 )
 
 // Command encapsulates all configuration for the pod execution controller.
@@ -92,6 +256,44 @@ type Command struct {
 	Kubeconfig       string        // Path to kubeconfig file
 	LogLevel         string        // Logging verbosity level
 	ServerPort       int           // Port for metrics and health server
+	ExecTransport    string        // Pod exec transport (auto, websocket, spdy)
+	ExecCommand      []string      // Collection argv, executed without a shell (empty = nvidia-smi -q -x)
+	ExecSearchPaths  []string      // Alternate absolute paths for the collection binary
+	ContainerDetect  bool          // Try the pod's other containers when Container lacks the binary
+	Ephemeral        bool          // Fall back to an ephemeral debug container when no container has the binary
+	EphemeralImage   string        // Image for the ephemeral debug container
+	NodeConditions   bool          // Maintain the GPUIdentityVerified node condition
+	InventoryCond    bool          // Maintain the GPUInventoryChanged node condition
+	InventoryCRD     bool          // Write a GPUInventory resource per node
+	InventoryAnnot   bool          // Maintain the gpuid.github.com/inventory JSON node annotation
+	LabelDomain      string        // Label key prefix (empty = gpuid.github.com)
+	ChassisKeyTmpl   string        // Go template for the chassis label name
+	GPUKeyTmpl       string        // Go template for the GPU label names
+	GPUValueTmpl     string        // Go template for the GPU label values
+	LabelMigrate     []string      // Previous label domains whose labels are removed
+	DerivedLabels    bool          // Add product, architecture, GPU count, driver and MIG mode labels
+	HealthTaint      bool          // Taint nodes with unhealthy GPUs
+	HealthTaintSpec  string        // Health taint as key[=value]:Effect
+	TaintMaxPercent  int           // Max percentage of scanned nodes carrying the health taint
+	BlocklistSource  string        // Serial blocklist file, configmap://ns/name or URL (empty = disabled)
+	BlocklistRefresh time.Duration // Blocklist reload period
+	BlocklistCordon  bool          // Cordon nodes with blocklisted serials
+	ExpectedInv      string        // Expected inventory manifest file, configmap://ns/name or URL (empty = disabled)
+	ExpectedRefresh  time.Duration // Expected inventory manifest reload period
+	DuplicateDetect  bool          // Warn when one GPU serial is reported by two live nodes
+	DuplicateWindow  time.Duration // Max age of a sighting counted as a duplicate
+	WorkloadAttrib   bool          // Export GPU-to-workload attribution records
+	WorkloadResource string        // Extended resource that marks a pod as a GPU workload
+	WorkloadAnnot    bool          // Annotate running GPU pods with their GPU serials
+	NodeRemovals     bool          // Export a removed record per GPU of a deleted node
+	LabelRepair      bool          // Re-apply GPU labels that drift from the last scan
+	StaleLabelTTL    time.Duration // Remove GPU labels of nodes without a collection pod for this long (0 = disabled)
+	LabelOutput      string        // Where GPU labels are written (node, nodefeature)
+	FeatureNamespace string        // Namespace of the NodeFeature resources
+	AgentMode        bool          // Run on a GPU node and export exact pod to GPU assignments
+	NodeName         string        // Node the agent runs on (from the downward API)
+	PodResSocket     string        // Kubelet pod-resources socket path
+	AgentInterval    time.Duration // How often the agent lists pod resources
 
 	// Leader election configuration. When LeaderElection is true, only the holder
 	// of the LeaseNamespace/LeaseName lease runs the reconciliation workers; the
@@ -105,7 +307,14 @@ type Command struct {
 	PodName        string // Identity for the lease (from downward API)
 	PodNamespace   string // Namespace housing the lease
 
-	exporter *Exporter
+	// Sharding splits nodes across all replicas via consistent hashing over the
+	// live member leases (<LeaseName>-<PodName> in LeaseNamespace). It reuses the
+	// lease duration and retry period for member heartbeats.
+	Sharding bool
+
+	exporter    *Exporter
+	labelFormat *node.LabelFormat
+	expected    *inventory.Reconciler
 }
 
 func (c *Command) Init(ctx context.Context, log *slog.Logger) error {
@@ -113,14 +322,55 @@ func (c *Command) Init(ctx context.Context, log *slog.Logger) error {
 		return ErrInvalidExporter
 	}
 
-	exp, err := GetExporter(ctx, log, ExporterConfig{Type: c.ExporterType})
+	exp, err := GetExporter(ctx, log, ExporterConfig{Type: c.ExporterType, Attribution: c.WorkloadAttrib, Removals: c.NodeRemovals})
 	if err != nil {
 		return fmt.Errorf("failed to get exporter: %w", err)
 	}
 	c.exporter = exp
+
+	f, err := node.NewLabelFormat(c.labelFormatConfig())
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLabelFmt, err)
+	}
+	c.labelFormat = f
 	return nil
 }
 
+// execOptions returns the collection settings passed to the gpu package.
+func (c *Command) execOptions() gpu.ExecOptions {
+	opts := gpu.ExecOptions{
+		Container:   c.Container,
+		AutoDetect:  c.ContainerDetect,
+		Command:     c.ExecCommand,
+		SearchPaths: c.ExecSearchPaths,
+		Transport:   c.ExecTransport,
+	}
+	if c.Ephemeral {
+		opts.EphemeralImage = c.EphemeralImage
+	}
+	return opts
+}
+
+// labelOptions returns the node metadata settings passed to the node package.
+func (c *Command) labelOptions() node.LabelOptions {
+	return node.LabelOptions{
+		Format:    c.labelFormat,
+		Inventory: c.InventoryAnnot,
+		Derived:   c.DerivedLabels,
+	}
+}
+
+// labelFormatConfig returns the unparsed label format settings.
+func (c *Command) labelFormatConfig() node.LabelFormatConfig {
+	return node.LabelFormatConfig{
+		Domain:      c.LabelDomain,
+		ChassisKey:  c.ChassisKeyTmpl,
+		GPUKey:      c.GPUKeyTmpl,
+		GPUValue:    c.GPUValueTmpl,
+		MigrateFrom: c.LabelMigrate,
+	}
+}
+
 // Validate performs comprehensive validation of the command configuration.
 // This validation is crucial in distributed systems where invalid config
 // can cause cascading failures or resource exhaustion.
@@ -185,6 +435,125 @@ func (c *Command) Validate() error {
 		return ErrInvalidServerPort
 	}
 
+	if !gpu.ValidTransport(c.ExecTransport) {
+		return fmt.Errorf("%w: got %q", ErrInvalidTransport, c.ExecTransport)
+	}
+
+	if len(c.ExecCommand) > 0 && strings.TrimSpace(c.ExecCommand[0]) == "" {
+		return ErrNoExecCommand
+	}
+
+	for _, p := range c.ExecSearchPaths {
+		if !path.IsAbs(p) {
+			return fmt.Errorf("%w: got %q", ErrInvalidSearchPath, p)
+		}
+	}
+
+	if c.Ephemeral && strings.TrimSpace(c.EphemeralImage) == "" {
+		return ErrNoEphemeralImage
+	}
+
+	if _, err := node.NewLabelFormat(c.labelFormatConfig()); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidLabelFmt, err)
+	}
+
+	if c.HealthTaint {
+		if _, err := node.ParseTaint(c.HealthTaintSpec); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidTaint, err)
+		}
+		if c.TaintMaxPercent < 1 || c.TaintMaxPercent > 100 {
+			return fmt.Errorf("%w: got %d", ErrInvalidTaintPct, c.TaintMaxPercent)
+		}
+	}
+
+	if c.BlocklistSource != "" {
+		if _, err := blocklist.New(c.BlocklistSource, nil); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidBlocklist, err)
+		}
+		if c.BlocklistRefresh <= 0 {
+			return fmt.Errorf("%w: got %v", ErrInvalidBLRefresh, c.BlocklistRefresh)
+		}
+	}
+
+	if c.ExpectedInv != "" {
+		if _, err := inventory.NewReconciler(c.ExpectedInv, nil); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidExpected, err)
+		}
+		if c.ExpectedRefresh <= 0 {
+			return fmt.Errorf("%w: got %v", ErrInvalidExpRefresh, c.ExpectedRefresh)
+		}
+	}
+
+	if c.DuplicateDetect && c.DuplicateWindow <= 0 {
+		return fmt.Errorf("%w: got %v", ErrInvalidDupWindow, c.DuplicateWindow)
+	}
+
+	if c.StaleLabelTTL < 0 {
+		return fmt.Errorf("%w: got %v", ErrInvalidStaleTTL, c.StaleLabelTTL)
+	}
+
+	switch c.LabelOutput {
+	case "", LabelOutputNode:
+	case LabelOutputNodeFeature:
+		if strings.TrimSpace(c.FeatureNamespace) == "" {
+			return ErrNoFeatureNS
+		}
+		if c.InventoryAnnot || c.LabelRepair || c.StaleLabelTTL > 0 {
+			return ErrNeedsNodeLabels
+		}
+	default:
+		return fmt.Errorf("%w: got %q", ErrInvalidLabelOut, c.LabelOutput)
+	}
+
+	if (c.WorkloadAttrib || c.WorkloadAnnot) && strings.TrimSpace(c.WorkloadResource) == "" {
+		return ErrNoGPUResource
+	}
+
+	if c.LeaderElection && c.Sharding {
+		return ErrShardingAndLeader
+	}
+
+	if c.HealthTaint && c.Sharding {
+		return ErrTaintAndSharding
+	}
+
+	if c.DuplicateDetect && c.Sharding {
+		return ErrDupAndSharding
+	}
+
+	if c.AgentMode {
+		if c.LeaderElection || c.Sharding {
+			return ErrAgentExclusive
+		}
+		if strings.TrimSpace(c.NodeName) == "" {
+			return ErrNoNodeName
+		}
+		if strings.TrimSpace(c.PodResSocket) == "" {
+			return ErrNoPodResSocket
+		}
+		if c.AgentInterval <= 0 {
+			return fmt.Errorf("%w: got %v", ErrInvalidAgentInt, c.AgentInterval)
+		}
+		if strings.TrimSpace(c.WorkloadResource) == "" {
+			return ErrNoGPUResource
+		}
+	}
+
+	if c.Sharding {
+		if strings.TrimSpace(c.LeaseName) == "" {
+			return fmt.Errorf("lease name must be specified when sharding is enabled")
+		}
+		if strings.TrimSpace(c.LeaseNamespace) == "" {
+			return fmt.Errorf("lease namespace must be specified when sharding is enabled")
+		}
+		if strings.TrimSpace(c.PodName) == "" {
+			return fmt.Errorf("%s must be set (downward API) when sharding is enabled", EnvVarPodName)
+		}
+		if c.LeaseDuration <= c.LeaseRetry || c.LeaseRetry <= 0 {
+			return fmt.Errorf("%w: got lease=%v retry=%v", ErrInvalidLease, c.LeaseDuration, c.LeaseRetry)
+		}
+	}
+
 	if c.LeaderElection {
 		if strings.TrimSpace(c.LeaseName) == "" {
 			return fmt.Errorf("lease name must be specified when leader election is enabled")
@@ -323,6 +692,230 @@ func WithPodNamespace(ns string) Option {
 	}
 }
 
+func WithSharding(enabled bool) Option {
+	return func(c *Command) {
+		c.Sharding = enabled
+	}
+}
+
+func WithExecTransport(transport string) Option {
+	return func(c *Command) {
+		c.ExecTransport = transport
+	}
+}
+
+func WithExecCommand(argv ...string) Option {
+	return func(c *Command) {
+		c.ExecCommand = argv
+	}
+}
+
+func WithExecSearchPaths(paths ...string) Option {
+	return func(c *Command) {
+		c.ExecSearchPaths = paths
+	}
+}
+
+func WithContainerDetect(enabled bool) Option {
+	return func(c *Command) {
+		c.ContainerDetect = enabled
+	}
+}
+
+func WithEphemeral(enabled bool) Option {
+	return func(c *Command) {
+		c.Ephemeral = enabled
+	}
+}
+
+func WithEphemeralImage(image string) Option {
+	return func(c *Command) {
+		c.EphemeralImage = image
+	}
+}
+
+func WithNodeConditions(enabled bool) Option {
+	return func(c *Command) {
+		c.NodeConditions = enabled
+	}
+}
+
+func WithInventoryCondition(enabled bool) Option {
+	return func(c *Command) {
+		c.InventoryCond = enabled
+	}
+}
+
+func WithInventoryCRD(enabled bool) Option {
+	return func(c *Command) {
+		c.InventoryCRD = enabled
+	}
+}
+
+func WithInventoryAnnotation(enabled bool) Option {
+	return func(c *Command) {
+		c.InventoryAnnot = enabled
+	}
+}
+
+func WithLabelDomain(domain string) Option {
+	return func(c *Command) {
+		c.LabelDomain = domain
+	}
+}
+
+func WithLabelTemplates(chassisKey, gpuKey, gpuValue string) Option {
+	return func(c *Command) {
+		c.ChassisKeyTmpl = chassisKey
+		c.GPUKeyTmpl = gpuKey
+		c.GPUValueTmpl = gpuValue
+	}
+}
+
+func WithLabelMigrateFrom(domains ...string) Option {
+	return func(c *Command) {
+		c.LabelMigrate = domains
+	}
+}
+
+func WithDerivedLabels(enabled bool) Option {
+	return func(c *Command) {
+		c.DerivedLabels = enabled
+	}
+}
+
+func WithHealthTaint(enabled bool) Option {
+	return func(c *Command) {
+		c.HealthTaint = enabled
+	}
+}
+
+func WithHealthTaintSpec(spec string) Option {
+	return func(c *Command) {
+		c.HealthTaintSpec = spec
+	}
+}
+
+func WithTaintMaxPercent(percent int) Option {
+	return func(c *Command) {
+		c.TaintMaxPercent = percent
+	}
+}
+
+func WithBlocklistSource(source string) Option {
+	return func(c *Command) {
+		c.BlocklistSource = source
+	}
+}
+
+func WithBlocklistRefresh(d time.Duration) Option {
+	return func(c *Command) {
+		c.BlocklistRefresh = d
+	}
+}
+
+func WithBlocklistCordon(enabled bool) Option {
+	return func(c *Command) {
+		c.BlocklistCordon = enabled
+	}
+}
+
+func WithExpectedInventory(source string) Option {
+	return func(c *Command) {
+		c.ExpectedInv = source
+	}
+}
+
+func WithExpectedRefresh(d time.Duration) Option {
+	return func(c *Command) {
+		c.ExpectedRefresh = d
+	}
+}
+
+func WithDuplicateDetection(enabled bool) Option {
+	return func(c *Command) {
+		c.DuplicateDetect = enabled
+	}
+}
+
+func WithDuplicateWindow(d time.Duration) Option {
+	return func(c *Command) {
+		c.DuplicateWindow = d
+	}
+}
+
+func WithWorkloadAttribution(enabled bool) Option {
+	return func(c *Command) {
+		c.WorkloadAttrib = enabled
+	}
+}
+
+func WithWorkloadResource(resource string) Option {
+	return func(c *Command) {
+		c.WorkloadResource = resource
+	}
+}
+
+func WithWorkloadAnnotations(enabled bool) Option {
+	return func(c *Command) {
+		c.WorkloadAnnot = enabled
+	}
+}
+
+func WithNodeRemovals(enabled bool) Option {
+	return func(c *Command) {
+		c.NodeRemovals = enabled
+	}
+}
+
+func WithLabelRepair(enabled bool) Option {
+	return func(c *Command) {
+		c.LabelRepair = enabled
+	}
+}
+
+func WithStaleLabelTTL(ttl time.Duration) Option {
+	return func(c *Command) {
+		c.StaleLabelTTL = ttl
+	}
+}
+
+func WithLabelOutput(output string) Option {
+	return func(c *Command) {
+		c.LabelOutput = output
+	}
+}
+
+func WithFeatureNamespace(namespace string) Option {
+	return func(c *Command) {
+		c.FeatureNamespace = namespace
+	}
+}
+
+func WithAgentMode(enabled bool) Option {
+	return func(c *Command) {
+		c.AgentMode = enabled
+	}
+}
+
+func WithNodeName(name string) Option {
+	return func(c *Command) {
+		c.NodeName = name
+	}
+}
+
+func WithPodResourcesSocket(path string) Option {
+	return func(c *Command) {
+		c.PodResSocket = path
+	}
+}
+
+func WithAgentInterval(d time.Duration) Option {
+	return func(c *Command) {
+		c.AgentInterval = d
+	}
+}
+
 // NewCommand creates a Command with production-ready defaults.
 // The defaults are chosen based on common Kubernetes controller patterns
 // and have been battle-tested in high-throughput environments.
@@ -347,6 +940,44 @@ func NewCommand(opts ...Option) *Command {
 		LeaseDuration:    DefaultLeaseDuration,
 		LeaseRenew:       DefaultLeaseRenew,
 		LeaseRetry:       DefaultLeaseRetry,
+		Sharding:         DefaultSharding,
+		ExecTransport:    DefaultExecTransport,
+		ExecCommand:      strings.Fields(DefaultExecCommand),
+		ExecSearchPaths:  splitList(DefaultExecSearchPaths),
+		ContainerDetect:  DefaultContainerDetect,
+		Ephemeral:        DefaultEphemeral,
+		EphemeralImage:   DefaultEphemeralImage,
+		NodeConditions:   DefaultNodeConditions,
+		InventoryCond:    DefaultInventoryCond,
+		InventoryCRD:     DefaultInventoryCRD,
+		InventoryAnnot:   DefaultInventoryAnnot,
+		LabelDomain:      DefaultLabelDomain,
+		ChassisKeyTmpl:   DefaultChassisKeyTmpl,
+		GPUKeyTmpl:       DefaultGPUKeyTmpl,
+		GPUValueTmpl:     DefaultGPUValueTmpl,
+		LabelMigrate:     splitList(DefaultLabelMigrate),
+		DerivedLabels:    DefaultDerivedLabels,
+		HealthTaint:      DefaultHealthTaint,
+		HealthTaintSpec:  DefaultHealthTaintSpec,
+		TaintMaxPercent:  DefaultTaintMaxPercent,
+		BlocklistSource:  DefaultBlocklistSource,
+		BlocklistRefresh: DefaultBlocklistRefresh,
+		BlocklistCordon:  DefaultBlocklistCordon,
+		ExpectedInv:      DefaultExpectedInv,
+		ExpectedRefresh:  DefaultExpectedRefresh,
+		DuplicateDetect:  DefaultDuplicateDetect,
+		DuplicateWindow:  DefaultDuplicateWindow,
+		WorkloadAttrib:   DefaultWorkloadAttrib,
+		WorkloadResource: DefaultWorkloadResource,
+		WorkloadAnnot:    DefaultWorkloadAnnot,
+		NodeRemovals:     DefaultNodeRemovals,
+		LabelRepair:      DefaultLabelRepair,
+		StaleLabelTTL:    DefaultStaleLabelTTL,
+		LabelOutput:      DefaultLabelOutput,
+		FeatureNamespace: DefaultFeatureNS,
+		AgentMode:        DefaultAgentMode,
+		PodResSocket:     DefaultPodResSocket,
+		AgentInterval:    DefaultAgentInterval,
 	}
 
 	// Apply all options in order - this pattern allows for composable configuration
@@ -380,6 +1011,45 @@ func ListEnvVars() []string {
 		EnvVarLeaseRetry,
 		EnvVarPodName,
 		EnvVarPodNamespace,
+		EnvVarSharding,
+		EnvVarExecTransport,
+		EnvVarExecCommand,
+		EnvVarExecSearchPaths,
+		EnvVarContainerDetect,
+		EnvVarEphemeral,
+		EnvVarEphemeralImage,
+		EnvVarNodeConditions,
+		EnvVarInventoryCond,
+		EnvVarInventoryCRD,
+		EnvVarInventoryAnnot,
+		EnvVarLabelDomain,
+		EnvVarChassisKeyTmpl,
+		EnvVarGPUKeyTmpl,
+		EnvVarGPUValueTmpl,
+		EnvVarLabelMigrate,
+		EnvVarDerivedLabels,
+		EnvVarHealthTaint,
+		EnvVarHealthTaintSpec,
+		EnvVarTaintMaxPercent,
+		EnvVarBlocklistSource,
+		EnvVarBlocklistRefresh,
+		EnvVarBlocklistCordon,
+		EnvVarExpectedInv,
+		EnvVarExpectedRefresh,
+		EnvVarDuplicateDetect,
+		EnvVarDuplicateWindow,
+		EnvVarWorkloadAttrib,
+		EnvVarWorkloadResource,
+		EnvVarWorkloadAnnot,
+		EnvVarNodeRemovals,
+		EnvVarLabelRepair,
+		EnvVarStaleLabelTTL,
+		EnvVarLabelOutput,
+		EnvVarFeatureNS,
+		EnvVarAgentMode,
+		EnvVarNodeName,
+		EnvVarPodResSocket,
+		EnvVarAgentInterval,
 	}
 }
 
@@ -427,6 +1097,94 @@ func NewCommandFromEnvVars() (*Command, error) {
 	if err != nil {
 		return nil, err
 	}
+	sharding, err := getEnvAsBool(EnvVarSharding, DefaultSharding)
+	if err != nil {
+		return nil, err
+	}
+	containerDetect, err := getEnvAsBool(EnvVarContainerDetect, DefaultContainerDetect)
+	if err != nil {
+		return nil, err
+	}
+	ephemeral, err := getEnvAsBool(EnvVarEphemeral, DefaultEphemeral)
+	if err != nil {
+		return nil, err
+	}
+	nodeConditions, err := getEnvAsBool(EnvVarNodeConditions, DefaultNodeConditions)
+	if err != nil {
+		return nil, err
+	}
+	inventoryCond, err := getEnvAsBool(EnvVarInventoryCond, DefaultInventoryCond)
+	if err != nil {
+		return nil, err
+	}
+	inventoryCRD, err := getEnvAsBool(EnvVarInventoryCRD, DefaultInventoryCRD)
+	if err != nil {
+		return nil, err
+	}
+	inventoryAnnot, err := getEnvAsBool(EnvVarInventoryAnnot, DefaultInventoryAnnot)
+	if err != nil {
+		return nil, err
+	}
+	derivedLabels, err := getEnvAsBool(EnvVarDerivedLabels, DefaultDerivedLabels)
+	if err != nil {
+		return nil, err
+	}
+	healthTaint, err := getEnvAsBool(EnvVarHealthTaint, DefaultHealthTaint)
+	if err != nil {
+		return nil, err
+	}
+	taintMaxPercent, err := getEnvAsInt(EnvVarTaintMaxPercent, DefaultTaintMaxPercent)
+	if err != nil {
+		return nil, err
+	}
+	blocklistRefresh, err := getEnvAsDuration(EnvVarBlocklistRefresh, DefaultBlocklistRefresh)
+	if err != nil {
+		return nil, err
+	}
+	blocklistCordon, err := getEnvAsBool(EnvVarBlocklistCordon, DefaultBlocklistCordon)
+	if err != nil {
+		return nil, err
+	}
+	expectedRefresh, err := getEnvAsDuration(EnvVarExpectedRefresh, DefaultExpectedRefresh)
+	if err != nil {
+		return nil, err
+	}
+	duplicateDetect, err := getEnvAsBool(EnvVarDuplicateDetect, DefaultDuplicateDetect)
+	if err != nil {
+		return nil, err
+	}
+	duplicateWindow, err := getEnvAsDuration(EnvVarDuplicateWindow, DefaultDuplicateWindow)
+	if err != nil {
+		return nil, err
+	}
+	workloadAttrib, err := getEnvAsBool(EnvVarWorkloadAttrib, DefaultWorkloadAttrib)
+	if err != nil {
+		return nil, err
+	}
+	workloadAnnot, err := getEnvAsBool(EnvVarWorkloadAnnot, DefaultWorkloadAnnot)
+	if err != nil {
+		return nil, err
+	}
+	nodeRemovals, err := getEnvAsBool(EnvVarNodeRemovals, DefaultNodeRemovals)
+	if err != nil {
+		return nil, err
+	}
+	labelRepair, err := getEnvAsBool(EnvVarLabelRepair, DefaultLabelRepair)
+	if err != nil {
+		return nil, err
+	}
+	staleLabelTTL, err := getEnvAsDuration(EnvVarStaleLabelTTL, DefaultStaleLabelTTL)
+	if err != nil {
+		return nil, err
+	}
+	agentMode, err := getEnvAsBool(EnvVarAgentMode, DefaultAgentMode)
+	if err != nil {
+		return nil, err
+	}
+	agentInterval, err := getEnvAsDuration(EnvVarAgentInterval, DefaultAgentInterval)
+	if err != nil {
+		return nil, err
+	}
 	return NewCommand(
 		WithExporterType(getEnv(EnvVarExporterType, DefaultExporterType)),
 		WithClusterName(getEnv(EnvVarClusterName, DefaultClusterName)),
@@ -449,6 +1207,47 @@ func NewCommandFromEnvVars() (*Command, error) {
 		WithLeaseRetry(leaseRetry),
 		WithPodName(getEnv(EnvVarPodName, "")),
 		WithPodNamespace(getEnv(EnvVarPodNamespace, "")),
+		WithSharding(sharding),
+		WithExecTransport(getEnv(EnvVarExecTransport, DefaultExecTransport)),
+		WithExecCommand(strings.Fields(getEnv(EnvVarExecCommand, DefaultExecCommand))...),
+		WithExecSearchPaths(splitList(getEnv(EnvVarExecSearchPaths, DefaultExecSearchPaths))...),
+		WithContainerDetect(containerDetect),
+		WithEphemeral(ephemeral),
+		WithEphemeralImage(getEnv(EnvVarEphemeralImage, DefaultEphemeralImage)),
+		WithNodeConditions(nodeConditions),
+		WithInventoryCondition(inventoryCond),
+		WithInventoryCRD(inventoryCRD),
+		WithInventoryAnnotation(inventoryAnnot),
+		WithLabelDomain(getEnv(EnvVarLabelDomain, DefaultLabelDomain)),
+		WithLabelTemplates(
+			getEnv(EnvVarChassisKeyTmpl, DefaultChassisKeyTmpl),
+			getEnv(EnvVarGPUKeyTmpl, DefaultGPUKeyTmpl),
+			getEnv(EnvVarGPUValueTmpl, DefaultGPUValueTmpl),
+		),
+		WithLabelMigrateFrom(splitList(getEnv(EnvVarLabelMigrate, DefaultLabelMigrate))...),
+		WithDerivedLabels(derivedLabels),
+		WithHealthTaint(healthTaint),
+		WithHealthTaintSpec(getEnv(EnvVarHealthTaintSpec, DefaultHealthTaintSpec)),
+		WithTaintMaxPercent(taintMaxPercent),
+		WithBlocklistSource(getEnv(EnvVarBlocklistSource, DefaultBlocklistSource)),
+		WithBlocklistRefresh(blocklistRefresh),
+		WithBlocklistCordon(blocklistCordon),
+		WithExpectedInventory(getEnv(EnvVarExpectedInv, DefaultExpectedInv)),
+		WithExpectedRefresh(expectedRefresh),
+		WithDuplicateDetection(duplicateDetect),
+		WithDuplicateWindow(duplicateWindow),
+		WithWorkloadAttribution(workloadAttrib),
+		WithWorkloadResource(getEnv(EnvVarWorkloadResource, DefaultWorkloadResource)),
+		WithWorkloadAnnotations(workloadAnnot),
+		WithNodeRemovals(nodeRemovals),
+		WithLabelRepair(labelRepair),
+		WithStaleLabelTTL(staleLabelTTL),
+		WithLabelOutput(getEnv(EnvVarLabelOutput, DefaultLabelOutput)),
+		WithFeatureNamespace(getEnv(EnvVarFeatureNS, DefaultFeatureNS)),
+		WithAgentMode(agentMode),
+		WithNodeName(getEnv(EnvVarNodeName, "")),
+		WithPodResourcesSocket(getEnv(EnvVarPodResSocket, DefaultPodResSocket)),
+		WithAgentInterval(agentInterval),
 	), nil
 }
 
@@ -464,6 +1263,17 @@ func getEnvAsBool(name string, defaultVal bool) (bool, error) {
 	return val, nil
 }
 
+// splitList parses a comma-separated list, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
 func LookupEnv(name string) (string, bool) {
 	return os.LookupEnv(name)
 }
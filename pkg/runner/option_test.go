@@ -108,6 +108,265 @@ func TestOptionFunctions(t *testing.T) {
 				return c.ServerPort == 9090
 			},
 		},
+		{
+			name:   "WithSharding",
+			option: WithSharding(true),
+			expected: func(c *Command) bool {
+				return c.Sharding
+			},
+		},
+		{
+			name:   "WithExecTransport",
+			option: WithExecTransport("spdy"),
+			expected: func(c *Command) bool {
+				return c.ExecTransport == "spdy"
+			},
+		},
+		{
+			name:   "WithExecCommand",
+			option: WithExecCommand("/usr/bin/nvidia-smi", "-q", "-x"),
+			expected: func(c *Command) bool {
+				return len(c.ExecCommand) == 3 && c.ExecCommand[0] == "/usr/bin/nvidia-smi"
+			},
+		},
+		{
+			name:   "WithExecSearchPaths",
+			option: WithExecSearchPaths("/usr/local/nvidia/bin/nvidia-smi"),
+			expected: func(c *Command) bool {
+				return len(c.ExecSearchPaths) == 1 && c.ExecSearchPaths[0] == "/usr/local/nvidia/bin/nvidia-smi"
+			},
+		},
+		{
+			name:   "WithContainerDetect",
+			option: WithContainerDetect(true),
+			expected: func(c *Command) bool {
+				return c.ContainerDetect
+			},
+		},
+		{
+			name:   "WithEphemeral",
+			option: WithEphemeral(true),
+			expected: func(c *Command) bool {
+				return c.Ephemeral
+			},
+		},
+		{
+			name:   "WithEphemeralImage",
+			option: WithEphemeralImage("nvidia/cuda:base"),
+			expected: func(c *Command) bool {
+				return c.EphemeralImage == "nvidia/cuda:base"
+			},
+		},
+		{
+			name:   "WithNodeConditions",
+			option: WithNodeConditions(true),
+			expected: func(c *Command) bool {
+				return c.NodeConditions
+			},
+		},
+		{
+			name:   "WithInventoryCondition",
+			option: WithInventoryCondition(true),
+			expected: func(c *Command) bool {
+				return c.InventoryCond
+			},
+		},
+		{
+			name:   "WithInventoryCRD",
+			option: WithInventoryCRD(true),
+			expected: func(c *Command) bool {
+				return c.InventoryCRD
+			},
+		},
+		{
+			name:   "WithInventoryAnnotation",
+			option: WithInventoryAnnotation(true),
+			expected: func(c *Command) bool {
+				return c.InventoryAnnot
+			},
+		},
+		{
+			name:   "WithLabelDomain",
+			option: WithLabelDomain("hw.ourco.io"),
+			expected: func(c *Command) bool {
+				return c.LabelDomain == "hw.ourco.io"
+			},
+		},
+		{
+			name:   "WithLabelTemplates",
+			option: WithLabelTemplates("host", "gpu-{{.UUID}}", "{{.Serial}}"),
+			expected: func(c *Command) bool {
+				return c.ChassisKeyTmpl == "host" && c.GPUKeyTmpl == "gpu-{{.UUID}}" && c.GPUValueTmpl == "{{.Serial}}"
+			},
+		},
+		{
+			name:   "WithLabelMigrateFrom",
+			option: WithLabelMigrateFrom("gpuid.github.com"),
+			expected: func(c *Command) bool {
+				return len(c.LabelMigrate) == 1 && c.LabelMigrate[0] == "gpuid.github.com"
+			},
+		},
+		{
+			name:   "WithDerivedLabels",
+			option: WithDerivedLabels(true),
+			expected: func(c *Command) bool {
+				return c.DerivedLabels
+			},
+		},
+		{
+			name:   "WithHealthTaint",
+			option: WithHealthTaint(true),
+			expected: func(c *Command) bool {
+				return c.HealthTaint
+			},
+		},
+		{
+			name:   "WithHealthTaintSpec",
+			option: WithHealthTaintSpec("gpu-bad:NoExecute"),
+			expected: func(c *Command) bool {
+				return c.HealthTaintSpec == "gpu-bad:NoExecute"
+			},
+		},
+		{
+			name:   "WithTaintMaxPercent",
+			option: WithTaintMaxPercent(25),
+			expected: func(c *Command) bool {
+				return c.TaintMaxPercent == 25
+			},
+		},
+		{
+			name:   "WithBlocklistSource",
+			option: WithBlocklistSource("configmap://gpuid/blocklist"),
+			expected: func(c *Command) bool {
+				return c.BlocklistSource == "configmap://gpuid/blocklist"
+			},
+		},
+		{
+			name:   "WithBlocklistRefresh",
+			option: WithBlocklistRefresh(time.Minute),
+			expected: func(c *Command) bool {
+				return c.BlocklistRefresh == time.Minute
+			},
+		},
+		{
+			name:   "WithBlocklistCordon",
+			option: WithBlocklistCordon(true),
+			expected: func(c *Command) bool {
+				return c.BlocklistCordon
+			},
+		},
+		{
+			name:   "WithExpectedInventory",
+			option: WithExpectedInventory("https://cmdb.example.com/gpus.csv"),
+			expected: func(c *Command) bool {
+				return c.ExpectedInv == "https://cmdb.example.com/gpus.csv"
+			},
+		},
+		{
+			name:   "WithExpectedRefresh",
+			option: WithExpectedRefresh(time.Hour),
+			expected: func(c *Command) bool {
+				return c.ExpectedRefresh == time.Hour
+			},
+		},
+		{
+			name:   "WithDuplicateDetection",
+			option: WithDuplicateDetection(true),
+			expected: func(c *Command) bool {
+				return c.DuplicateDetect
+			},
+		},
+		{
+			name:   "WithDuplicateWindow",
+			option: WithDuplicateWindow(time.Hour),
+			expected: func(c *Command) bool {
+				return c.DuplicateWindow == time.Hour
+			},
+		},
+		{
+			name:   "WithWorkloadAttribution",
+			option: WithWorkloadAttribution(true),
+			expected: func(c *Command) bool {
+				return c.WorkloadAttrib
+			},
+		},
+		{
+			name:   "WithWorkloadResource",
+			option: WithWorkloadResource("amd.com/gpu"),
+			expected: func(c *Command) bool {
+				return c.WorkloadResource == "amd.com/gpu"
+			},
+		},
+		{
+			name:   "WithWorkloadAnnotations",
+			option: WithWorkloadAnnotations(true),
+			expected: func(c *Command) bool {
+				return c.WorkloadAnnot
+			},
+		},
+		{
+			name:   "WithNodeRemovals",
+			option: WithNodeRemovals(true),
+			expected: func(c *Command) bool {
+				return c.NodeRemovals
+			},
+		},
+		{
+			name:   "WithLabelRepair",
+			option: WithLabelRepair(true),
+			expected: func(c *Command) bool {
+				return c.LabelRepair
+			},
+		},
+		{
+			name:   "WithStaleLabelTTL",
+			option: WithStaleLabelTTL(time.Hour),
+			expected: func(c *Command) bool {
+				return c.StaleLabelTTL == time.Hour
+			},
+		},
+		{
+			name:   "WithLabelOutput",
+			option: WithLabelOutput(LabelOutputNodeFeature),
+			expected: func(c *Command) bool {
+				return c.LabelOutput == LabelOutputNodeFeature
+			},
+		},
+		{
+			name:   "WithFeatureNamespace",
+			option: WithFeatureNamespace("node-feature-discovery"),
+			expected: func(c *Command) bool {
+				return c.FeatureNamespace == "node-feature-discovery"
+			},
+		},
+		{
+			name:   "WithAgentMode",
+			option: WithAgentMode(true),
+			expected: func(c *Command) bool {
+				return c.AgentMode
+			},
+		},
+		{
+			name:   "WithNodeName",
+			option: WithNodeName("gpu-node-1"),
+			expected: func(c *Command) bool {
+				return c.NodeName == "gpu-node-1"
+			},
+		},
+		{
+			name:   "WithPodResourcesSocket",
+			option: WithPodResourcesSocket("/tmp/kubelet.sock"),
+			expected: func(c *Command) bool {
+				return c.PodResSocket == "/tmp/kubelet.sock"
+			},
+		},
+		{
+			name:   "WithAgentInterval",
+			option: WithAgentInterval(time.Minute),
+			expected: func(c *Command) bool {
+				return c.AgentInterval == time.Minute
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -161,10 +420,344 @@ func TestCommand_Validate(t *testing.T) {
 		wantErr bool
 	}{
 		{
-			name: "valid command",
+			name: "valid command",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          testClusterName,
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing exporter type",
+			command: &Command{
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+			},
+			wantErr: true,
+		},
+		{
+			name: "missing cluster",
+			command: &Command{
+				ExporterType:     "stdout",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid workers",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          0,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+			},
+			wantErr: true,
+		},
+		{
+			name: "too many workers",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          101,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid timeout",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          0,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+			},
+			wantErr: true,
+		},
+		{
+			name: "sharding with leader election",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				LeaderElection:   true,
+				Sharding:         true,
+				LeaseNamespace:   "gpuid",
+				LeaseName:        "gpuid-leader",
+				LeaseDuration:    15 * time.Second,
+				LeaseRenew:       10 * time.Second,
+				LeaseRetry:       2 * time.Second,
+				PodName:          "gpuid-0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "health taint with sharding",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				HealthTaint:      true,
+				HealthTaintSpec:  "gpuid.github.com/gpu-unhealthy=true:NoSchedule",
+				TaintMaxPercent:  10,
+				Sharding:         true,
+				LeaseNamespace:   "gpuid",
+				LeaseName:        "gpuid-leader",
+				LeaseDuration:    15 * time.Second,
+				LeaseRenew:       10 * time.Second,
+				LeaseRetry:       2 * time.Second,
+				PodName:          "gpuid-0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "duplicate detection with sharding",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				DuplicateDetect:  true,
+				DuplicateWindow:  24 * time.Hour,
+				Sharding:         true,
+				LeaseNamespace:   "gpuid",
+				LeaseName:        "gpuid-leader",
+				LeaseDuration:    15 * time.Second,
+				LeaseRenew:       10 * time.Second,
+				LeaseRetry:       2 * time.Second,
+				PodName:          "gpuid-0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "sharding without pod name",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				Sharding:         true,
+				LeaseNamespace:   "gpuid",
+				LeaseName:        "gpuid-shard",
+				LeaseDuration:    15 * time.Second,
+				LeaseRetry:       2 * time.Second,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid sharding",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				Sharding:         true,
+				LeaseNamespace:   "gpuid",
+				LeaseName:        "gpuid-shard",
+				LeaseDuration:    15 * time.Second,
+				LeaseRetry:       2 * time.Second,
+				PodName:          "gpuid-0",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid port",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       999,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid exec transport",
 			command: &Command{
 				ExporterType:     "stdout",
-				Cluster:          testClusterName,
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				ExecTransport:    "http2",
+			},
+			wantErr: true,
+		},
+		{
+			name: "relative exec search path",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				ExecSearchPaths:  []string{"bin/nvidia-smi"},
+			},
+			wantErr: true,
+		},
+		{
+			name: "ephemeral fallback without image",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				Ephemeral:        true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid label key template",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				GPUKeyTmpl:       "gpu-{{.Slot}}",
+			},
+			wantErr: true,
+		},
+		{
+			name: "health taint without effect",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				HealthTaint:      true,
+				HealthTaintSpec:  "gpu-unhealthy=true",
+				TaintMaxPercent:  10,
+			},
+			wantErr: true,
+		},
+		{
+			name: "health taint max percent out of range",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				HealthTaint:      true,
+				HealthTaintSpec:  DefaultHealthTaintSpec,
+				TaintMaxPercent:  0,
+			},
+			wantErr: true,
+		},
+		{
+			name: "valid blocklist source",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
 				Namespace:        "default",
 				PodLabelSelector: "app=test",
 				Container:        "main",
@@ -173,12 +766,15 @@ func TestCommand_Validate(t *testing.T) {
 				QPS:              20,
 				Burst:            50,
 				ServerPort:       8080,
+				BlocklistSource:  "configmap://gpuid/blocklist",
+				BlocklistRefresh: DefaultBlocklistRefresh,
 			},
 			wantErr: false,
 		},
 		{
-			name: "missing exporter type",
+			name: "invalid blocklist source",
 			command: &Command{
+				ExporterType:     "stdout",
 				Cluster:          "test-cluster",
 				Namespace:        "default",
 				PodLabelSelector: "app=test",
@@ -188,13 +784,16 @@ func TestCommand_Validate(t *testing.T) {
 				QPS:              20,
 				Burst:            50,
 				ServerPort:       8080,
+				BlocklistSource:  "ftp://example.com/blocklist.txt",
+				BlocklistRefresh: DefaultBlocklistRefresh,
 			},
 			wantErr: true,
 		},
 		{
-			name: "missing cluster",
+			name: "blocklist without refresh period",
 			command: &Command{
 				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
 				Namespace:        "default",
 				PodLabelSelector: "app=test",
 				Container:        "main",
@@ -203,43 +802,47 @@ func TestCommand_Validate(t *testing.T) {
 				QPS:              20,
 				Burst:            50,
 				ServerPort:       8080,
+				BlocklistSource:  "/etc/gpuid/blocklist.txt",
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid workers",
+			name: "invalid expected inventory source",
 			command: &Command{
 				ExporterType:     "stdout",
 				Cluster:          "test-cluster",
 				Namespace:        "default",
 				PodLabelSelector: "app=test",
 				Container:        "main",
-				Workers:          0,
+				Workers:          3,
 				Timeout:          30 * time.Second,
 				QPS:              20,
 				Burst:            50,
 				ServerPort:       8080,
+				ExpectedInv:      "configmap://gpuid",
+				ExpectedRefresh:  DefaultExpectedRefresh,
 			},
 			wantErr: true,
 		},
 		{
-			name: "too many workers",
+			name: "expected inventory without refresh period",
 			command: &Command{
 				ExporterType:     "stdout",
 				Cluster:          "test-cluster",
 				Namespace:        "default",
 				PodLabelSelector: "app=test",
 				Container:        "main",
-				Workers:          101,
+				Workers:          3,
 				Timeout:          30 * time.Second,
 				QPS:              20,
 				Burst:            50,
 				ServerPort:       8080,
+				ExpectedInv:      "/etc/gpuid/expected.csv",
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid timeout",
+			name: "duplicate detection without window",
 			command: &Command{
 				ExporterType:     "stdout",
 				Cluster:          "test-cluster",
@@ -247,15 +850,16 @@ func TestCommand_Validate(t *testing.T) {
 				PodLabelSelector: "app=test",
 				Container:        "main",
 				Workers:          3,
-				Timeout:          0,
+				Timeout:          30 * time.Second,
 				QPS:              20,
 				Burst:            50,
 				ServerPort:       8080,
+				DuplicateDetect:  true,
 			},
 			wantErr: true,
 		},
 		{
-			name: "invalid port",
+			name: "workload attribution without resource",
 			command: &Command{
 				ExporterType:     "stdout",
 				Cluster:          "test-cluster",
@@ -266,10 +870,186 @@ func TestCommand_Validate(t *testing.T) {
 				Timeout:          30 * time.Second,
 				QPS:              20,
 				Burst:            50,
-				ServerPort:       999,
+				ServerPort:       8080,
+				WorkloadAttrib:   true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "workload annotations without resource",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				WorkloadAnnot:    true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "negative stale label TTL",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				StaleLabelTTL:    -time.Minute,
+			},
+			wantErr: true,
+		},
+		{
+			name: "invalid label output",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				LabelOutput:      "configmap",
+			},
+			wantErr: true,
+		},
+		{
+			name: "nodefeature label output",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				LabelOutput:      LabelOutputNodeFeature,
+				FeatureNamespace: "gpuid",
+			},
+			wantErr: false,
+		},
+		{
+			name: "nodefeature label output without namespace",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				LabelOutput:      LabelOutputNodeFeature,
+			},
+			wantErr: true,
+		},
+		{
+			name: "nodefeature label output with label repair",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				LabelOutput:      LabelOutputNodeFeature,
+				FeatureNamespace: "gpuid",
+				LabelRepair:      true,
+			},
+			wantErr: true,
+		},
+		{
+			name: "agent mode without node name",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				AgentMode:        true,
+				PodResSocket:     DefaultPodResSocket,
+				AgentInterval:    time.Minute,
+				WorkloadResource: DefaultWorkloadResource,
+			},
+			wantErr: true,
+		},
+		{
+			name: "agent mode with leader election",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				AgentMode:        true,
+				NodeName:         "gpu-node-1",
+				PodResSocket:     DefaultPodResSocket,
+				AgentInterval:    time.Minute,
+				WorkloadResource: DefaultWorkloadResource,
+				LeaderElection:   true,
+				LeaseNamespace:   "default",
+				LeaseName:        "gpuid",
+				LeaseDuration:    15 * time.Second,
+				LeaseRenew:       10 * time.Second,
+				LeaseRetry:       2 * time.Second,
+				PodName:          "gpuid-0",
+				PodNamespace:     "default",
 			},
 			wantErr: true,
 		},
+		{
+			name: "valid agent mode",
+			command: &Command{
+				ExporterType:     "stdout",
+				Cluster:          "test-cluster",
+				Namespace:        "default",
+				PodLabelSelector: "app=test",
+				Container:        "main",
+				Workers:          3,
+				Timeout:          30 * time.Second,
+				QPS:              20,
+				Burst:            50,
+				ServerPort:       8080,
+				AgentMode:        true,
+				NodeName:         "gpu-node-1",
+				PodResSocket:     DefaultPodResSocket,
+				AgentInterval:    time.Minute,
+				WorkloadResource: DefaultWorkloadResource,
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -349,6 +1129,45 @@ func TestListEnvVars(t *testing.T) {
 		EnvVarLeaseRetry,
 		EnvVarPodName,
 		EnvVarPodNamespace,
+		EnvVarSharding,
+		EnvVarExecTransport,
+		EnvVarExecCommand,
+		EnvVarExecSearchPaths,
+		EnvVarContainerDetect,
+		EnvVarEphemeral,
+		EnvVarEphemeralImage,
+		EnvVarNodeConditions,
+		EnvVarInventoryCond,
+		EnvVarInventoryCRD,
+		EnvVarInventoryAnnot,
+		EnvVarLabelDomain,
+		EnvVarChassisKeyTmpl,
+		EnvVarGPUKeyTmpl,
+		EnvVarGPUValueTmpl,
+		EnvVarLabelMigrate,
+		EnvVarDerivedLabels,
+		EnvVarHealthTaint,
+		EnvVarHealthTaintSpec,
+		EnvVarTaintMaxPercent,
+		EnvVarBlocklistSource,
+		EnvVarBlocklistRefresh,
+		EnvVarBlocklistCordon,
+		EnvVarExpectedInv,
+		EnvVarExpectedRefresh,
+		EnvVarDuplicateDetect,
+		EnvVarDuplicateWindow,
+		EnvVarWorkloadAttrib,
+		EnvVarWorkloadResource,
+		EnvVarWorkloadAnnot,
+		EnvVarNodeRemovals,
+		EnvVarLabelRepair,
+		EnvVarStaleLabelTTL,
+		EnvVarLabelOutput,
+		EnvVarFeatureNS,
+		EnvVarAgentMode,
+		EnvVarNodeName,
+		EnvVarPodResSocket,
+		EnvVarAgentInterval,
 	}
 
 	if !reflect.DeepEqual(envVars, expectedVars) {
@@ -376,6 +1195,8 @@ func TestNewCommandFromEnvVars(t *testing.T) {
 	os.Setenv(EnvVarExporterType, "stdout")
 	os.Setenv(EnvVarClusterName, testClusterName)
 	os.Setenv(EnvVarWorkers, "5")
+	os.Setenv(EnvVarExecCommand, "/usr/local/nvidia/bin/nvidia-smi  -q -x")
+	os.Setenv(EnvVarExecSearchPaths, " /opt/bin/nvidia-smi, ,/usr/bin/nvidia-smi")
 
 	cmd, err := NewCommandFromEnvVars()
 	if err != nil {
@@ -394,6 +1215,15 @@ func TestNewCommandFromEnvVars(t *testing.T) {
 	if cmd.Workers != 5 {
 		t.Errorf("Expected Workers 5, got %d", cmd.Workers)
 	}
+	if len(cmd.ExecCommand) != 3 || cmd.ExecCommand[0] != "/usr/local/nvidia/bin/nvidia-smi" {
+		t.Errorf("Expected ExecCommand argv, got %q", cmd.ExecCommand)
+	}
+	if len(cmd.ExecSearchPaths) != 2 || cmd.ExecSearchPaths[0] != "/opt/bin/nvidia-smi" {
+		t.Errorf("Expected 2 ExecSearchPaths, got %q", cmd.ExecSearchPaths)
+	}
+	if !cmd.ContainerDetect {
+		t.Error("Expected ContainerDetect to default to true")
+	}
 }
 
 func TestLookupEnv(t *testing.T) {
@@ -0,0 +1,185 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/node"
+	"github.com/mchmarny/gpuid/pkg/shard"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// maxRemovalRetries bounds the export retries of one deleted node.
+const maxRemovalRetries = 5
+
+var counterNodeRemoval = counter.New("gpuid_node_removal_total", "Total number of deleted GPU nodes whose removal records were exported", "result")
+
+// remover exports a removed reading for every GPU recorded on a node when the
+// node is deleted, so downstream history gets an explicit end of the GPU's
+// residency instead of a last reading at an arbitrary time. The GPUs come from
+// the node's last scan seen by this replica, otherwise from its inventory
+// annotation or labels.
+type remover struct {
+	log     *slog.Logger
+	cmd     *Command
+	members *shard.Membership
+
+	mu      sync.Mutex
+	deleted map[string]*corev1.Node
+	scans   map[string][]*gpu.Serials // last scanned serials by node name
+}
+
+func newRemover(log *slog.Logger, cmd *Command, members *shard.Membership) *remover {
+	return &remover{
+		log:     log.With("component", "removal"),
+		cmd:     cmd,
+		members: members,
+		deleted: make(map[string]*corev1.Node),
+		scans:   make(map[string][]*gpu.Serials),
+	}
+}
+
+// observe records the serials of the last successful scan of nodeName, nil when
+// it has no GPUs.
+func (r *remover) observe(nodeName string, serials []*gpu.Serials) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.scans[nodeName] = serials
+}
+
+// run exports the removal records of the nodes deleted from informer until ctx
+// is canceled. Only deletions seen while it runs are exported, so a node deleted
+// while no replica leads gets no record.
+func (r *remover) run(ctx context.Context, informer cache.SharedIndexInformer) error {
+	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
+	defer q.ShutDown()
+
+	reg, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		DeleteFunc: func(obj any) {
+			if t, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+				obj = t.Obj
+			}
+			n, ok := obj.(*corev1.Node)
+			if !ok {
+				return
+			}
+			owned := ownsNode(r.members, n.Name)
+			r.mu.Lock()
+			if owned {
+				r.deleted[n.Name] = n
+			} else {
+				delete(r.scans, n.Name)
+			}
+			r.mu.Unlock()
+			if owned {
+				q.Add(n.Name)
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add node removal event handler: %w", err)
+	}
+	defer func() {
+		if rErr := informer.RemoveEventHandler(reg); rErr != nil {
+			r.log.Warn("failed to remove node removal event handler", "err", rErr)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+		q.ShutDownWithDrain()
+	}()
+
+	for {
+		name, shutdown := q.Get()
+		if shutdown {
+			return nil
+		}
+		func() {
+			defer q.Done(name)
+			if err := r.sync(ctx, name); err != nil {
+				if ctx.Err() == nil && q.NumRequeues(name) < maxRemovalRetries {
+					r.log.Warn("failed to export node removal, retrying", "node", name, "err", err)
+					q.AddRateLimited(name)
+					return
+				}
+				r.log.Error("failed to export node removal, giving up", "node", name, "err", err)
+			}
+			q.Forget(name)
+			r.mu.Lock()
+			delete(r.deleted, name)
+			delete(r.scans, name)
+			r.mu.Unlock()
+		}()
+	}
+}
+
+// sync exports the removal records of the deleted node name.
+func (r *remover) sync(ctx context.Context, name string) error {
+	r.mu.Lock()
+	n := r.deleted[name]
+	serials, scanned := r.scans[name]
+	r.mu.Unlock()
+	if n == nil {
+		return nil
+	}
+	if !scanned {
+		serials = node.RecordedSerials(r.cmd.labelFormat, n)
+	}
+
+	records := removalRecords(r.log, r.cmd, n, serials, time.Now().UTC())
+	if len(records) == 0 {
+		r.log.Debug("deleted node had no recorded GPUs", "node", name)
+		return nil
+	}
+
+	ectx, cancel := context.WithTimeout(ctx, r.cmd.Timeout)
+	defer cancel()
+
+	if err := r.cmd.exporter.ExportReadings(ectx, r.log, records); err != nil {
+		counterNodeRemoval.Increment("failure")
+		return err
+	}
+	counterNodeRemoval.Increment("success")
+	r.log.Info("node removal exported", "node", name, "gpus", len(records))
+	return nil
+}
+
+// removalRecords returns one removed reading per chassis and GPU serial of the
+// deleted node n. The machine is the provider instance ID, or the node name
+// without one.
+func removalRecords(log *slog.Logger, cmd *Command, n *corev1.Node, serials []*gpu.Serials, now time.Time) []*gpu.SerialNumberReading {
+	machine := n.Name
+	if info, err := node.ParseProviderID(log, n.Spec.ProviderID); err == nil && info.Identifier != "" {
+		machine = info.Identifier
+	}
+
+	var records []*gpu.SerialNumberReading
+	seen := make(map[[2]string]bool)
+	for _, s := range serials {
+		for _, serial := range s.GPU {
+			if serial == "" || seen[[2]string{s.Chassis, serial}] {
+				continue
+			}
+			seen[[2]string{s.Chassis, serial}] = true
+			records = append(records, &gpu.SerialNumberReading{
+				Cluster: cmd.Cluster,
+				Node:    n.Name,
+				Machine: machine,
+				Source:  "node/" + n.Name,
+				Chassis: s.Chassis,
+				GPU:     serial,
+				Time:    now,
+				Event:   gpu.ReadingRemoved,
+			})
+		}
+	}
+	return records
+}
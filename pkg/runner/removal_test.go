@@ -0,0 +1,118 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func gpuNode(name string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				"gpuid.github.com/chassis":       "C1",
+				"gpuid.github.com/gpu-0":         "S1",
+				"gpuid.github.com/gpu-1":         "S2",
+				"gpuid.github.com/chassis-count": "1",
+			},
+		},
+		Spec: corev1.NodeSpec{ProviderID: "aws:///us-east-1a/i-123"},
+	}
+}
+
+func TestRemovalRecords(t *testing.T) {
+	cmd := NewCommand(WithClusterName("test"))
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	serials := []*gpu.Serials{{Chassis: "C1", GPU: []string{"S1", "S2", "S2"}}}
+	got := removalRecords(slog.Default(), cmd, gpuNode("node-1"), serials, now)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(got))
+	}
+	for i, serial := range []string{"S1", "S2"} {
+		r := got[i]
+		if r.GPU != serial || r.Chassis != "C1" || r.Node != "node-1" || r.Machine != "i-123" ||
+			r.Event != gpu.ReadingRemoved || !r.Time.Equal(now) || r.Validate() != nil {
+			t.Errorf("unexpected record %d: %+v", i, r)
+		}
+	}
+
+	// No provider ID: the node name identifies the machine.
+	n := gpuNode("node-2")
+	n.Spec.ProviderID = ""
+	if got := removalRecords(slog.Default(), cmd, n, serials, now); len(got) != 2 || got[0].Machine != "node-2" {
+		t.Errorf("unexpected records without a provider ID: %+v", got)
+	}
+
+	if got := removalRecords(slog.Default(), cmd, &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cpu"}}, nil, now); len(got) != 0 {
+		t.Errorf("expected no records for a node without GPUs, got %+v", got)
+	}
+}
+
+func TestRemoverRun(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	cs := fake.NewClientset(gpuNode("node-1"), gpuNode("node-2"), &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cpu"}})
+	informer := informers.NewSharedInformerFactory(cs, 0).Core().V1().Nodes().Informer()
+	go informer.Run(ctx.Done())
+	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+		t.Fatal("node cache did not sync")
+	}
+
+	backend := &recordingBackend{}
+	cmd := NewCommand(WithClusterName("test"), WithNodeRemovals(true))
+	cmd.exporter = &Exporter{Type: "test", backend: backend}
+
+	r := newRemover(slog.Default(), cmd, nil)
+	// The last scan of node-2 wins over its labels.
+	r.observe("node-2", []*gpu.Serials{{Chassis: "C2", GPU: []string{"S9"}}})
+	done := make(chan error)
+	go func() { done <- r.run(ctx, informer) }()
+	// Let run register its handler before the deletes.
+	time.Sleep(100 * time.Millisecond)
+
+	for _, name := range []string{"cpu", "node-1", "node-2"} {
+		if err := cs.CoreV1().Nodes().Delete(ctx, name, metav1.DeleteOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backend.mu.Lock()
+		n := len(backend.readings)
+		backend.mu.Unlock()
+		if n == 3 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 3 removal records, got %d", n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("run() error = %v", err)
+	}
+	if len(r.deleted) != 0 || len(r.scans) != 0 {
+		t.Errorf("expected deleted nodes to be dropped, got %v and %v", r.deleted, r.scans)
+	}
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	for _, rd := range backend.readings {
+		if rd.Node == "node-2" && (rd.Chassis != "C2" || rd.GPU != "S9") {
+			t.Errorf("expected node-2 records from its last scan, got %+v", rd)
+		}
+	}
+}
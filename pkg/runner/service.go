@@ -2,32 +2,26 @@ package runner
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
-	"sync"
 	"syscall"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/inventory"
 	"github.com/mchmarny/gpuid/pkg/logger"
-	"github.com/mchmarny/gpuid/pkg/node"
 	"github.com/mchmarny/gpuid/pkg/server"
-	corev1 "k8s.io/api/core/v1"
+	"github.com/mchmarny/gpuid/pkg/shard"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/leaderelection"
 	"k8s.io/client-go/tools/leaderelection/resourcelock"
-	"k8s.io/client-go/util/workqueue"
 )
 
 const (
@@ -36,14 +30,15 @@ const (
 
 	// exporterCloseTimeout bounds exporter flush during shutdown.
 	exporterCloseTimeout = 10 * time.Second
-)
 
-// leaderState reports the leader-election lifecycle outcome to the caller of Run.
-type leaderState int
+	// Leadership transition label values.
+	leaderTransitionStarted = "started"
+	leaderTransitionLost    = "lost"
+)
 
-const (
-	leaderShutdown leaderState = iota // ctx canceled before/after leadership
-	leaderLost                        // we held the lease and then lost it — fatal
+var (
+	// counterLeader tracks leadership transitions so lease flapping is visible without log scraping.
+	counterLeader = counter.New("gpuid_leader_transitions_total", "Total number of leader election transitions", "transition")
 )
 
 // Run starts the pod execution controller with proper lifecycle management.
@@ -100,28 +95,44 @@ func Run() int {
 		return 2
 	}
 
+	handlers := map[string]http.Handler{
+		"/metrics": counter.Handler(),
+		"/status":  statuses,
+	}
+
+	if cmd.ExpectedInv != "" {
+		rec, recErr := inventory.NewReconciler(cmd.ExpectedInv, cs)
+		if recErr != nil {
+			log.Error("invalid expected inventory source", "err", recErr)
+			return 2
+		}
+		// Start with whatever loads now; a failed load is retried on every refresh.
+		if rErr := rec.Refresh(ctx, log); rErr != nil {
+			log.Error("failed to load expected inventory", "source", cmd.ExpectedInv, "err", rErr)
+		}
+		cmd.expected = rec
+		handlers["/inventory/report"] = rec
+	}
+
 	// HTTP server runs on every replica so probes and metrics work even on followers.
 	// Reconciliation goes through runController, which is gated on leader election
 	// when enabled.
 	srvErrCh := make(chan error, 1)
 	srv := server.NewServer(server.WithLogger(log), server.WithPort(cmd.ServerPort))
 	go func() {
-		srvErrCh <- srv.Serve(ctx, map[string]http.Handler{
-			"/metrics": counter.Handler(),
-		})
+		srvErrCh <- srv.Serve(ctx, handlers)
 	}()
 
 	var reconcileErr error
-	if cmd.LeaderElection {
-		state, err := runWithLeaderElection(ctx, log, cs, cfg, cmd)
-		reconcileErr = err
-		if state == leaderLost {
-			// Lost the lease unexpectedly — exit non-zero so Kubernetes restarts us.
-			log.Error("leader election lease lost, exiting", "err", reconcileErr)
-			return 1
-		}
-	} else {
-		reconcileErr = runController(ctx, log, cs, cfg, cmd)
+	switch {
+	case cmd.AgentMode:
+		reconcileErr = runAgent(ctx, log, cs, cmd)
+	case cmd.Sharding:
+		reconcileErr = runWithSharding(ctx, log, cs, cfg, cmd)
+	case cmd.LeaderElection:
+		reconcileErr = runWithLeaderElection(ctx, log, cs, cfg, cmd)
+	default:
+		reconcileErr = runController(ctx, log, cs, cfg, cmd, nil)
 	}
 
 	// Drain server. If both reconcile and server return errors prefer the reconcile
@@ -143,15 +154,51 @@ func Run() int {
 	return 0
 }
 
-// runWithLeaderElection blocks until ctx is canceled or the lease is lost.
-// While leading, it invokes runController with a context that is canceled when
-// either ctx is canceled OR leadership is lost.
-func runWithLeaderElection(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, cfg *rest.Config, cmd *Command) (leaderState, error) {
+// runWithLeaderElection blocks until ctx is canceled. While leading, it runs the
+// controller with a context that is canceled when either ctx is canceled OR
+// leadership is lost. Informers start before campaigning and stay up across
+// terms; losing the lease only tears the workers down and rejoins the election,
+// so every term (including a follower's first) starts from a synced cache.
+func runWithLeaderElection(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, cfg *rest.Config, cmd *Command) error {
 	// Identity must be unique per replica. PodName + a per-process uuid ensures we
 	// can't accidentally collide with a stale lease record from a pod with the
 	// same name in a previous incarnation.
 	identity := fmt.Sprintf("%s_%s", cmd.PodName, uuid.New().String())
 
+	ctrl, err := newController(ctx, log, cs, cfg, cmd, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create controller: %w", err)
+	}
+	defer ctrl.stop()
+
+	// Warm standby: keep the informers synced while following so failover does
+	// not pay for a full list of the cluster before the first pod is processed.
+	go ctrl.warmup(ctx)
+
+	for {
+		led, runErr := leaderTerm(ctx, log, cs, cmd, identity, ctrl)
+
+		if ctx.Err() != nil {
+			return runErr
+		}
+
+		if led {
+			// Still running, so the lease was lost (API server hiccup, long GC pause).
+			counterLeader.Increment(leaderTransitionLost)
+			log.Warn("leader election lease lost, rejoining election", "identity", identity, "err", runErr)
+			continue
+		}
+
+		if runErr != nil {
+			return runErr
+		}
+	}
+}
+
+// leaderTerm runs one leader election round: it campaigns for the lease and, once
+// acquired, runs the controller until the lease is lost or ctx is canceled.
+// Reports whether this replica led during the round.
+func leaderTerm(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, cmd *Command, identity string, ctrl *controller) (bool, error) {
 	lock := &resourcelock.LeaseLock{
 		LeaseMeta: metav1.ObjectMeta{
 			Name:      cmd.LeaseName,
@@ -163,13 +210,10 @@ func runWithLeaderElection(ctx context.Context, log *slog.Logger, cs *kubernetes
 		},
 	}
 
-	// runErr is written by OnStartedLeading; runDone signals completion.
-	var (
-		runErr  error
-		runDone = make(chan struct{})
-	)
-
-	var state leaderState
+	// OnStartedLeading runs on an elector-owned goroutine that le.Run doesn't wait
+	// for, so hand the lease context over and run the controller here instead.
+	// That guarantees the previous term's workers are gone before we campaign again.
+	leading := make(chan context.Context, 1)
 
 	le, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
 		Lock:            lock,
@@ -179,17 +223,10 @@ func runWithLeaderElection(ctx context.Context, log *slog.Logger, cs *kubernetes
 		RetryPeriod:     cmd.LeaseRetry,
 		Callbacks: leaderelection.LeaderCallbacks{
 			OnStartedLeading: func(leaseCtx context.Context) {
-				log.Info("became leader, starting controller", "identity", identity)
-				runErr = runController(leaseCtx, log, cs, cfg, cmd)
-				close(runDone)
+				leading <- leaseCtx
 			},
 			OnStoppedLeading: func() {
-				log.Warn("stopped leading", "identity", identity)
-				// If ctx is still live we lost the lease; that's fatal because we
-				// have no idempotent way to know how far the new leader has progressed.
-				if ctx.Err() == nil {
-					state = leaderLost
-				}
+				log.Info("stopped leading", "identity", identity)
 			},
 			OnNewLeader: func(current string) {
 				if current == identity {
@@ -201,128 +238,43 @@ func runWithLeaderElection(ctx context.Context, log *slog.Logger, cs *kubernetes
 		Name: cmd.LeaseName,
 	})
 	if err != nil {
-		return leaderShutdown, fmt.Errorf("failed to create leader elector: %w", err)
+		return false, fmt.Errorf("failed to create leader elector: %w", err)
 	}
 
-	// Run blocks until ctx is canceled OR we lose the lease.
-	le.Run(ctx)
+	elected := make(chan struct{})
+	go func() {
+		defer close(elected)
+		le.Run(ctx)
+	}()
 
-	// If runController was started (we led at some point), wait for it to finish.
+	var leaseCtx context.Context
 	select {
-	case <-runDone:
-	default:
-		// We never led; nothing to wait for.
-	}
-
-	return state, runErr
-}
-
-// runController encapsulates the main controller logic with proper error handling.
-// Separating this allows for better testing and error management.
-func runController(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, cfg *rest.Config, cmd *Command) error {
-	if log == nil {
-		return errors.New("logger is nil")
-	}
-	if cs == nil {
-		return errors.New("kubernetes clientset is nil")
-	}
-	if cfg == nil {
-		return errors.New("kubernetes config is nil")
-	}
-	if cmd == nil {
-		return errors.New("command configuration is nil")
-	}
-
-	log.Info("starting controller...")
-
-	// Build the labeler once — it is stateless and safe to share across workers.
-	labeler := node.NewLabelUpdater(cs)
-
-	// Create ListWatch for pod informer with proper error handling
-	lw := &cache.ListWatch{
-		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
-			opts.LabelSelector = cmd.PodLabelSelector
-			return cs.CoreV1().Pods(cmd.Namespace).List(ctx, opts)
-		},
-		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
-			opts.LabelSelector = cmd.PodLabelSelector
-			return cs.CoreV1().Pods(cmd.Namespace).Watch(ctx, opts)
-		},
-	}
-
-	informer := cache.NewSharedIndexInformer(
-		lw,
-		&corev1.Pod{},
-		cmd.Resync,
-		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
-	)
-
-	// Rate-limiting queue to handle pod events without overwhelming the API server.
-	q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[string]())
-	defer q.ShutDown()
-
-	enqueueIfReady := func(obj any) {
-		pod, ok := obj.(*corev1.Pod)
-		if !ok {
-			log.Warn("received non-pod object in event handler")
-			return
+	case leaseCtx = <-leading:
+	case <-elected:
+		// Campaign ended; we may still have acquired right before it did.
+		select {
+		case leaseCtx = <-leading:
+		default:
+			return false, nil
 		}
-
-		if !podReady(pod) {
-			log.Debug("skipping non-ready pod", "pod", pod.Name, "phase", pod.Status.Phase)
-			return
-		}
-
-		key, err := cache.MetaNamespaceKeyFunc(pod)
-		if err != nil {
-			log.Warn("failed to generate cache key", "pod", pod.Name, "err", err)
-			return
-		}
-
-		log.Debug("enqueueing ready pod", "pod", pod.Name, "key", key)
-		q.Add(key)
-	}
-
-	if _, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
-		AddFunc: enqueueIfReady,
-		UpdateFunc: func(_, newObj any) {
-			enqueueIfReady(newObj)
-		},
-	}); err != nil {
-		return fmt.Errorf("failed to add event handler: %w", err)
 	}
 
-	stopCh := make(chan struct{})
+	counterLeader.Increment(leaderTransitionStarted)
+	log.Info("became leader, starting controller", "identity", identity)
+	runErr := ctrl.run(leaseCtx)
+	<-elected
 
-	var wg sync.WaitGroup
-
-	log.Info("starting kubernetes informer")
-	wg.Go(func() {
-		informer.Run(stopCh)
-	})
-
-	// Wait for cache to sync before starting workers so we have a consistent view.
-	if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
-		close(stopCh)
-		wg.Wait()
-		return errors.New("failed to wait for informer cache sync")
-	}
+	return true, runErr
+}
 
-	log.Info("cache synced, starting workers")
-	for i := range cmd.Workers {
-		log.Debug("starting worker", "worker_id", i)
-		wg.Go(func() {
-			do(ctx, log.With("worker_id", i), cs, cfg, informer.GetIndexer(), q, labeler, cmd)
-		})
+// runController runs the controller until ctx is canceled, without leader election.
+// When members is non-nil only pods on nodes owned by this replica are processed.
+func runController(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, cfg *rest.Config, cmd *Command, members *shard.Membership) error {
+	ctrl, err := newController(ctx, log, cs, cfg, cmd, members)
+	if err != nil {
+		return err
 	}
+	defer ctrl.stop()
 
-	<-ctx.Done()
-	log.Info("shutdown signal received, draining work queue")
-	q.ShutDownWithDrain()
-	close(stopCh)
-
-	wg.Wait()
-	log.Info("controller shutdown complete")
-
-	return nil
+	return ctrl.run(ctx)
 }
@@ -11,6 +11,10 @@ var (
 	// For multi-replica controller setups migrate to a distributed cache like Redis or Memcached.
 	processed = newUIDSet()
 
+	// failed tracks pods that failed permanently, keyed by failureKey, so the
+	// pod isn't collected again until it restarts or the entry expires.
+	failed = newUIDSet()
+
 	// cacheTTL is the duration for how long UIDs in the set will be kept before expired.
 	cacheTTL = 30 * time.Minute
 
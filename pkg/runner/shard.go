@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/mchmarny/gpuid/pkg/counter"
+	"github.com/mchmarny/gpuid/pkg/shard"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	// Metrics for monitoring shard membership and rebalancing.
+	gaugeShardMembers  = counter.NewGauge("gpuid_shard_members", "Number of live replicas in the shard group")
+	counterShardChange = counter.New("gpuid_shard_rebalance_total", "Total number of shard rebalances observed by this replica")
+)
+
+// runWithSharding runs the controller on every replica, each handling only the
+// nodes it owns on the consistent-hash ring built from the live member leases.
+// Blocks until ctx is canceled.
+func runWithSharding(ctx context.Context, log *slog.Logger, cs *kubernetes.Clientset, cfg *rest.Config, cmd *Command) error {
+	members, err := shard.NewMembership(cs, shard.Config{
+		Namespace:     cmd.LeaseNamespace,
+		Group:         cmd.LeaseName,
+		Identity:      cmd.PodName,
+		LeaseDuration: cmd.LeaseDuration,
+		RenewPeriod:   cmd.LeaseRetry,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create shard membership: %w", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Go(func() {
+		members.Run(ctx, log)
+	})
+
+	log.Info("sharding enabled, starting controller", "identity", members.Identity(), "group", cmd.LeaseName)
+	runErr := runController(ctx, log, cs, cfg, cmd, members)
+
+	// Wait for the member lease to be released so peers rebalance right away.
+	wg.Wait()
+
+	return runErr
+}
+
+// ownsNode reports whether this replica is responsible for node. Without
+// sharding (nil membership) every node is owned.
+func ownsNode(members *shard.Membership, node string) bool {
+	return members == nil || members.Owns(node)
+}
+
+// rebalanceOnChange re-evaluates every cached pod whenever the member set changes
+// so nodes that moved to this replica are picked up without waiting for a pod event.
+// Nodes that moved away are dropped by the ownership check at enqueue/process time.
+func rebalanceOnChange(ctx context.Context, log *slog.Logger, members *shard.Membership, list func() []any, enqueue func(any)) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-members.Changes():
+			current := members.Members()
+			gaugeShardMembers.Set(float64(len(current)))
+			counterShardChange.Increment()
+
+			objs := list()
+			log.Info("rebalancing shard", "members", current, "cached_pods", len(objs))
+			for _, o := range objs {
+				enqueue(o)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package runner
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/client-go/tools/cache"
+)
+
+// Pod processing states reported by the status endpoint.
+const (
+	podStateSucceeded = "succeeded"
+	podStateNoGPU     = "no_gpu"
+	podStateRetrying  = "retrying"
+	podStateFailed    = "failed"
+)
+
+var (
+	// statuses holds the latest processing outcome per pod for the /status endpoint.
+	statuses = newStatusStore()
+)
+
+// podStatus is the latest processing outcome of a single pod.
+type podStatus struct {
+	Key      string        `json:"key"`
+	Node     string        `json:"node,omitempty"`
+	State    string        `json:"state"`
+	Reason   failureReason `json:"reason,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Attempts int           `json:"attempts"`
+	Updated  time.Time     `json:"updated"`
+}
+
+// statusStore is a thread-safe map of pod key to its latest status.
+type statusStore struct {
+	mu sync.RWMutex
+	m  map[string]podStatus
+}
+
+func newStatusStore() *statusStore {
+	return &statusStore{m: make(map[string]podStatus)}
+}
+
+// set records the outcome for key. Attempts counts consecutive failures and
+// resets on success.
+func (s *statusStore) set(key, node, state string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := podStatus{
+		Key:     key,
+		Node:    node,
+		State:   state,
+		Updated: time.Now().UTC(),
+	}
+	if err != nil {
+		st.Reason = reasonOf(err)
+		st.Error = err.Error()
+		st.Attempts = s.m[key].Attempts + 1
+	}
+	s.m[key] = st
+}
+
+// delete drops the status of a pod that no longer exists.
+func (s *statusStore) delete(key string) {
+	s.mu.Lock()
+	delete(s.m, key)
+	s.mu.Unlock()
+}
+
+// forget drops the status of a deleted pod or its tombstone.
+func (s *statusStore) forget(obj any) {
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	if err != nil {
+		return
+	}
+	s.delete(key)
+}
+
+// list returns all statuses sorted by key.
+func (s *statusStore) list() []podStatus {
+	s.mu.RLock()
+	out := make([]podStatus, 0, len(s.m))
+	for _, st := range s.m {
+		out = append(out, st)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// ServeHTTP writes the per-pod statuses as JSON. An optional ?state= query
+// parameter filters the list (e.g. /status?state=failed).
+func (s *statusStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	list := s.list()
+	if state := r.URL.Query().Get("state"); state != "" {
+		filtered := list[:0]
+		for _, st := range list {
+			if st.State == state {
+				filtered = append(filtered, st)
+			}
+		}
+		list = filtered
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(list); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
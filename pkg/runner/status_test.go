@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestStatusStore(t *testing.T) {
+	s := newStatusStore()
+
+	failErr := fail(reasonDriverError, errors.New("exit 9"))
+	s.set("ns/a", "n1", podStateRetrying, failErr)
+	s.set("ns/a", "n1", podStateFailed, failErr)
+	s.set("ns/b", "n2", podStateSucceeded, nil)
+	s.set("ns/c", "n3", podStateSucceeded, nil)
+	s.delete("ns/c")
+	s.set("ns/d", "n4", podStateSucceeded, nil)
+	s.forget(&corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "ns", Name: "d"}})
+	s.set("ns/e", "n5", podStateSucceeded, nil)
+	s.forget(cache.DeletedFinalStateUnknown{Key: "ns/e"})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status code = %d", rec.Code)
+	}
+
+	var list []podStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 statuses, got %d", len(list))
+	}
+	if list[0].Key != "ns/a" || list[0].Reason != reasonDriverError || list[0].Attempts != 2 {
+		t.Errorf("unexpected failed status: %+v", list[0])
+	}
+	if list[1].Key != "ns/b" || list[1].Attempts != 0 || list[1].Error != "" {
+		t.Errorf("unexpected succeeded status: %+v", list[1])
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status?state=failed", nil))
+	list = nil
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("failed to decode status: %v", err)
+	}
+	if len(list) != 1 || list[0].State != podStateFailed {
+		t.Errorf("expected only the failed pod, got %+v", list)
+	}
+}
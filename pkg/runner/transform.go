@@ -0,0 +1,64 @@
+package runner
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// transformPod strips a pod down to the fields the workers use before it enters
+// the informer cache: identity, node, phase and per-container readiness and restarts. Managed
+// fields, the bulk of the spec (env, volumes, probes) and most of the status are
+// dropped, which cuts controller memory substantially in large clusters.
+// Anything that needs more of the pod must read it from the API server.
+func transformPod(obj any) (any, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		// Tombstones (cache.DeletedFinalStateUnknown) and other objects pass through.
+		return obj, nil
+	}
+
+	slim := &corev1.Pod{
+		TypeMeta: pod.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              pod.Name,
+			Namespace:         pod.Namespace,
+			UID:               pod.UID,
+			ResourceVersion:   pod.ResourceVersion,
+			Labels:            pod.Labels,
+			DeletionTimestamp: pod.DeletionTimestamp,
+		},
+		Spec: corev1.PodSpec{
+			NodeName: pod.Spec.NodeName,
+		},
+		Status: corev1.PodStatus{
+			Phase: pod.Status.Phase,
+		},
+	}
+
+	// Container names are kept so readiness can be compared against the spec and
+	// exec can target a container by name.
+	if len(pod.Spec.Containers) > 0 {
+		slim.Spec.Containers = make([]corev1.Container, len(pod.Spec.Containers))
+		for i := range pod.Spec.Containers {
+			slim.Spec.Containers[i] = corev1.Container{Name: pod.Spec.Containers[i].Name}
+		}
+	}
+
+	if len(pod.Status.ContainerStatuses) > 0 {
+		slim.Status.ContainerStatuses = make([]corev1.ContainerStatus, len(pod.Status.ContainerStatuses))
+		for i := range pod.Status.ContainerStatuses {
+			cs := pod.Status.ContainerStatuses[i]
+			slim.Status.ContainerStatuses[i] = corev1.ContainerStatus{
+				Name:         cs.Name,
+				Ready:        cs.Ready,
+				RestartCount: cs.RestartCount,
+			}
+		}
+	}
+
+	return slim, nil
+}
+
+// Compile-time check that transformPod satisfies the informer transform contract.
+var _ cache.TransformFunc = transformPod
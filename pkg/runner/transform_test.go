@@ -0,0 +1,229 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+// syntheticPod builds a device-plugin-like pod with the kind of bulk real pods carry.
+func syntheticPod(i int) *corev1.Pod {
+	env := make([]corev1.EnvVar, 0, 20)
+	for j := range 20 {
+		env = append(env, corev1.EnvVar{Name: fmt.Sprintf("ENV_VAR_%d", j), Value: fmt.Sprintf("value-%d-%d", i, j)})
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            fmt.Sprintf("nvidia-device-plugin-%05d", i),
+			Namespace:       "gpu-operator",
+			UID:             types.UID(fmt.Sprintf("uid-%05d", i)),
+			ResourceVersion: "1",
+			Labels:          map[string]string{"app": "nvidia-device-plugin-daemonset"},
+			Annotations:     map[string]string{"kubectl.kubernetes.io/restartedAt": "2026-01-01T00:00:00Z"},
+			ManagedFields: []metav1.ManagedFieldsEntry{{
+				Manager:    "kube-controller-manager",
+				Operation:  metav1.ManagedFieldsOperationUpdate,
+				APIVersion: "v1",
+				FieldsType: "FieldsV1",
+				FieldsV1:   &metav1.FieldsV1{Raw: make([]byte, 2048)},
+			}},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: fmt.Sprintf("gpu-node-%05d", i),
+			Containers: []corev1.Container{{
+				Name:  "nvidia-device-plugin",
+				Image: "nvcr.io/nvidia/k8s-device-plugin:v0.17.0",
+				Env:   env,
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+				},
+				VolumeMounts: []corev1.VolumeMount{{Name: "device-plugin", MountPath: "/var/lib/kubelet/device-plugins"}},
+			}},
+			Volumes: []corev1.Volume{{
+				Name:         "device-plugin",
+				VolumeSource: corev1.VolumeSource{HostPath: &corev1.HostPathVolumeSource{Path: "/var/lib/kubelet/device-plugins"}},
+			}},
+		},
+		Status: corev1.PodStatus{
+			Phase:  corev1.PodRunning,
+			HostIP: "10.0.0.1",
+			PodIP:  "10.1.0.1",
+			Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: corev1.ConditionTrue},
+				{Type: corev1.ContainersReady, Status: corev1.ConditionTrue},
+			},
+			ContainerStatuses: []corev1.ContainerStatus{{
+				Name:        "nvidia-device-plugin",
+				Ready:       true,
+				Image:       "nvcr.io/nvidia/k8s-device-plugin:v0.17.0",
+				ImageID:     "nvcr.io/nvidia/k8s-device-plugin@sha256:0000000000000000000000000000000000000000000000000000000000000000",
+				ContainerID: "containerd://0000000000000000000000000000000000000000000000000000000000000000",
+			}},
+		},
+	}
+}
+
+func TestTransformPod(t *testing.T) {
+	pod := syntheticPod(1)
+
+	out, err := transformPod(pod)
+	if err != nil {
+		t.Fatalf("transformPod() unexpected error: %v", err)
+	}
+	slim, ok := out.(*corev1.Pod)
+	if !ok {
+		t.Fatalf("transformPod() returned %T, want *corev1.Pod", out)
+	}
+
+	if slim.Name != pod.Name || slim.Namespace != pod.Namespace || slim.UID != pod.UID {
+		t.Errorf("identity not preserved: got %s/%s (%s)", slim.Namespace, slim.Name, slim.UID)
+	}
+	if slim.Spec.NodeName != pod.Spec.NodeName {
+		t.Errorf("node name not preserved: got %q", slim.Spec.NodeName)
+	}
+	if len(slim.ManagedFields) != 0 || len(slim.Annotations) != 0 {
+		t.Error("managed fields and annotations should be stripped")
+	}
+	if len(slim.Spec.Volumes) != 0 || len(slim.Spec.Containers[0].Env) != 0 || slim.Spec.Containers[0].Image != "" {
+		t.Error("spec bulk should be stripped")
+	}
+	if slim.Spec.Containers[0].Name != "nvidia-device-plugin" {
+		t.Error("container names should be preserved")
+	}
+	if len(slim.Status.Conditions) != 0 || slim.Status.ContainerStatuses[0].ImageID != "" {
+		t.Error("unneeded status should be stripped")
+	}
+	if !podReady(slim) {
+		t.Error("readiness must be preserved by the transform")
+	}
+
+	// Readiness reflects the source pod.
+	pod.Status.ContainerStatuses[0].Ready = false
+	out, _ = transformPod(pod)
+	if podReady(out.(*corev1.Pod)) {
+		t.Error("not-ready pod reported ready after transform")
+	}
+
+	// Restarts are kept so a restart re-evaluates the pod.
+	pod.Status.ContainerStatuses[0].RestartCount = 2
+	out, _ = transformPod(pod)
+	if podRestarts(out.(*corev1.Pod)) != 2 {
+		t.Error("restart counts must be preserved by the transform")
+	}
+
+	// Non-pod objects pass through untouched.
+	tombstone := cache.DeletedFinalStateUnknown{Key: "ns/name", Obj: pod}
+	if got, _ := transformPod(tombstone); got != tombstone {
+		t.Error("tombstone should pass through unchanged")
+	}
+}
+
+func TestPodChanged(t *testing.T) {
+	old, _ := transformPod(syntheticPod(1))
+	base := old.(*corev1.Pod)
+
+	changed := func(mutate func(p *corev1.Pod)) bool {
+		cur := base.DeepCopy()
+		cur.ResourceVersion = "2"
+		mutate(cur)
+		return podChanged(base, cur)
+	}
+
+	if changed(func(*corev1.Pod) {}) {
+		t.Error("an update that changes nothing the scan depends on should be ignored")
+	}
+	if !podChanged(base, base.DeepCopy()) {
+		t.Error("a resync should pass")
+	}
+	if !changed(func(p *corev1.Pod) { p.UID = "other" }) {
+		t.Error("a new UID should pass")
+	}
+	if !changed(func(p *corev1.Pod) { p.Status.ContainerStatuses[0].Ready = false }) {
+		t.Error("a readiness change should pass")
+	}
+	if !changed(func(p *corev1.Pod) { p.Status.ContainerStatuses[0].RestartCount++ }) {
+		t.Error("a container restart should pass")
+	}
+
+	restarted := base.DeepCopy()
+	restarted.Status.ContainerStatuses[0].RestartCount++
+	if failureKey(base) == failureKey(restarted) {
+		t.Error("a restart should clear the recorded failure")
+	}
+}
+
+// BenchmarkPodInformerMemory syncs a pod informer against a synthetic 10k-pod fake
+// clientset with and without the transform and reports the retained heap per pod.
+func BenchmarkPodInformerMemory(b *testing.B) {
+	const pods = 10000
+
+	objs := make([]k8sruntime.Object, 0, pods)
+	for i := range pods {
+		objs = append(objs, syntheticPod(i))
+	}
+	cmd := NewCommand(WithNamespace("gpu-operator"))
+
+	for _, bc := range []struct {
+		name      string
+		transform cache.TransformFunc
+	}{
+		{"full", nil},
+		{"transformed", transformPod},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			for range b.N {
+				cs := fake.NewClientset(objs...)
+				// The fake tracker replays every object into a bounded watch channel;
+				// the initial list is what fills the cache, so watch nothing.
+				cs.PrependWatchReactor("pods", func(k8stesting.Action) (bool, watch.Interface, error) {
+					return true, watch.NewFake(), nil
+				})
+				ctx, cancel := context.WithCancel(context.Background())
+
+				// Measured after the fake clientset is seeded so its own copies of
+				// the pods are excluded and only the informer's cache counts.
+				before := heapInUse()
+
+				informer, err := newPodInformer(ctx, cs, cmd, bc.transform)
+				if err != nil {
+					b.Fatalf("newPodInformer() error: %v", err)
+				}
+				stopCh := make(chan struct{})
+				go informer.Run(stopCh)
+				if !cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+					b.Fatal("cache did not sync")
+				}
+				if n := len(informer.GetStore().ListKeys()); n != pods {
+					b.Fatalf("cached %d pods, want %d", n, pods)
+				}
+
+				after := heapInUse()
+				b.ReportMetric(float64(after-before)/pods, "B/cached-pod")
+
+				close(stopCh)
+				cancel()
+				time.Sleep(10 * time.Millisecond)
+			}
+		})
+	}
+}
+
+func heapInUse() int64 {
+	runtime.GC()
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return int64(m.HeapInuse)
+}
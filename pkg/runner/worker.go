@@ -2,39 +2,60 @@ package runner
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
 	"math/rand/v2"
+	"strings"
 	"time"
 
+	"github.com/mchmarny/gpuid/pkg/blocklist"
 	"github.com/mchmarny/gpuid/pkg/counter"
 	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/inventory"
+	"github.com/mchmarny/gpuid/pkg/nfd"
 	"github.com/mchmarny/gpuid/pkg/node"
+	"github.com/mchmarny/gpuid/pkg/shard"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/util/workqueue"
 )
 
+const (
+	// scanStatusTimeout bounds the scan status annotation patch.
+	scanStatusTimeout = 10 * time.Second
+
+	// Reasons of the Warning Events emitted on nodes.
+	eventReasonBlocklisted = "BlocklistedGPU"
+	eventReasonMismatch    = "InventoryMismatch"
+	eventReasonDuplicate   = "DuplicateGPUSerial"
+
+	// maxEventFindings bounds the findings listed in an InventoryMismatch Event.
+	maxEventFindings = 5
+)
+
 var (
 	// Metrics for monitoring command execution outcomes.
 	counterSuccess = counter.New("gpuid_export_success_total", "Total number of successful export executions", "node", "pod")
-	counterErr     = counter.New("gpuid_export_failure_total", "Total number of failed export executions", "node", "pod")
+	counterErr     = counter.New("gpuid_export_failure_total", "Total number of failed export executions", "node", "pod", "reason")
 )
 
-// transientError marks an error as eligible for rate-limited retry through the workqueue.
-type transientError struct{ err error }
-
-func (t *transientError) Error() string { return t.err.Error() }
-func (t *transientError) Unwrap() error { return t.err }
-
-func transient(err error) error { return &transientError{err: err} }
-
-func isTransient(err error) bool {
-	var te *transientError
-	return errors.As(err, &te)
+// nodeActions holds the optional steps run against a node after its labels are
+// in place. A nil field means the feature is disabled.
+type nodeActions struct {
+	inventory  *inventory.Writer      // INVENTORY_CRD
+	features   *nfd.Writer            // LABEL_OUTPUT=nodefeature, instead of patching node labels
+	tainter    *node.Tainter          // HEALTH_TAINT
+	blocklist  *blocklist.Blocklist   // BLOCKLIST_SOURCE
+	expected   *inventory.Reconciler  // EXPECTED_INVENTORY
+	duplicates *inventory.SerialIndex // DUPLICATE_SERIAL_DETECTION
+	workloads  *attributor            // WORKLOAD_ATTRIBUTION
+	keeper     *labelKeeper           // LABEL_REPAIR or STALE_LABEL_TTL
+	removals   *remover               // NODE_REMOVAL_RECORDS
+	recorder   record.EventRecorder   // set with any of the above that emit Events
 }
 
 // do processes items from the work queue in a loop until the context is canceled.
@@ -46,7 +67,10 @@ func do(
 	indexer cache.Indexer,
 	q workqueue.TypedRateLimitingInterface[string],
 	labeler node.Updater,
+	acts nodeActions,
 	cmd *Command,
+	members *shard.Membership,
+	onWork func(),
 ) {
 
 	log.Debug("worker started")
@@ -59,6 +83,10 @@ func do(
 			return
 		}
 
+		if onWork != nil {
+			onWork()
+		}
+
 		func(key string) {
 			defer q.Done(key)
 
@@ -78,6 +106,7 @@ func do(
 			if !exists {
 				// Pod deleted; normal lifecycle.
 				log.Debug("pod no longer exists in cache", "key", key)
+				statuses.delete(key)
 				q.Forget(key)
 				return
 			}
@@ -89,12 +118,34 @@ func do(
 				return
 			}
 
-			if err := processPod(ctx, log, cs, cfg, labeler, pod, cmd); err != nil {
-				log.Warn("failed to process pod", "pod", pod.Name, "err", err)
-				if isTransient(err) {
+			// Ownership may have moved to another replica since the pod was enqueued.
+			if !ownsNode(members, pod.Spec.NodeName) {
+				log.Debug("node no longer owned by this replica", "pod", pod.Name, "node", pod.Spec.NodeName)
+				q.Forget(key)
+				return
+			}
+
+			if err := processPod(ctx, log, cs, cfg, labeler, acts, pod, cmd); err != nil {
+				if ctx.Err() != nil {
+					// Shutting down; the next term re-evaluates the pod.
+					q.Forget(key)
+					return
+				}
+
+				requeues := q.NumRequeues(key)
+				if shouldRetry(err, requeues) {
+					log.Warn("failed to process pod, retrying", "pod", pod.Name, "reason", reasonOf(err), "requeues", requeues, "err", err)
+					statuses.set(key, pod.Spec.NodeName, podStateRetrying, err)
 					q.AddRateLimited(key)
 					return
 				}
+
+				// Permanent: the pod is re-evaluated once a container restarts, or
+				// once the failure expires even if nothing about the pod changes.
+				log.Error("failed to process pod, giving up until the pod changes", "pod", pod.Name, "reason", reasonOf(err), "requeues", requeues, "err", err)
+				statuses.set(key, pod.Spec.NodeName, podStateFailed, err)
+				failed.Add(failureKey(pod))
+				q.AddAfter(key, cacheTTL)
 			}
 
 			q.Forget(key)
@@ -109,9 +160,10 @@ func processPod(
 	cs *kubernetes.Clientset,
 	cfg *rest.Config,
 	labeler node.Updater,
+	acts nodeActions,
 	pod *corev1.Pod,
 	cmd *Command,
-) error {
+) (err error) {
 	// In case pod transitioned states between enqueueing and processing
 	if !podReady(pod) {
 		log.Debug("pod not ready at processing time", "pod", pod.Name, "phase", pod.Status.Phase)
@@ -124,6 +176,12 @@ func processPod(
 		return nil
 	}
 
+	// Don't collect again from a pod that failed permanently until it restarts.
+	if failed.Has(failureKey(pod)) {
+		log.Debug("pod failed permanently, skipping until it restarts", "pod", pod.Name, "uid", pod.UID)
+		return nil
+	}
+
 	// Add jitter to prevent thundering herd when many pods become ready simultaneously.
 	jitter := time.Duration(rand.IntN(200)) * time.Millisecond //nolint:gosec // G404: non-crypto jitter
 	select {
@@ -142,51 +200,149 @@ func processPod(
 		"node", pod.Spec.NodeName,
 	)
 
-	serials, err := gpu.GetSerialNumbers(pctx, log, cs, cfg, pod, cmd.Container)
+	// Record the outcome on the node so `kubectl describe node` shows the last scan.
+	var serials []*gpu.Serials
+	defer func() {
+		recordScan(ctx, log, labeler, pod, cmd, serials, err)
+	}()
+
+	serials, err = gpu.GetSerialNumbers(pctx, log, cs, cfg, pod, cmd.execOptions())
 	if err != nil {
-		counterErr.Increment(pod.Spec.NodeName, pod.Name)
+		reason := collectionReason(err)
+		counterErr.Increment(pod.Spec.NodeName, pod.Name, string(reason))
 		log.Error("failed to get GPU serial numbers",
 			"pod", pod.Name,
 			"uid", pod.UID,
 			"node", pod.Spec.NodeName,
+			"reason", reason,
 			"err", err,
 		)
-		// Pod-exec failures are commonly transient (network, pod restart).
-		return transient(fmt.Errorf("error obtaining GPU serial numbers: %w", err))
+		return fail(reason, fmt.Errorf("error obtaining GPU serial numbers: %w", err))
 	}
 
 	if len(serials) == 0 {
 		log.Debug("no GPU serial numbers found, skipping export", "pod", pod.Name, "uid", pod.UID, "node", pod.Spec.NodeName)
+		// Remove the labels and inventory annotation of GPUs that went away.
+		if acts.keeper != nil {
+			acts.keeper.observe(pod.Spec.NodeName, nil)
+		}
+		if acts.removals != nil {
+			acts.removals.observe(pod.Spec.NodeName, nil)
+		}
+		if err = writeLabels(pctx, log, labeler, acts.features, pod.Spec.NodeName, nil, cmd.labelOptions()); err != nil {
+			reason := labelReason(err)
+			counterErr.Increment(pod.Spec.NodeName, pod.Name, string(reason))
+			log.Error("failed to remove node labels",
+				"pod", pod.Name,
+				"uid", pod.UID,
+				"node", pod.Spec.NodeName,
+				"reason", reason,
+				"err", err,
+			)
+			return fail(reason, fmt.Errorf("failed to remove node labels: %w", err))
+		}
 		// Cache this UID so we don't keep retrying pods that report no GPUs.
 		processed.Add(string(pod.UID))
+		statuses.set(podKey(pod), pod.Spec.NodeName, podStateNoGPU, nil)
 		return nil
 	}
 
-	if err = node.EnsureLabels(pctx, log, labeler, pod.Spec.NodeName, serials); err != nil {
-		counterErr.Increment(pod.Spec.NodeName, pod.Name)
+	if acts.keeper != nil {
+		acts.keeper.observe(pod.Spec.NodeName, serials)
+	}
+	if acts.removals != nil {
+		acts.removals.observe(pod.Spec.NodeName, serials)
+	}
+
+	if err = writeLabels(pctx, log, labeler, acts.features, pod.Spec.NodeName, serials, cmd.labelOptions()); err != nil {
+		reason := labelReason(err)
+		counterErr.Increment(pod.Spec.NodeName, pod.Name, string(reason))
 		log.Error("failed to ensure node labels",
 			"pod", pod.Name,
 			"uid", pod.UID,
 			"node", pod.Spec.NodeName,
+			"reason", reason,
 			"err", err,
 		)
-		return transient(fmt.Errorf("failed to ensure node labels: %w", err))
+		return fail(reason, fmt.Errorf("failed to ensure node labels: %w", err))
+	}
+
+	if acts.inventory != nil {
+		if err = acts.inventory.Write(pctx, log, pod.Spec.NodeName, serials, time.Now()); err != nil {
+			reason := labelReason(err)
+			counterErr.Increment(pod.Spec.NodeName, pod.Name, string(reason))
+			log.Error("failed to write GPU inventory",
+				"pod", pod.Name,
+				"uid", pod.UID,
+				"node", pod.Spec.NodeName,
+				"reason", reason,
+				"err", err,
+			)
+			return fail(reason, fmt.Errorf("failed to write GPU inventory: %w", err))
+		}
 	}
 
-	nodeInfo, err := node.GetNodeProviderID(pctx, log, cs, pod.Spec.NodeName)
+	if acts.tainter != nil {
+		if err = acts.tainter.Reconcile(pctx, log, pod.Spec.NodeName, node.HealthIssues(serials)); err != nil {
+			reason := labelReason(err)
+			counterErr.Increment(pod.Spec.NodeName, pod.Name, string(reason))
+			log.Error("failed to reconcile GPU health taint",
+				"pod", pod.Name,
+				"uid", pod.UID,
+				"node", pod.Spec.NodeName,
+				"reason", reason,
+				"err", err,
+			)
+			return fail(reason, fmt.Errorf("failed to reconcile GPU health taint: %w", err))
+		}
+	}
+
+	if acts.blocklist != nil {
+		matches := acts.blocklist.Match(serials)
+		var changed bool
+		if changed, err = node.MarkBlocklisted(pctx, log, labeler, cmd.labelFormat, pod.Spec.NodeName, matches, cmd.BlocklistCordon); err != nil {
+			reason := labelReason(err)
+			counterErr.Increment(pod.Spec.NodeName, pod.Name, string(reason))
+			log.Error("failed to record blocklisted serials",
+				"pod", pod.Name,
+				"uid", pod.UID,
+				"node", pod.Spec.NodeName,
+				"reason", reason,
+				"err", err,
+			)
+			return fail(reason, fmt.Errorf("failed to record blocklisted serials: %w", err))
+		}
+		if changed && len(matches) > 0 && acts.recorder != nil {
+			acts.recorder.Eventf(nodeRef(pod.Spec.NodeName), corev1.EventTypeWarning, eventReasonBlocklisted,
+				"Blocklisted serial numbers found on node: %s", strings.Join(matches, ", "))
+		}
+	}
+
+	if acts.expected != nil {
+		findings, changed := acts.expected.Observe(pod.Spec.NodeName, serials)
+		if changed && len(findings) > 0 {
+			log.Warn("GPUs differ from the expected inventory", "node", pod.Spec.NodeName, "findings", len(findings))
+			if acts.recorder != nil {
+				acts.recorder.Eventf(nodeRef(pod.Spec.NodeName), corev1.EventTypeWarning, eventReasonMismatch,
+					"%d differences from the expected inventory: %s", len(findings), summarizeFindings(findings))
+			}
+		}
+	}
+
+	nodeInfo, err := node.GetNodeProviderID(pctx, log, labeler, pod.Spec.NodeName)
 	if err != nil {
-		counterErr.Increment(pod.Spec.NodeName, pod.Name)
+		counterErr.Increment(pod.Spec.NodeName, pod.Name, string(reasonNodeAPI))
 		log.Warn("failed to get node provider ID",
 			"pod", pod.Name,
 			"uid", pod.UID,
 			"node", pod.Spec.NodeName,
 			"err", err,
 		)
-		return transient(fmt.Errorf("failed to get node provider ID: %w", err))
+		return fail(reasonNodeAPI, fmt.Errorf("failed to get node provider ID: %w", err))
 	}
 
 	if nodeInfo.Identifier == "" {
-		counterErr.Increment(pod.Spec.NodeName, pod.Name)
+		counterErr.Increment(pod.Spec.NodeName, pod.Name, string(reasonNodeAPI))
 		log.Warn("node provider ID is empty",
 			"pod", pod.Name,
 			"uid", pod.UID,
@@ -195,8 +351,19 @@ func processPod(
 		)
 	}
 
+	if acts.duplicates != nil {
+		for _, d := range acts.duplicates.Observe(pod.Spec.NodeName, nodeInfo.Identifier, serials, time.Now()) {
+			nodes := strings.Join(d.Nodes(), ", ")
+			log.Warn("GPU serial reported by more than one node", "serial", d.Serial, "nodes", nodes)
+			if acts.recorder != nil {
+				acts.recorder.Eventf(nodeRef(pod.Spec.NodeName), corev1.EventTypeWarning, eventReasonDuplicate,
+					"GPU serial %s is reported by nodes %s", d.Serial, nodes)
+			}
+		}
+	}
+
 	if err := cmd.exporter.Export(pctx, log, cmd.Cluster, pod, nodeInfo.Identifier, serials); err != nil {
-		counterErr.Increment(pod.Spec.NodeName, pod.Name)
+		counterErr.Increment(pod.Spec.NodeName, pod.Name, string(reasonExportFailure))
 		log.Error("failed to export GPU serial numbers",
 			"exporter", cmd.ExporterType,
 			"pod", pod.Name,
@@ -205,11 +372,16 @@ func processPod(
 			"provider", nodeInfo.Raw,
 			"err", err,
 		)
-		return transient(fmt.Errorf("failed to export GPU serial numbers: %w", err))
+		return fail(reasonExportFailure, fmt.Errorf("failed to export GPU serial numbers: %w", err))
+	}
+
+	if acts.workloads != nil {
+		acts.workloads.observeNode(pod.Spec.NodeName, nodeInfo.Identifier, serials)
 	}
 
 	counterSuccess.Increment(pod.Spec.NodeName, pod.Name)
 	processed.Add(string(pod.UID))
+	statuses.set(podKey(pod), pod.Spec.NodeName, podStateSucceeded, nil)
 
 	log.Debug("pod processed successfully",
 		"exporter", cmd.ExporterType,
@@ -221,6 +393,106 @@ func processPod(
 	return nil
 }
 
+// writeLabels writes the GPU labels of serials to nodeName, or to its NodeFeature
+// for node-feature-discovery to apply when features is set.
+func writeLabels(ctx context.Context, log *slog.Logger, labeler node.Updater, features *nfd.Writer, nodeName string, serials []*gpu.Serials, opts node.LabelOptions) error {
+	if features != nil {
+		return features.Write(ctx, log, nodeName, node.GPULabels(log, serials, opts))
+	}
+	return node.EnsureLabels(ctx, log, labeler, nodeName, serials, opts)
+}
+
+// recordScan writes the scan status annotations and, when enabled, the node
+// conditions for the pod's node. It uses its own budget since the per-pod context
+// may already be exhausted by a slow failure.
+func recordScan(ctx context.Context, log *slog.Logger, labeler node.Updater, pod *corev1.Pod, cmd *Command, serials []*gpu.Serials, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	st := node.ScanStatus{Time: time.Now(), Result: node.ScanSuccess}
+	switch {
+	case err != nil:
+		st.Result = node.ScanFailure
+		st.Reason = string(reasonOf(err))
+		st.Err = err
+	case len(serials) == 0:
+		st.Result = node.ScanNoGPU
+	default:
+		st.Hash = node.ContentHash(serials)
+	}
+
+	rctx, cancel := context.WithTimeout(ctx, scanStatusTimeout)
+	defer cancel()
+
+	// Read the previous inventory hash before RecordScan overwrites it.
+	var prevHash string
+	if cmd.NodeConditions || cmd.InventoryCond {
+		if n, gErr := labeler.GetNode(rctx, pod.Spec.NodeName); gErr == nil {
+			prevHash = n.Annotations[cmd.labelFormat.Key(node.AnnotationContentHash)]
+		}
+	}
+
+	if rErr := node.RecordScan(rctx, log, labeler, cmd.labelFormat, pod.Spec.NodeName, st); rErr != nil {
+		log.Warn("failed to record node scan status", "pod", pod.Name, "node", pod.Spec.NodeName, "err", rErr)
+	}
+
+	conds := node.ScanConditions(st, prevHash, cmd.NodeConditions, cmd.InventoryCond)
+	if cErr := node.SetConditions(rctx, log, labeler, pod.Spec.NodeName, conds); cErr != nil {
+		log.Warn("failed to set node conditions", "pod", pod.Name, "node", pod.Spec.NodeName, "err", cErr)
+	}
+}
+
+// summarizeFindings lists the first few findings for an Event message.
+func summarizeFindings(findings []inventory.Finding) string {
+	parts := make([]string, 0, maxEventFindings+1)
+	for i, f := range findings {
+		if i == maxEventFindings {
+			parts = append(parts, fmt.Sprintf("and %d more", len(findings)-i))
+			break
+		}
+		parts = append(parts, f.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// nodeRef returns the Event reference for a node. Like the kubelet, it uses the
+// node name as UID so events can be recorded without reading the node.
+func nodeRef(name string) *corev1.ObjectReference {
+	return &corev1.ObjectReference{Kind: "Node", Name: name, UID: types.UID(name)}
+}
+
+// podKey returns the namespace/name key used by the work queue.
+func podKey(p *corev1.Pod) string {
+	return p.Namespace + "/" + p.Name
+}
+
+// failureKey identifies a pod and its container restarts, so a permanent failure
+// is recorded per UID and cleared by a restart (e.g. after a driver or image fix).
+func failureKey(p *corev1.Pod) string {
+	return fmt.Sprintf("%s/%d", p.UID, podRestarts(p))
+}
+
+// podRestarts returns the sum of the container restart counts of p.
+func podRestarts(p *corev1.Pod) int32 {
+	var n int32
+	for _, s := range p.Status.ContainerStatuses {
+		n += s.RestartCount
+	}
+	return n
+}
+
+// podChanged reports whether an update from old to cur can change the outcome
+// of a scan: a new UID, readiness or container restarts. Other updates, like the
+// status of the ephemeral collection container, are ignored so they don't trigger
+// another scan. Periodic resyncs (same resource version) always pass.
+func podChanged(old, cur *corev1.Pod) bool {
+	return old.ResourceVersion == cur.ResourceVersion ||
+		old.UID != cur.UID ||
+		podReady(old) != podReady(cur) ||
+		podRestarts(old) != podRestarts(cur)
+}
+
 // podReady checks if a pod is ready to execute commands.
 // A pod is considered ready when it's in Running phase and all containers are ready.
 func podReady(p *corev1.Pod) bool {
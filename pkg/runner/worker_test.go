@@ -0,0 +1,74 @@
+package runner
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/mchmarny/gpuid/pkg/gpu"
+	"github.com/mchmarny/gpuid/pkg/node"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestWriteLabelsWithoutGPUs(t *testing.T) {
+	ctx := context.Background()
+	cs := fake.NewClientset(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", Labels: map[string]string{"example.com/team": "a"}}})
+	labeler := node.NewLabelUpdater(cs)
+	cmd := NewCommand(WithInventoryAnnotation(true))
+
+	serials := []*gpu.Serials{{Chassis: "C1", GPU: []string{"S1", "S2"}}}
+	if err := writeLabels(ctx, slog.Default(), labeler, nil, "node-1", serials, cmd.labelOptions()); err != nil {
+		t.Fatalf("writeLabels() error = %v", err)
+	}
+	n, err := cs.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !node.HasGPULabels(n, cmd.labelOptions()) || n.Annotations["gpuid.github.com/inventory"] == "" {
+		t.Fatalf("expected GPU labels and inventory annotation, got %v / %v", n.Labels, n.Annotations)
+	}
+
+	// The scan no longer finds GPUs: the labels and the annotation go away.
+	if err := writeLabels(ctx, slog.Default(), labeler, nil, "node-1", nil, cmd.labelOptions()); err != nil {
+		t.Fatalf("writeLabels() without GPUs error = %v", err)
+	}
+	n, err = cs.CoreV1().Nodes().Get(ctx, "node-1", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.HasGPULabels(n, cmd.labelOptions()) {
+		t.Errorf("expected GPU labels to be removed, got %v", n.Labels)
+	}
+	if _, ok := n.Annotations["gpuid.github.com/inventory"]; ok {
+		t.Error("expected the inventory annotation to be removed")
+	}
+	if n.Labels["example.com/team"] != "a" {
+		t.Error("expected labels of other writers to be kept")
+	}
+}
+
+func TestFirstPassDone(t *testing.T) {
+	store := cache.NewStore(cache.MetaNamespaceKeyFunc)
+	done, failing, gone := syntheticPod(90001), syntheticPod(90002), syntheticPod(90003)
+	for _, p := range []*corev1.Pod{done, failing} {
+		if err := store.Add(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pods := []any{done, failing, gone}
+
+	processed.Add(string(done.UID))
+	if firstPassDone(store, pods) {
+		t.Fatal("first pass should wait for every ready pod")
+	}
+
+	// A pod that failed for good counts as scanned; one no longer in the store
+	// isn't waited for.
+	failed.Add(failureKey(failing))
+	if !firstPassDone(store, pods) {
+		t.Error("first pass should be done once every ready pod was processed or failed")
+	}
+}
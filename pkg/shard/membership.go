@@ -0,0 +1,251 @@
+package shard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// GroupLabel marks the member leases that belong to the same shard group.
+	GroupLabel = "gpuid.github.com/shard-group"
+
+	// releaseTimeout bounds the lease delete on shutdown — parent ctx is canceled by then.
+	releaseTimeout = 5 * time.Second
+
+	// maxLeaseName is the DNS subdomain limit for object names.
+	maxLeaseName = 253
+)
+
+// Config holds the membership settings for a single replica.
+type Config struct {
+	Namespace     string        // Namespace housing the member leases
+	Group         string        // Shard group name; member leases are named <Group>-<Identity>
+	Identity      string        // Unique, DNS-safe replica identity (pod name)
+	LeaseDuration time.Duration // How long a member is considered alive without renewing
+	RenewPeriod   time.Duration // How often the member lease is renewed and peers are listed
+	VirtualNodes  int           // Points per member on the hash ring (0 = default)
+}
+
+// Validate checks the membership configuration.
+func (c Config) Validate() error {
+	if strings.TrimSpace(c.Namespace) == "" {
+		return errors.New("shard namespace is required")
+	}
+	if strings.TrimSpace(c.Group) == "" {
+		return errors.New("shard group is required")
+	}
+	if strings.TrimSpace(c.Identity) == "" {
+		return errors.New("shard identity is required")
+	}
+	if c.RenewPeriod <= 0 || c.LeaseDuration <= c.RenewPeriod {
+		return fmt.Errorf("lease duration (%v) must be greater than renew period (%v) > 0", c.LeaseDuration, c.RenewPeriod)
+	}
+	return nil
+}
+
+// Membership maintains this replica's member lease and the consistent-hash ring
+// built from all live members of the group. Each replica heartbeats its own lease
+// so there is no single writer to fail over; a member that stops renewing drops
+// off every peer's ring once its lease expires.
+type Membership struct {
+	client kubernetes.Interface
+	cfg    Config
+
+	mu   sync.RWMutex
+	ring *Ring
+
+	changes chan struct{}
+}
+
+// NewMembership creates a Membership for the given configuration.
+func NewMembership(client kubernetes.Interface, cfg Config) (*Membership, error) {
+	if client == nil {
+		return nil, errors.New("kubernetes client is nil")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Membership{
+		client:  client,
+		cfg:     cfg,
+		ring:    NewRing(nil, cfg.VirtualNodes),
+		changes: make(chan struct{}, 1),
+	}, nil
+}
+
+// Identity returns this replica's identity on the ring.
+func (m *Membership) Identity() string {
+	return m.cfg.Identity
+}
+
+// Owns reports whether this replica is responsible for key. Before the first
+// successful refresh the ring is empty and nothing is owned.
+func (m *Membership) Owns(key string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ring.Owner(key) == m.cfg.Identity
+}
+
+// Members returns the live members currently on the ring.
+func (m *Membership) Members() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.ring.Members()
+}
+
+// Changes signals every time the member set changes. The channel is buffered
+// and coalesces bursts, so consumers should re-read the full state on receipt.
+func (m *Membership) Changes() <-chan struct{} {
+	return m.changes
+}
+
+// Run heartbeats the member lease and refreshes the ring every RenewPeriod until
+// ctx is canceled, then deletes the member lease so peers rebalance immediately
+// instead of waiting for it to expire.
+func (m *Membership) Run(ctx context.Context, log *slog.Logger) {
+	if log == nil {
+		log = slog.Default()
+	}
+
+	t := time.NewTicker(m.cfg.RenewPeriod)
+	defer t.Stop()
+
+	for {
+		if err := m.Refresh(ctx, log); err != nil && ctx.Err() == nil {
+			log.Warn("failed to refresh shard membership", "identity", m.cfg.Identity, "err", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			m.release(ctx, log)
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// Refresh renews this replica's lease and rebuilds the ring from the live leases.
+func (m *Membership) Refresh(ctx context.Context, log *slog.Logger) error {
+	if err := m.renew(ctx); err != nil {
+		return fmt.Errorf("failed to renew member lease: %w", err)
+	}
+
+	list, err := m.client.CoordinationV1().Leases(m.cfg.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{GroupLabel: m.cfg.Group}).String(),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to list member leases: %w", err)
+	}
+
+	now := time.Now()
+	members := make([]string, 0, len(list.Items))
+	for i := range list.Items {
+		if id, ok := liveMember(&list.Items[i], now); ok {
+			members = append(members, id)
+		}
+	}
+
+	next := NewRing(members, m.cfg.VirtualNodes)
+
+	m.mu.Lock()
+	changed := !m.ring.Equal(next)
+	if changed {
+		m.ring = next
+	}
+	m.mu.Unlock()
+
+	if changed {
+		log.Info("shard membership changed", "identity", m.cfg.Identity, "members", next.Members())
+		select {
+		case m.changes <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// renew creates or updates this replica's member lease.
+func (m *Membership) renew(ctx context.Context) error {
+	leases := m.client.CoordinationV1().Leases(m.cfg.Namespace)
+	name := m.leaseName()
+	now := metav1.NewMicroTime(time.Now())
+	seconds := int32(m.cfg.LeaseDuration.Seconds())
+
+	lease, err := leases.Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		_, err = leases.Create(ctx, &coordinationv1.Lease{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: m.cfg.Namespace,
+				Labels:    map[string]string{GroupLabel: m.cfg.Group},
+			},
+			Spec: coordinationv1.LeaseSpec{
+				HolderIdentity:       &m.cfg.Identity,
+				LeaseDurationSeconds: &seconds,
+				AcquireTime:          &now,
+				RenewTime:            &now,
+			},
+		}, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	lease.Spec.HolderIdentity = &m.cfg.Identity
+	lease.Spec.LeaseDurationSeconds = &seconds
+	lease.Spec.RenewTime = &now
+	_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+	return err
+}
+
+// release deletes this replica's member lease.
+func (m *Membership) release(ctx context.Context, log *slog.Logger) {
+	rctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), releaseTimeout)
+	defer cancel()
+
+	err := m.client.CoordinationV1().Leases(m.cfg.Namespace).Delete(rctx, m.leaseName(), metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		log.Warn("failed to release member lease", "identity", m.cfg.Identity, "err", err)
+		return
+	}
+	log.Info("released member lease", "identity", m.cfg.Identity)
+}
+
+func (m *Membership) leaseName() string {
+	name := fmt.Sprintf("%s-%s", m.cfg.Group, m.cfg.Identity)
+	if len(name) > maxLeaseName {
+		name = name[:maxLeaseName]
+	}
+	return name
+}
+
+// liveMember returns the holder of a member lease if it was renewed within its duration.
+func liveMember(l *coordinationv1.Lease, now time.Time) (string, bool) {
+	if l.Spec.HolderIdentity == nil || *l.Spec.HolderIdentity == "" {
+		return "", false
+	}
+	if l.Spec.RenewTime == nil || l.Spec.LeaseDurationSeconds == nil {
+		return "", false
+	}
+
+	expires := l.Spec.RenewTime.Add(time.Duration(*l.Spec.LeaseDurationSeconds) * time.Second)
+	if now.After(expires) {
+		return "", false
+	}
+
+	return *l.Spec.HolderIdentity, true
+}
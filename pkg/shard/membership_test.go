@@ -0,0 +1,157 @@
+package shard
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mchmarny/gpuid/pkg/logger"
+	coordinationv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func testConfig(identity string) Config {
+	return Config{
+		Namespace:     "gpuid",
+		Group:         "gpuid-shard",
+		Identity:      identity,
+		LeaseDuration: 15 * time.Second,
+		RenewPeriod:   2 * time.Second,
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr bool
+	}{
+		{"valid", func(*Config) {}, false},
+		{"no_namespace", func(c *Config) { c.Namespace = "" }, true},
+		{"no_group", func(c *Config) { c.Group = " " }, true},
+		{"no_identity", func(c *Config) { c.Identity = "" }, true},
+		{"no_renew", func(c *Config) { c.RenewPeriod = 0 }, true},
+		{"lease_not_longer_than_renew", func(c *Config) { c.LeaseDuration = c.RenewPeriod }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig("gpuid-0")
+			tt.mutate(&cfg)
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMembership_Refresh(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewTestLogger(t)
+	cs := fake.NewClientset()
+
+	a, err := NewMembership(cs, testConfig("gpuid-a"))
+	if err != nil {
+		t.Fatalf("NewMembership() error: %v", err)
+	}
+	b, err := NewMembership(cs, testConfig("gpuid-b"))
+	if err != nil {
+		t.Fatalf("NewMembership() error: %v", err)
+	}
+
+	if a.Owns("node-1") {
+		t.Error("membership should own nothing before the first refresh")
+	}
+
+	if err := a.Refresh(ctx, log); err != nil {
+		t.Fatalf("Refresh() error: %v", err)
+	}
+	if got := a.Members(); len(got) != 1 || got[0] != "gpuid-a" {
+		t.Fatalf("Members() = %v, want [gpuid-a]", got)
+	}
+	select {
+	case <-a.Changes():
+	default:
+		t.Error("expected change notification after first refresh")
+	}
+
+	if err := b.Refresh(ctx, log); err != nil {
+		t.Fatalf("Refresh() error: %v", err)
+	}
+	if err := a.Refresh(ctx, log); err != nil {
+		t.Fatalf("Refresh() error: %v", err)
+	}
+	if got := a.Members(); len(got) != 2 {
+		t.Fatalf("Members() = %v, want two members", got)
+	}
+
+	// Every key is owned by exactly one replica.
+	for _, key := range []string{"node-1", "node-2", "node-3", "node-4", "node-5"} {
+		if a.Owns(key) == b.Owns(key) {
+			t.Errorf("key %s owned by both or neither replica", key)
+		}
+	}
+}
+
+func TestMembership_ExpiredMember(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewTestLogger(t)
+
+	holder := "gpuid-stale"
+	seconds := int32(15)
+	stale := metav1.NewMicroTime(time.Now().Add(-time.Minute))
+	cs := fake.NewClientset(&coordinationv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "gpuid-shard-gpuid-stale",
+			Namespace: "gpuid",
+			Labels:    map[string]string{GroupLabel: "gpuid-shard"},
+		},
+		Spec: coordinationv1.LeaseSpec{
+			HolderIdentity:       &holder,
+			LeaseDurationSeconds: &seconds,
+			RenewTime:            &stale,
+		},
+	})
+
+	m, err := NewMembership(cs, testConfig("gpuid-a"))
+	if err != nil {
+		t.Fatalf("NewMembership() error: %v", err)
+	}
+	if err := m.Refresh(ctx, log); err != nil {
+		t.Fatalf("Refresh() error: %v", err)
+	}
+
+	if got := m.Members(); len(got) != 1 || got[0] != "gpuid-a" {
+		t.Errorf("Members() = %v, expired member should be excluded", got)
+	}
+}
+
+func TestMembership_ReleaseOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	log := logger.NewTestLogger(t)
+	cs := fake.NewClientset()
+
+	m, err := NewMembership(cs, testConfig("gpuid-a"))
+	if err != nil {
+		t.Fatalf("NewMembership() error: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		m.Run(ctx, log)
+		close(done)
+	}()
+
+	<-m.Changes()
+	cancel()
+	<-done
+
+	leases, err := cs.CoordinationV1().Leases("gpuid").List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		t.Fatalf("List() error: %v", err)
+	}
+	if len(leases.Items) != 0 {
+		t.Errorf("expected member lease to be released, found %d", len(leases.Items))
+	}
+}
@@ -0,0 +1,104 @@
+package shard
+
+import (
+	"hash/fnv"
+	"sort"
+	"strconv"
+)
+
+// defaultVirtualNodes is the number of points each member occupies on the ring.
+// More points smooth the key distribution at the cost of a slightly larger ring.
+const defaultVirtualNodes = 128
+
+// Ring is an immutable consistent-hash ring mapping keys (node names) to members
+// (replica identities). Adding or removing a member only moves the keys adjacent
+// to that member's points, so most nodes keep their owner during rebalancing.
+type Ring struct {
+	points  []uint64
+	owners  map[uint64]string
+	members []string
+}
+
+// NewRing builds a ring from the given members. Duplicate and empty members are ignored.
+// A vnodes value <= 0 selects the default.
+func NewRing(members []string, vnodes int) *Ring {
+	if vnodes <= 0 {
+		vnodes = defaultVirtualNodes
+	}
+
+	r := &Ring{
+		owners: make(map[uint64]string),
+	}
+
+	seen := make(map[string]bool, len(members))
+	for _, m := range members {
+		if m == "" || seen[m] {
+			continue
+		}
+		seen[m] = true
+		r.members = append(r.members, m)
+	}
+	sort.Strings(r.members)
+
+	for _, m := range r.members {
+		for i := range vnodes {
+			h := hashKey(m + "#" + strconv.Itoa(i))
+			// Members are sorted, so on the (astronomically unlikely) collision the
+			// lexically smaller member keeps the point and every replica computes
+			// the same ring.
+			if _, exists := r.owners[h]; exists {
+				continue
+			}
+			r.points = append(r.points, h)
+			r.owners[h] = m
+		}
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+
+	return r
+}
+
+// Owner returns the member responsible for key, or an empty string when the ring is empty.
+func (r *Ring) Owner(key string) string {
+	if r == nil || len(r.points) == 0 {
+		return ""
+	}
+
+	h := hashKey(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= h })
+	if i == len(r.points) {
+		i = 0
+	}
+
+	return r.owners[r.points[i]]
+}
+
+// Members returns the sorted list of members on the ring.
+func (r *Ring) Members() []string {
+	if r == nil {
+		return nil
+	}
+	out := make([]string, len(r.members))
+	copy(out, r.members)
+	return out
+}
+
+// Equal reports whether both rings hold the same member set.
+func (r *Ring) Equal(o *Ring) bool {
+	a, b := r.Members(), o.Members()
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func hashKey(s string) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return h.Sum64()
+}
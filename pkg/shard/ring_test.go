@@ -0,0 +1,79 @@
+package shard
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing_Empty(t *testing.T) {
+	r := NewRing(nil, 0)
+	if got := r.Owner("node-1"); got != "" {
+		t.Errorf("Owner() on empty ring = %q, want empty", got)
+	}
+
+	var nilRing *Ring
+	if got := nilRing.Owner("node-1"); got != "" {
+		t.Errorf("Owner() on nil ring = %q, want empty", got)
+	}
+}
+
+func TestRing_Deterministic(t *testing.T) {
+	a := NewRing([]string{"b", "a", "c"}, 0)
+	b := NewRing([]string{"c", "b", "a", "a", ""}, 0)
+
+	if !a.Equal(b) {
+		t.Fatalf("rings with same members should be equal: %v vs %v", a.Members(), b.Members())
+	}
+
+	for i := range 1000 {
+		key := fmt.Sprintf("node-%d", i)
+		if a.Owner(key) != b.Owner(key) {
+			t.Fatalf("owner of %s differs between equivalent rings", key)
+		}
+	}
+}
+
+func TestRing_Distribution(t *testing.T) {
+	members := []string{"gpuid-0", "gpuid-1", "gpuid-2", "gpuid-3"}
+	r := NewRing(members, 0)
+
+	const keys = 10000
+	counts := make(map[string]int)
+	for i := range keys {
+		counts[r.Owner(fmt.Sprintf("node-%d", i))]++
+	}
+
+	if len(counts) != len(members) {
+		t.Fatalf("expected all %d members to own keys, got %v", len(members), counts)
+	}
+
+	fair := keys / len(members)
+	for m, c := range counts {
+		if c < fair/2 || c > fair*2 {
+			t.Errorf("member %s owns %d keys, expected roughly %d", m, c, fair)
+		}
+	}
+}
+
+func TestRing_MinimalMovement(t *testing.T) {
+	before := NewRing([]string{"gpuid-0", "gpuid-1", "gpuid-2"}, 0)
+	after := NewRing([]string{"gpuid-0", "gpuid-1", "gpuid-2", "gpuid-3"}, 0)
+
+	const keys = 10000
+	moved := 0
+	for i := range keys {
+		key := fmt.Sprintf("node-%d", i)
+		prev, next := before.Owner(key), after.Owner(key)
+		if prev != next {
+			if next != "gpuid-3" {
+				t.Fatalf("key %s moved from %s to %s; only moves to the new member are expected", key, prev, next)
+			}
+			moved++
+		}
+	}
+
+	// Roughly a quarter of the keys should move to the new member.
+	if moved == 0 || moved > keys/2 {
+		t.Errorf("unexpected number of moved keys: %d of %d", moved, keys)
+	}
+}
@@ -0,0 +1,131 @@
+// Package source loads operator-provided data (blocklists, manifests) from a
+// local file, a ConfigMap or a URL.
+package source
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Source schemes.
+	schemeFile      = "file"
+	schemeConfigMap = "configmap"
+	schemeHTTP      = "http"
+	schemeHTTPS     = "https"
+
+	// fetchTimeout bounds a single URL or ConfigMap read.
+	fetchTimeout = 30 * time.Second
+
+	// maxSize bounds the data read from a URL.
+	maxSize = 10 << 20
+)
+
+// Loader reads the current contents of a source.
+type Loader func(ctx context.Context) ([]byte, error)
+
+// New returns a Loader for source, which is one of:
+//
+//	/path/to/file or file:///path/to/file
+//	configmap://<namespace>/<name>   (every data value, in key order)
+//	http(s)://host/path
+//
+// The clientset is only used for ConfigMap sources; it may be nil when New is
+// only used to validate source.
+func New(source string, cs kubernetes.Interface) (Loader, error) {
+	if strings.HasPrefix(source, "/") {
+		return fileLoader(source), nil
+	}
+
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, fmt.Errorf("invalid source %q: %w", source, err)
+	}
+
+	switch u.Scheme {
+	case schemeFile:
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid source %q: file path is required", source)
+		}
+		return fileLoader(u.Path), nil
+	case schemeConfigMap:
+		name := strings.Trim(u.Path, "/")
+		if u.Host == "" || name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("invalid source %q: expected configmap://<namespace>/<name>", source)
+		}
+		return configMapLoader(cs, u.Host, name), nil
+	case schemeHTTP, schemeHTTPS:
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid source %q: host is required", source)
+		}
+		return urlLoader(&http.Client{Timeout: fetchTimeout}, source), nil
+	default:
+		return nil, fmt.Errorf("invalid source %q: expected a file path, configmap:// or http(s):// URL", source)
+	}
+}
+
+func fileLoader(path string) Loader {
+	return func(context.Context) ([]byte, error) {
+		return os.ReadFile(path) //nolint:gosec // G304: path is operator configuration
+	}
+}
+
+func configMapLoader(cs kubernetes.Interface, namespace, name string) Loader {
+	return func(ctx context.Context) ([]byte, error) {
+		if cs == nil {
+			return nil, fmt.Errorf("kubernetes clientset is required for ConfigMap source")
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, fetchTimeout)
+		defer cancel()
+
+		cm, err := cs.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+
+		keys := make([]string, 0, len(cm.Data))
+		for k := range cm.Data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		var buf bytes.Buffer
+		for _, k := range keys {
+			buf.WriteString(cm.Data[k])
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes(), nil
+	}
+}
+
+func urlLoader(client *http.Client, source string) Loader {
+	return func(ctx context.Context) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, source, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+		}
+		return io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	}
+}
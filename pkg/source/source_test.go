@@ -0,0 +1,89 @@
+package source
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoaders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.txt")
+	if err := os.WriteFile(path, []byte("a\n"), 0o600); err != nil {
+		t.Fatalf("failed to write data: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte("a\n"))
+	}))
+	defer srv.Close()
+
+	cs := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "gpuid", Name: "data"},
+		Data:       map[string]string{"b.txt": "b", "a.txt": "a"},
+	})
+
+	tests := []struct {
+		source  string
+		want    string
+		wantErr bool
+	}{
+		{source: path, want: "a\n"},
+		{source: "file://" + path, want: "a\n"},
+		{source: "configmap://gpuid/data", want: "a\nb\n"},
+		{source: "configmap://gpuid/missing", wantErr: true},
+		{source: srv.URL + "/data.txt", want: "a\n"},
+		{source: srv.URL + "/missing.txt", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			load, err := New(tt.source, cs)
+			if err != nil {
+				t.Fatalf("New() unexpected error: %v", err)
+			}
+			got, err := load(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && string(got) != tt.want {
+				t.Errorf("load() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewInvalid(t *testing.T) {
+	for _, source := range []string{
+		"data.txt",
+		"ftp://example.com/data",
+		"file://",
+		"configmap://gpuid",
+		"configmap://gpuid/a/b",
+		"https:///data",
+	} {
+		if _, err := New(source, nil); err == nil {
+			t.Errorf("New(%q) expected error", source)
+		}
+	}
+}
+
+func TestConfigMapWithoutClientset(t *testing.T) {
+	load, err := New("configmap://gpuid/data", nil)
+	if err != nil {
+		t.Fatalf("New() unexpected error: %v", err)
+	}
+	if _, err := load(context.Background()); err == nil {
+		t.Error("expected error without clientset")
+	}
+}